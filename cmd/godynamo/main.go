@@ -0,0 +1,347 @@
+// Command godynamo is an interactive SQL shell for AWS DynamoDB, built on top of the godynamo driver.
+//
+// Usage:
+//
+//	godynamo [-dsn <dsn>] [-format table|json|csv] [-timing] [-f script.sql] [-e statement]
+//
+// Flags:
+//   - dsn: data source name, same format as accepted by sql.Open("godynamo", dsn). If not supplied, the value of the
+//     environment variable GODYNAMO_DSN is used.
+//   - format: output format, one of table (default), json or csv.
+//   - timing: print elapsed time and consumed capacity after each statement.
+//   - f: execute statements from a file (use "-" for stdin) and exit; the exit code is non-zero if a statement fails.
+//   - e: execute a single statement and exit.
+//
+// In interactive mode, a statement is executed when a line ends with a semicolon. Meta commands start with a backslash,
+// type \help to list them. History is persisted to the file $HOME/.godynamo_history; previous statements are listed by
+// \history and re-executed by \g [n]. The shell does not edit lines itself (no arrow-key recall), wrap it with a line
+// editor such as rlwrap for that.
+//
+// @Available since v1.4.0
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btnguyen2k/godynamo"
+	"github.com/btnguyen2k/godynamo/migrate"
+)
+
+const (
+	promptMain         = "godynamo> "
+	promptContinuation = "       -> "
+	historyFileName    = ".godynamo_history"
+	maxHistoryEntries  = 1000
+)
+
+type shell struct {
+	conn        *sql.Conn
+	out         io.Writer
+	format      string
+	timing      bool
+	history     []string
+	historyFile string
+}
+
+func main() {
+	dsn := flag.String("dsn", os.Getenv("GODYNAMO_DSN"), "data source name, default value is taken from env GODYNAMO_DSN")
+	format := flag.String("format", formatTable, "output format: table, json or csv")
+	file := flag.String("f", "", "execute statements from file (\"-\" for stdin) and exit")
+	stmt := flag.String("e", "", "execute a single statement and exit")
+	timing := flag.Bool("timing", false, "print elapsed time and consumed capacity after each statement")
+	flag.Parse()
+
+	if !isValidFormat(*format) {
+		fmt.Fprintf(os.Stderr, "invalid output format <%s>\n", *format)
+		os.Exit(2)
+	}
+
+	db, err := sql.Open("godynamo", *dsn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening database: %s\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = db.Close() }()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error connecting to database: %s\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = conn.Close() }()
+
+	sh := &shell{conn: conn, out: os.Stdout, format: *format, timing: *timing}
+	switch {
+	case *stmt != "":
+		if err := sh.execute(strings.TrimSuffix(strings.TrimSpace(*stmt), ";")); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+	case *file != "":
+		if err := sh.runScript(*file); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+	default:
+		if home, err := os.UserHomeDir(); err == nil {
+			sh.historyFile = filepath.Join(home, historyFileName)
+			sh.loadHistory()
+		}
+		sh.repl(os.Stdin)
+	}
+}
+
+// runScript executes all statements in a script file, stopping at the first error.
+func (sh *shell) runScript(fileName string) error {
+	var content []byte
+	var err error
+	if fileName == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(fileName)
+	}
+	if err != nil {
+		return err
+	}
+	stmts, err := migrate.SplitStatements(string(content))
+	if err != nil {
+		return err
+	}
+	for i, stmt := range stmts {
+		if err := sh.execute(stmt); err != nil {
+			return fmt.Errorf("statement #%d <%s>: %w", i+1, stmt, err)
+		}
+	}
+	return nil
+}
+
+// repl runs the read-eval-print loop until EOF or \q.
+func (sh *shell) repl(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	buf := strings.Builder{}
+	fmt.Fprintf(sh.out, "godynamo shell v%s, type \\help for help.\n", godynamo.Version)
+	for {
+		if buf.Len() == 0 {
+			fmt.Fprint(sh.out, promptMain)
+		} else {
+			fmt.Fprint(sh.out, promptContinuation)
+		}
+		if !scanner.Scan() {
+			fmt.Fprintln(sh.out)
+			return
+		}
+		line := scanner.Text()
+		if buf.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), `\`) {
+			if quit := sh.metaCommand(strings.TrimSpace(line)); quit {
+				return
+			}
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+		stmts, complete := splitInput(buf.String())
+		if !complete {
+			continue
+		}
+		sh.addHistory(strings.TrimSpace(buf.String()))
+		buf.Reset()
+		for _, stmt := range stmts {
+			if err := sh.execute(stmt); err != nil {
+				fmt.Fprintf(sh.out, "error: %s\n", err)
+			}
+		}
+	}
+}
+
+// splitInput returns the statements in input if input is terminated by a semicolon (outside of quoted strings).
+func splitInput(input string) ([]string, bool) {
+	trimmed := strings.TrimSpace(input)
+	if !strings.HasSuffix(trimmed, ";") {
+		return nil, false
+	}
+	stmts, err := migrate.SplitStatements(trimmed)
+	if err != nil {
+		// unterminated quoted string
+		return nil, false
+	}
+	return stmts, true
+}
+
+// metaCommand executes a meta command, returns true if the shell should exit.
+func (sh *shell) metaCommand(cmd string) bool {
+	tokens := strings.Fields(cmd)
+	switch strings.ToLower(tokens[0]) {
+	case `\q`, `\quit`, `\exit`:
+		return true
+	case `\h`, `\help`, `\?`:
+		fmt.Fprintln(sh.out, `Statements are executed when a line ends with ";". Meta commands:
+  \format [table|json|csv]  show or change output format
+  \timing [on|off]          toggle printing of elapsed time and consumed capacity
+  \history                  show statement history
+  \g [n]                    re-execute the n-th statement of history (default: the last one)
+  \help                     show this help
+  \q                        quit`)
+	case `\timing`:
+		if len(tokens) > 1 {
+			sh.timing = strings.EqualFold(tokens[1], "on")
+		} else {
+			sh.timing = !sh.timing
+		}
+		fmt.Fprintf(sh.out, "Timing is %s.\n", map[bool]string{true: "on", false: "off"}[sh.timing])
+	case `\format`:
+		if len(tokens) > 1 {
+			if !isValidFormat(strings.ToLower(tokens[1])) {
+				fmt.Fprintf(sh.out, "error: invalid output format <%s>\n", tokens[1])
+				break
+			}
+			sh.format = strings.ToLower(tokens[1])
+		}
+		fmt.Fprintf(sh.out, "Output format is %s.\n", sh.format)
+	case `\history`:
+		for i, entry := range sh.history {
+			fmt.Fprintf(sh.out, "%5d  %s\n", i+1, entry)
+		}
+	case `\g`:
+		entry, err := sh.historyEntry(tokens[1:])
+		if err != nil {
+			fmt.Fprintf(sh.out, "error: %s\n", err)
+			break
+		}
+		fmt.Fprintln(sh.out, entry)
+		stmts, err := migrate.SplitStatements(entry)
+		if err != nil {
+			fmt.Fprintf(sh.out, "error: %s\n", err)
+			break
+		}
+		for _, stmt := range stmts {
+			if err := sh.execute(stmt); err != nil {
+				fmt.Fprintf(sh.out, "error: %s\n", err)
+			}
+		}
+	default:
+		fmt.Fprintf(sh.out, "error: unknown command <%s>, type \\help for help\n", tokens[0])
+	}
+	return false
+}
+
+// historyEntry returns the history entry selected by the arguments of meta command \g: the 1-based index of the entry
+// (as listed by \history), or the last entry if not specified.
+func (sh *shell) historyEntry(args []string) (string, error) {
+	if len(sh.history) == 0 {
+		return "", errors.New("history is empty")
+	}
+	if len(args) == 0 {
+		return sh.history[len(sh.history)-1], nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || n > len(sh.history) {
+		return "", fmt.Errorf("invalid history entry <%s>, expected a number between 1 and %d", args[0], len(sh.history))
+	}
+	return sh.history[n-1], nil
+}
+
+func (sh *shell) loadHistory() {
+	content, err := os.ReadFile(sh.historyFile)
+	if err != nil {
+		return
+	}
+	for _, entry := range strings.Split(string(content), "\n") {
+		if entry != "" {
+			sh.history = append(sh.history, strings.ReplaceAll(entry, `\n`, "\n"))
+		}
+	}
+	if len(sh.history) > maxHistoryEntries {
+		sh.history = sh.history[len(sh.history)-maxHistoryEntries:]
+	}
+}
+
+func (sh *shell) addHistory(entry string) {
+	sh.history = append(sh.history, entry)
+	if sh.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(sh.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	_, _ = fmt.Fprintln(f, strings.ReplaceAll(entry, "\n", `\n`))
+}
+
+// execute runs a statement and renders its result.
+func (sh *shell) execute(stmt string) error {
+	ctx := context.Background()
+	start := time.Now()
+	if returnsRows(stmt) {
+		dbrows, err := sh.conn.QueryContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+		numRows, err := render(sh.out, sh.format, dbrows)
+		_ = dbrows.Close()
+		if err != nil {
+			return err
+		}
+		if sh.format == formatTable {
+			fmt.Fprintf(sh.out, "(%d row(s))\n", numRows)
+		}
+	} else {
+		result, err := sh.conn.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+		numRows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sh.out, "%d row(s) affected\n", numRows)
+	}
+	if sh.timing {
+		elapsed := time.Since(start)
+		capacity := 0.0
+		_ = sh.conn.Raw(func(driverConn interface{}) error {
+			if c, ok := driverConn.(*godynamo.Conn); ok {
+				capacity = c.LastConsumedCapacity()
+			}
+			return nil
+		})
+		fmt.Fprintf(sh.out, "Time: %.3f ms, consumed capacity: %g\n", float64(elapsed.Microseconds())/1000.0, capacity)
+	}
+	return nil
+}
+
+var (
+	// reStringLiteral matches string literals and quoted identifiers, which are masked before looking for clauses.
+	reStringLiteral = regexp.MustCompile(`'[^']*'|"[^"]*"`)
+	// reReturning matches the RETURNING clause at the end of a statement, optionally followed by a WITH clause, the
+	// same way the driver does.
+	reReturning = regexp.MustCompile(`(?is)\sRETURNING\s+(ALL|MODIFIED)\s+(OLD|NEW)\s+\*(\s+WITH\s.*)?[\s;]*$`)
+)
+
+// returnsRows checks if a statement is expected to return a result set.
+func returnsRows(stmt string) bool {
+	fields := strings.Fields(strings.ToUpper(stmt))
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "SELECT", "LIST", "DESCRIBE", "EXPLAIN":
+		return true
+	case "INSERT", "UPDATE", "DELETE":
+		masked := reStringLiteral.ReplaceAllStringFunc(stmt, func(s string) string { return strings.Repeat("_", len(s)) })
+		return reReturning.MatchString(masked)
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_historyEntry(t *testing.T) {
+	testName := "Test_historyEntry"
+	sh := &shell{}
+	if _, err := sh.historyEntry(nil); err == nil {
+		t.Fatalf("%s failed: expected error for empty history", testName)
+	}
+	sh.history = []string{"LIST TABLES;", "SELECT * FROM tbl\nWHERE id='a';"}
+	testData := []struct {
+		args     []string
+		expected string
+		err      bool
+	}{
+		{args: nil, expected: "SELECT * FROM tbl\nWHERE id='a';"},
+		{args: []string{"1"}, expected: "LIST TABLES;"},
+		{args: []string{"2"}, expected: "SELECT * FROM tbl\nWHERE id='a';"},
+		{args: []string{"0"}, err: true},
+		{args: []string{"3"}, err: true},
+		{args: []string{"abc"}, err: true},
+	}
+	for _, testCase := range testData {
+		entry, err := sh.historyEntry(testCase.args)
+		if testCase.err != (err != nil) || entry != testCase.expected {
+			t.Fatalf("%s failed: for %v expected %#v (error: %v) but received %#v / %v", testName, testCase.args, testCase.expected, testCase.err, entry, err)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/btnguyen2k/godynamo"
+)
+
+const (
+	formatTable = "table"
+	formatJson  = "json"
+	formatCsv   = "csv"
+)
+
+func isValidFormat(format string) bool {
+	return format == formatTable || format == formatJson || format == formatCsv
+}
+
+// render writes all rows in the specified format and returns the number of rows written.
+func render(w io.Writer, format string, dbrows *sql.Rows) (int, error) {
+	colTypes, err := dbrows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+	cols := make([]string, len(colTypes))
+	dbTypes := make([]string, len(colTypes))
+	for i, colType := range colTypes {
+		cols[i] = colType.Name()
+		dbTypes[i] = colType.DatabaseTypeName()
+	}
+
+	rows := make([][]interface{}, 0)
+	for dbrows.Next() {
+		vals := make([]interface{}, len(cols))
+		scanVals := make([]interface{}, len(cols))
+		for i := range vals {
+			scanVals[i] = &vals[i]
+		}
+		if err := dbrows.Scan(scanVals...); err != nil {
+			return len(rows), err
+		}
+		rows = append(rows, vals)
+	}
+	if err := dbrows.Err(); err != nil {
+		return len(rows), err
+	}
+
+	switch format {
+	case formatJson:
+		return len(rows), renderJson(w, cols, rows)
+	case formatCsv:
+		return len(rows), renderCsv(w, cols, dbTypes, rows)
+	default:
+		return len(rows), renderTable(w, cols, dbTypes, rows)
+	}
+}
+
+// formatValue converts a value to its textual form, based on its DynamoDB data type (as reported by ColumnTypeDatabaseTypeName).
+func formatValue(val interface{}, dbType string) string {
+	if val == nil {
+		return "NULL"
+	}
+	switch v := val.(type) {
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case *godynamo.BigNumber:
+		return v.String()
+	case *big.Float:
+		return v.Text('g', -1)
+	}
+	switch dbType {
+	case "M", "L", "SS", "NS", "BS":
+		js, _ := json.Marshal(val)
+		return string(js)
+	}
+	switch val.(type) {
+	case map[string]interface{}, []interface{}:
+		js, _ := json.Marshal(val)
+		return string(js)
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+func renderJson(w io.Writer, cols []string, rows [][]interface{}) error {
+	result := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		result[i] = make(map[string]interface{}, len(cols))
+		for j, col := range cols {
+			if row[j] != nil {
+				result[i][col] = row[j]
+			}
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+func renderCsv(w io.Writer, cols, dbTypes []string, rows [][]interface{}) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(cols); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(cols))
+		for i := range cols {
+			if row[i] != nil {
+				record[i] = formatValue(row[i], dbTypes[i])
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func renderTable(w io.Writer, cols, dbTypes []string, rows [][]interface{}) error {
+	widths := make([]int, len(cols))
+	cells := make([][]string, len(rows))
+	for i, col := range cols {
+		widths[i] = utf8.RuneCountInString(col)
+	}
+	for r, row := range rows {
+		cells[r] = make([]string, len(cols))
+		for i := range cols {
+			cells[r][i] = strings.ReplaceAll(formatValue(row[i], dbTypes[i]), "\n", `\n`)
+			if l := utf8.RuneCountInString(cells[r][i]); l > widths[i] {
+				widths[i] = l
+			}
+		}
+	}
+
+	sep := strings.Builder{}
+	sep.WriteString("+")
+	for _, width := range widths {
+		sep.WriteString(strings.Repeat("-", width+2))
+		sep.WriteString("+")
+	}
+	writeRow := func(values []string) {
+		line := strings.Builder{}
+		line.WriteString("|")
+		for i, v := range values {
+			line.WriteString(" ")
+			line.WriteString(v)
+			line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(v)))
+			line.WriteString(" |")
+		}
+		_, _ = fmt.Fprintln(w, line.String())
+	}
+
+	_, _ = fmt.Fprintln(w, sep.String())
+	writeRow(cols)
+	_, _ = fmt.Fprintln(w, sep.String())
+	for _, row := range cells {
+		writeRow(row)
+	}
+	_, err := fmt.Fprintln(w, sep.String())
+	return err
+}
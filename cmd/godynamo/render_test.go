@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func Test_renderTable(t *testing.T) {
+	testName := "Test_renderTable"
+	buf := &bytes.Buffer{}
+	cols := []string{"id", "tags", "score"}
+	dbTypes := []string{"S", "SS", "N"}
+	rows := [][]interface{}{{"a", []interface{}{"x", "y"}, 1.5}, {"bb", nil, nil}}
+	if err := renderTable(buf, cols, dbTypes, rows); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	expected := `+----+-----------+-------+
+| id | tags      | score |
++----+-----------+-------+
+| a  | ["x","y"] | 1.5   |
+| bb | NULL      | NULL  |
++----+-----------+-------+
+`
+	if buf.String() != expected {
+		t.Fatalf("%s failed: expected\n%s\nreceived\n%s", testName, expected, buf.String())
+	}
+}
+
+func Test_renderCsv(t *testing.T) {
+	testName := "Test_renderCsv"
+	buf := &bytes.Buffer{}
+	cols := []string{"id", "info"}
+	dbTypes := []string{"S", "M"}
+	rows := [][]interface{}{{"a,b", map[string]interface{}{"k": "v"}}, {"c", nil}}
+	if err := renderCsv(buf, cols, dbTypes, rows); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	expected := "id,info\n\"a,b\",\"{\"\"k\"\":\"\"v\"\"}\"\nc,\n"
+	if buf.String() != expected {
+		t.Fatalf("%s failed: expected %q received %q", testName, expected, buf.String())
+	}
+}
+
+func Test_returnsRows(t *testing.T) {
+	testName := "Test_returnsRows"
+	testCases := map[string]bool{
+		`SELECT * FROM "t"`:                                 true,
+		`list tables`:                                       true,
+		`DESCRIBE TABLE t`:                                  true,
+		`INSERT INTO "t" VALUE {'id': 1}`:                   false,
+		`UPDATE "t" SET a=1 WHERE id=1`:                     false,
+		`UPDATE "t" SET a=1 WHERE id=1 RETURNING ALL NEW *`: true,
+		`DELETE FROM "t" WHERE id=1 RETURNING ALL OLD *`:    true,
+		`CREATE TABLE t WITH PK=id:string`:                  false,
+
+		`INSERT INTO "t" VALUE {'id': 1} RETURNING ALL NEW *`:                           true,
+		`UPDATE "t" SET a=1 WHERE id=1 returning all old *` + "\n" + `WITH STRICT=true`: true,
+		`UPDATE "t" SET a=1 WHERE id=1 WITH STRICT=true`:                                false,
+		`DELETE FROM "t" WHERE id=1 RETURNING MODIFIED OLD *;`:                          true,
+		`DELETE FROM "t" WHERE id=' RETURNING ALL OLD *'`:                               false,
+	}
+	for stmt, expected := range testCases {
+		if returnsRows(stmt) != expected {
+			t.Fatalf("%s failed: <%s> expected %#v", testName, stmt, expected)
+		}
+	}
+}
+
+func Test_splitInput(t *testing.T) {
+	testName := "Test_splitInput"
+	if _, complete := splitInput("SELECT * FROM \"t\"\n"); complete {
+		t.Fatalf("%s failed: input must be incomplete", testName)
+	}
+	if _, complete := splitInput("SELECT * FROM \"t\" WHERE id='a;\n"); complete {
+		t.Fatalf("%s failed: input must be incomplete", testName)
+	}
+	stmts, complete := splitInput("LIST TABLES;\nDESCRIBE TABLE t;\n")
+	if !complete || !reflect.DeepEqual(stmts, []string{"LIST TABLES", "DESCRIBE TABLE t"}) {
+		t.Fatalf("%s failed: received %#v", testName, stmts)
+	}
+}
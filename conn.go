@@ -2,9 +2,12 @@ package godynamo
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,6 +24,13 @@ var (
 	ErrTxRollingBack  = errors.New("transaction is being rolled back")
 )
 
+// ErrReadOnly is returned when a statement that may modify tables or data is prepared or executed on a read-only
+// connection (DSN option ReadOnly=true) or inside a read-only transaction (sql.TxOptions.ReadOnly), including statements
+// prepared before the transaction started (sql.Tx.Stmt).
+//
+// @Available since v1.4.0
+var ErrReadOnly = errors.New("connection is read-only")
+
 type txMode int
 
 const (
@@ -35,6 +45,18 @@ type txStmt struct {
 	stmt   *Stmt
 	values []driver.NamedValue
 	output *dynamodb.ExecuteStatementOutput
+
+	// rows returned by the statement's RETURNING clause once the transaction is committed, nil if not requested (@Available since v1.4.0)
+	returnItems []map[string]types.AttributeValue
+	// SELECT statement fetching the old image (returnItems) right before the transaction is executed, nil if not needed,
+	// and the position right after the WHERE keyword of the statement, where the condition on the old image is added (@Available since v1.4.0)
+	oldImageStmt     *Stmt
+	oldImageValues   []driver.NamedValue
+	oldImageWhereEnd int
+	// receives returnItems once the transaction is committed, passed to the statement via sql.Out (@Available since v1.4.0)
+	returning *TxReturning
+	// the version attribute specified by clause WITH VERSION of an UPDATE statement, empty if not specified (@Available since v1.4.0)
+	versionAttr string
 }
 
 type executeStatementOutputWrapper func() *dynamodb.ExecuteStatementOutput
@@ -47,25 +69,110 @@ type Conn struct {
 	tx         *Tx
 	txMode     txMode
 	txStmtList []*txStmt
+
+	lastConsumedCapacity float64 // capacity units consumed by the last executed statement/transaction
+
+	numberMode        string                 // how N attributes are returned, see NumberModeFloat (@Available since v1.4.0)
+	disallowScan      bool                   // if true, SELECT statements that would run as full scans are refused (@Available since v1.4.0)
+	strict            bool                   // if true, failed condition checks of UPDATE/DELETE are reported as errors (@Available since v1.4.0)
+	tableDescriptions *tableDescriptionCache // cached table descriptions, shared by connections with the same DSN settings, see dsnCacheKey (@Available since v1.4.0)
+	tablePrefix       string                 // prefix of the physical table names, DSN option TablePrefix (@Available since v1.4.0)
+	readOnly          bool                   // if true, only read statements are allowed, DSN option ReadOnly (@Available since v1.4.0)
+	hooks             []Hooks                // hooks observing this connection, in addition to the global ones (@Available since v1.4.0)
+	lastPageCount     int                    // number of pages fetched by the last executed statement (@Available since v1.4.0)
+	stmtCache         *stmtCache             // cached parsed statements, shared by connections with the same DSN, nil if disabled (@Available since v1.4.0)
+	txReturning       *TxReturning           // passed via sql.Out to the statement being added to the transaction, see CheckNamedValue (@Available since v1.4.0)
 }
 
-func (c *Conn) newContext() context.Context {
-	ctx, cancelFunc := context.WithTimeout(context.Background(), c.timeout)
-	go func() {
-		time.Sleep(c.timeout)
-		cancelFunc()
-	}()
-	return ctx
+// LastConsumedCapacity returns the total capacity units consumed by the last INSERT, SELECT, UPDATE or DELETE statement
+// (or the last committed transaction) executed on this connection.
+//
+// Use sql.Conn.Raw to access the underlying *Conn:
+//
+//	conn, _ := db.Conn(ctx)
+//	conn.Raw(func(driverConn interface{}) error {
+//		capacity := driverConn.(*godynamo.Conn).LastConsumedCapacity()
+//		...
+//	})
+//
+// @Available since v1.4.0
+func (c *Conn) LastConsumedCapacity() float64 {
+	return c.lastConsumedCapacity
+}
+
+// capacityUnits returns the total capacity units from a ConsumedCapacity, or 0 if cc is nil.
+func capacityUnits(cc *types.ConsumedCapacity) float64 {
+	if cc == nil || cc.CapacityUnits == nil {
+		return 0
+	}
+	return *cc.CapacityUnits
+}
+
+// physicalTableName returns the name of the table in DynamoDB, i.e. the logical name prefixed with DSN option TablePrefix.
+//
+// @Available since v1.4.0
+func (c *Conn) physicalTableName(tableName string) string {
+	if c == nil || c.tablePrefix == "" || tableName == "" {
+		return tableName
+	}
+	return c.tablePrefix + tableName
+}
+
+// logicalTableName strips DSN option TablePrefix from the name of the table in DynamoDB. ok is false if the table does
+// not belong to the connection's namespace.
+//
+// @Available since v1.4.0
+func (c *Conn) logicalTableName(tableName string) (name string, ok bool) {
+	if c == nil || c.tablePrefix == "" {
+		return tableName, true
+	}
+	if !strings.HasPrefix(tableName, c.tablePrefix) {
+		return tableName, false
+	}
+	return tableName[len(c.tablePrefix):], true
 }
 
-func (c *Conn) ensureContext(ctx context.Context) context.Context {
+// isReadOnly returns true if the connection is read-only (DSN option ReadOnly) or has an ongoing read-only transaction.
+//
+// @Available since v1.4.0
+func (c *Conn) isReadOnly() bool {
+	return c != nil && (c.readOnly || (c.tx != nil && c.tx.readOnly))
+}
+
+// checkWritable returns an error wrapping ErrReadOnly if the connection is read-only, see isReadOnly. Statements that
+// may modify tables or data are checked when parsed, and again when executed: database/sql reuses statements prepared
+// outside a transaction inside it (sql.Tx.Stmt).
+//
+// @Available since v1.4.0
+func (c *Conn) checkWritable(query string) error {
+	if c.isReadOnly() {
+		return fmt.Errorf("%w, statement is not allowed: %s", ErrReadOnly, query)
+	}
+	return nil
+}
+
+// newContext derives the context of an operation from ctx (context.Background() if nil), bounded by timeout, or by the
+// connection's timeout (DSN option TimeoutMs) if timeout is 0. The returned cancel function must be called to release
+// the context's resources once the operation completes.
+//
+// @Since v1.4.0 the context is derived from the caller's context, and no longer leaks a goroutine until timeout.
+func (c *Conn) newContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
 	if ctx == nil {
-		ctx = c.newContext()
+		ctx = context.Background()
 	}
-	return ctx
+	if timeout <= 0 {
+		timeout = c.timeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
-func (c *Conn) commit() error {
+// commit executes the statements of the ongoing transaction.
+//
+// @Since v1.4.0 the context is passed to the AWS SDK, so that hooks observe the retries of the transaction.
+func (c *Conn) commit(ctx context.Context) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	if c.tx == nil {
@@ -82,6 +189,7 @@ func (c *Conn) commit() error {
 		c.tx = nil
 		c.txMode = txNone
 		c.txStmtList = nil
+		c.txReturning = nil
 	}()
 
 	if len(c.txStmtList) == 0 {
@@ -89,32 +197,119 @@ func (c *Conn) commit() error {
 		return nil
 	}
 
+	paramsList := make([][]types.AttributeValue, len(c.txStmtList))
+	for i, txStmt := range c.txStmtList {
+		params, err := txStmt.stmt.marshalParameters(txStmt.values)
+		if err != nil {
+			return fmt.Errorf("%s, statement <%s>", err, txStmt.stmt.query)
+		}
+		paramsList[i] = params
+	}
+	ctx, cancel := c.newContext(ctx, 0)
+	defer cancel()
+	c.lastConsumedCapacity = 0
+	if err := c.fetchOldImages(ctx); err != nil {
+		return err
+	}
 	txStmts := make([]types.ParameterizedStatement, len(c.txStmtList))
 	for i, txStmt := range c.txStmtList {
-		params := make([]types.AttributeValue, len(txStmt.values))
-		var err error
-		for j, v := range txStmt.values {
-			params[j], err = ToAttributeValue(v.Value)
-			if err != nil {
-				return fmt.Errorf("error marshalling parameter %d-th for statement <%s>: %s", j+1, txStmt.stmt.query, err)
-			}
+		query, params := txStmt.stmt.query, paramsList[i]
+		if txStmt.oldImageStmt != nil && len(txStmt.returnItems) == 1 {
+			// the write must not succeed if the item was modified after its old image was read
+			query, params = conditionOnOldImage(query, txStmt.oldImageWhereEnd, params, txStmt.returnItems[0])
+		}
+		txStmts[i] = types.ParameterizedStatement{Statement: aws.String(query), Parameters: params}
+		if txStmt.stmt.strict {
+			txStmts[i].ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
 		}
-		txStmts[i] = types.ParameterizedStatement{Statement: &txStmt.stmt.query, Parameters: params}
 	}
 	input := &dynamodb.ExecuteTransactionInput{
 		TransactStatements:     txStmts,
 		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
 	}
-	outputExecuteTransaction, err := c.client.ExecuteTransaction(c.newContext(), input)
+	outputExecuteTransaction, err := c.client.ExecuteTransaction(ctx, input)
+	err = wrapAwsError(err, nil)
 	if err == nil {
+		for i := range outputExecuteTransaction.ConsumedCapacity {
+			c.lastConsumedCapacity += capacityUnits(&outputExecuteTransaction.ConsumedCapacity[i])
+		}
 		for i, txStmt := range c.txStmtList {
 			txStmt.output = &dynamodb.ExecuteStatementOutput{ResultMetadata: outputExecuteTransaction.ResultMetadata}
 			if len(outputExecuteTransaction.ConsumedCapacity) > i {
 				txStmt.output.ConsumedCapacity = &outputExecuteTransaction.ConsumedCapacity[i]
 			}
-			if len(outputExecuteTransaction.Responses) > i {
+			if txStmt.returnItems != nil {
+				txStmt.output.Items = txStmt.returnItems
+			} else if len(outputExecuteTransaction.Responses) > i {
 				txStmt.output.Items = []map[string]types.AttributeValue{outputExecuteTransaction.Responses[i].Item}
 			}
+			if txStmt.returning != nil {
+				txStmt.returning.Items = txStmt.returnItems
+			}
+		}
+	}
+	return c.transactionError(err)
+}
+
+// conditionOnOldImage adds to the WHERE clause of an UPDATE/DELETE statement (starting at whereEnd) a condition
+// requiring each attribute of the item to be equal to its value in the old image.
+func conditionOnOldImage(query string, whereEnd int, params []types.AttributeValue, oldImage map[string]types.AttributeValue) (string, []types.AttributeValue) {
+	attrs := make([]string, 0, len(oldImage))
+	for attr := range oldImage {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	conditions := make([]string, len(attrs))
+	for i, attr := range attrs {
+		conditions[i] = `"` + attr + `"=?`
+		params = append(params, oldImage[attr])
+	}
+	return fmt.Sprintf("%s (%s) AND %s", strings.TrimSpace(query[:whereEnd]), strings.TrimSpace(query[whereEnd:]), strings.Join(conditions, " AND ")), params
+}
+
+// fetchOldImages fetches, with strongly consistent reads, the old images of the transaction's statements that request
+// them.
+func (c *Conn) fetchOldImages(ctx context.Context) error {
+	for _, txStmt := range c.txStmtList {
+		if txStmt.oldImageStmt == nil {
+			continue
+		}
+		input, err := buildExecuteStatementInput(txStmt.oldImageStmt, txStmt.oldImageValues)
+		if err != nil {
+			return fmt.Errorf("%s, statement <%s>", err, txStmt.oldImageStmt.query)
+		}
+		input.ConsistentRead = aws.Bool(true)
+		txStmt.returnItems = make([]map[string]types.AttributeValue, 0)
+		err = c.fetchPages(ctx, txStmt.oldImageStmt, input, func(page *dynamodb.ExecuteStatementOutput) error {
+			txStmt.returnItems = append(txStmt.returnItems, page.Items...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastTxStmt returns the statement most recently added to the ongoing transaction.
+func (c *Conn) lastTxStmt() *txStmt {
+	return c.txStmtList[len(c.txStmtList)-1]
+}
+
+// transactionError returns a ConditionFailedError if the transaction was canceled because the condition check of a
+// strict statement failed (wrapping ErrVersionConflict if the statement has clause WITH VERSION), otherwise err is
+// returned as-is (nil included).
+func (c *Conn) transactionError(err error) error {
+	var txErr *types.TransactionCanceledException
+	if !errors.As(err, &txErr) {
+		return err
+	}
+	for i, reason := range txErr.CancellationReasons {
+		if i < len(c.txStmtList) && c.txStmtList[i].stmt.strict && aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+			if c.txStmtList[i].versionAttr != "" {
+				return &ConditionFailedError{Item: reason.Item, Err: fmt.Errorf("%w, %s", versionConflictError(c.txStmtList[i].versionAttr), err)}
+			}
+			return &ConditionFailedError{Item: reason.Item, Err: err}
 		}
 	}
 	return err
@@ -137,18 +332,24 @@ func (c *Conn) rollback() error {
 		c.tx = nil
 		c.txMode = txNone
 		c.txStmtList = nil
+		c.txReturning = nil
 	}()
 	return nil
 }
 
 // execute executes a PartiQL query and returns the result output.
 func (c *Conn) executeContext(ctx context.Context, stmt *Stmt, values []driver.NamedValue) (executeStatementOutputWrapper, error) {
-	//fmt.Printf("[DEBUG] executeContext: in-tx %5v - %s\n", c.tx != nil, stmt.query)
+	if !reSelect.MatchString(stmt.query) {
+		if err := c.checkWritable(stmt.query); err != nil {
+			return nil, err
+		}
+	}
 	if c.txMode == txStarted {
 		// transaction has started and not yet committed or rolled back
 		// --> can add more statements to the transaction
-		txStmt := txStmt{stmt: stmt, values: values}
+		txStmt := txStmt{stmt: stmt, values: values, returning: c.txReturning}
 		c.txStmtList = append(c.txStmtList, &txStmt)
+		c.txReturning = nil
 		return func() *dynamodb.ExecuteStatementOutput {
 			return txStmt.output
 		}, ErrInTx
@@ -161,13 +362,33 @@ func (c *Conn) executeContext(ctx context.Context, stmt *Stmt, values []driver.N
 
 	/* not in transaction mode, execute the statement normally */
 
-	params := make([]types.AttributeValue, len(values))
-	var err error
-	for i, v := range values {
-		params[i], err = ToAttributeValue(v.Value)
-		if err != nil {
-			return nil, fmt.Errorf("error marshalling parameter %d-th: %s", i+1, err)
+	input, err := buildExecuteStatementInput(stmt, values)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lastConsumedCapacity = 0
+	c.lastPageCount = 0
+	if !reSelect.MatchString(stmt.query) {
+		output, err := c.client.ExecuteStatement(ctx, input)
+		err = wrapAwsError(err, nil)
+		c.lastPageCount = 1
+		if err == nil {
+			c.lastConsumedCapacity = capacityUnits(output.ConsumedCapacity)
 		}
+		return func() *dynamodb.ExecuteStatementOutput {
+			return output
+		}, err
+	}
+
+	return c.executeSelectContext(ctx, stmt, input)
+}
+
+// buildExecuteStatementInput marshals the parameters and builds the input to execute a PartiQL statement.
+func buildExecuteStatementInput(stmt *Stmt, values []driver.NamedValue) (*dynamodb.ExecuteStatementInput, error) {
+	params, err := stmt.marshalParameters(values)
+	if err != nil {
+		return nil, err
 	}
 
 	input := &dynamodb.ExecuteStatementInput{
@@ -178,50 +399,56 @@ func (c *Conn) executeContext(ctx context.Context, stmt *Stmt, values []driver.N
 	if len(params) > 0 {
 		input.Parameters = params
 	}
+	if stmt.strict {
+		input.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+	}
 	if consistentRead, ok := stmt.withOpts["CONSISTENT_READ"]; ok {
 		input.ConsistentRead = aws.Bool(consistentRead.FirstBool())
 	} else if consistentRead, ok = stmt.withOpts["CONSISTENTREAD"]; ok {
 		input.ConsistentRead = aws.Bool(consistentRead.FirstBool())
 	}
-
-	if !reSelect.MatchString(stmt.query) {
-		output, err := c.client.ExecuteStatement(c.ensureContext(ctx), input)
-		return func() *dynamodb.ExecuteStatementOutput {
-			return output
-		}, err
-	}
-
-	return c.executeSelectContext(ctx, stmt, input)
+	return input, nil
 }
 
-// SELECT query could be paged, need to fetch all pages
-func (c *Conn) executeSelectContext(ctx context.Context, stmt *Stmt, input *dynamodb.ExecuteStatementInput) (executeStatementOutputWrapper, error) {
-	ctx = c.ensureContext(ctx)
-	var firstOutput *dynamodb.ExecuteStatementOutput
-	var err error
+// fetchPages executes a SELECT query and invokes f for each fetched page, until all pages are fetched or the LIMIT is reached.
+// Items exceeding the LIMIT are removed from the last page before it is passed to f.
+func (c *Conn) fetchPages(ctx context.Context, stmt *Stmt, input *dynamodb.ExecuteStatementInput, f func(page *dynamodb.ExecuteStatementOutput) error) error {
 	var limitNumItems int32 = 0
 	if stmt.limit != nil {
 		limitNumItems = *stmt.limit
 	}
-	//idx := 0                         // FIXME
-	//fetched := make(map[string]bool) // FIXME
+	numItems := int32(0)
 	for {
-		var output *dynamodb.ExecuteStatementOutput
-		output, err = c.client.ExecuteStatement(ctx, input)
+		output, err := c.client.ExecuteStatement(ctx, input)
 		if err != nil {
-			return func() *dynamodb.ExecuteStatementOutput {
-				return output
-			}, err
+			return wrapAwsError(err, nil)
 		}
+		c.lastConsumedCapacity += capacityUnits(output.ConsumedCapacity)
+		c.lastPageCount++
+		input.NextToken = output.NextToken
 
-		//// FIXME
-		//idx++
-		//for _, item := range output.Items {
-		//	fetched[item["id"].(*types.AttributeValueMemberS).Value] = true
-		//}
-		//fmt.Printf("[DEBUG] %2d / %s (LIMIT %#v) / LastEvaluatedKey: %d - NextToken: %5v / Fetched: %2d - Total: %2d\n", idx, stmt.query, stmt.limit, len(output.LastEvaluatedKey), output.NextToken != nil, len(output.Items), len(fetched))
-		//// END FIXME
+		if limitNumItems > 0 && numItems+int32(len(output.Items)) >= limitNumItems {
+			output.Items = output.Items[:limitNumItems-numItems]
+			return f(output)
+		}
+		if err := f(output); err != nil {
+			return err
+		}
+		numItems += int32(len(output.Items))
+		if limitNumItems > 0 {
+			input.Limit = aws.Int32(limitNumItems - numItems)
+		}
+
+		if output.NextToken == nil {
+			return nil
+		}
+	}
+}
 
+// SELECT query could be paged, need to fetch all pages
+func (c *Conn) executeSelectContext(ctx context.Context, stmt *Stmt, input *dynamodb.ExecuteStatementInput) (executeStatementOutputWrapper, error) {
+	var firstOutput *dynamodb.ExecuteStatementOutput
+	err := c.fetchPages(ctx, stmt, input, func(output *dynamodb.ExecuteStatementOutput) error {
 		if firstOutput == nil {
 			firstOutput = output
 		} else {
@@ -231,20 +458,10 @@ func (c *Conn) executeSelectContext(ctx context.Context, stmt *Stmt, input *dyna
 			firstOutput.ConsumedCapacity = output.ConsumedCapacity
 			firstOutput.Items = append(firstOutput.Items, output.Items...)
 		}
-		input.NextToken = output.NextToken
-
-		//merge result
-		if limitNumItems > 0 {
-			if len(firstOutput.Items) >= int(limitNumItems) {
-				firstOutput.Items = firstOutput.Items[:limitNumItems]
-				break
-			}
-			input.Limit = aws.Int32(limitNumItems - int32(len(firstOutput.Items)))
-		}
-
-		if output.NextToken == nil {
-			break
-		}
+		return nil
+	})
+	if err != nil {
+		firstOutput = nil
 	}
 	return func() *dynamodb.ExecuteStatementOutput {
 		return firstOutput
@@ -261,8 +478,16 @@ func (c *Conn) Prepare(query string) (driver.Stmt, error) {
 // Note: since v1.2.0, this function returns ErrInTx if there is an outgoing transaction.
 //
 // @Available since v0.2.0
+//
+// @Since v1.4.0 the returned statement reports its executions to the registered Hooks, if any.
 func (c *Conn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
-	return parseQuery(c, query)
+	// a TxReturning passed via sql.Out to a statement that failed before being executed is discarded
+	c.txReturning = nil
+	stmt, err := parseQuery(c, query)
+	if err != nil {
+		return stmt, err
+	}
+	return c.withHooks(stmt), nil
 }
 
 // Close implements driver.Conn/Close.
@@ -282,11 +507,13 @@ func (c *Conn) Begin() (driver.Tx, error) {
 // BeginTx implements driver.Conn/BeginTx.
 //
 // @Available since v0.2.0
-func (c *Conn) BeginTx(_ context.Context, _ driver.TxOptions) (driver.Tx, error) {
+//
+// @Since v1.4.0 the transaction keeps ctx, which bounds its commit.
+func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	if c.tx == nil {
-		c.tx = &Tx{conn: c}
+		c.tx = &Tx{conn: c, readOnly: opts.ReadOnly, ctx: ctx}
 		c.txMode = txStarted
 		c.txStmtList = make([]*txStmt, 0)
 		return c.tx, nil
@@ -294,8 +521,65 @@ func (c *Conn) BeginTx(_ context.Context, _ driver.TxOptions) (driver.Tx, error)
 	return c.tx, ErrInTx
 }
 
+// Ping implements driver.Pinger/Ping.
+//
+// It issues a cheap authenticated request (ListTables with Limit=1), so that bad credentials or an unreachable endpoint
+// are detected by sql.DB.PingContext.
+//
+// @Available since v1.4.0
+func (c *Conn) Ping(ctx context.Context) error {
+	ctx, cancel := c.newContext(ctx, 0)
+	defer cancel()
+	_, err := c.client.ListTables(ctx, &dynamodb.ListTablesInput{Limit: aws.Int32(1)})
+	return wrapAwsError(err, nil)
+}
+
+// ResetSession implements driver.SessionResetter/ResetSession.
+//
+// It is called by database/sql before the connection is reused, and clears any transaction left over on the connection.
+//
+// @Available since v1.4.0
+func (c *Conn) ResetSession(_ context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tx = nil
+	c.txMode = txNone
+	c.txStmtList = nil
+	c.txReturning = nil
+	return nil
+}
+
+// IsValid implements driver.Validator/IsValid.
+//
+// It reports false if the connection's transaction got stuck while being committed or rolled back, so that database/sql
+// discards the connection instead of returning it to the pool.
+//
+// @Available since v1.4.0
+func (c *Conn) IsValid() bool {
+	if !c.lock.TryLock() {
+		// a commit is still in progress
+		return false
+	}
+	defer c.lock.Unlock()
+	return c.txMode != txCommitting && c.txMode != txRollingBack
+}
+
 // CheckNamedValue implements driver.NamedValueChecker/CheckNamedValue.
-func (c *Conn) CheckNamedValue(_ *driver.NamedValue) error {
+//
+// @Since v1.4.0 an argument sql.Out{Dest: *TxReturning} is removed from the statement's arguments, and receives the rows
+// returned by the statement once the transaction is committed, see TxReturning.
+func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if out, ok := nv.Value.(sql.Out); ok {
+		returning, ok := out.Dest.(*TxReturning)
+		if !ok {
+			return fmt.Errorf("unsupported sql.Out destination %T, only *godynamo.TxReturning is supported", out.Dest)
+		}
+		if c.txMode != txStarted {
+			return errors.New("sql.Out{Dest: *godynamo.TxReturning} is only supported inside a transaction")
+		}
+		c.txReturning = returning
+		return driver.ErrRemoveArgument
+	}
 	// since DynamoDB is document db, it accepts any value types
 	return nil
 }
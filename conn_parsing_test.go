@@ -0,0 +1,140 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConn_Ping(t *testing.T) {
+	testName := "TestConn_Ping"
+	var requests []map[string]interface{}
+	authorized := true
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		input["Target"] = r.Header.Get("X-Amz-Target")
+		requests = append(requests, input)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if !authorized {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#UnrecognizedClientException","message":"The security token included in the request is invalid."}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"TableNames":["tbl"],"LastEvaluatedTableName":"tbl"}`))
+	}))
+	defer stub.Close()
+
+	conn, err := (&Driver{}).Open("Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint=" + stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	pinger := conn.(*Conn)
+	if err = pinger.Ping(context.Background()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(requests) != 1 || requests[0]["Target"] != "DynamoDB_20120810.ListTables" || requests[0]["Limit"] != float64(1) {
+		t.Fatalf("%s failed: expected a single ListTables request with Limit=1 but received %#v", testName, requests)
+	}
+
+	authorized = false
+	err = pinger.Ping(context.Background())
+	var awsErr *AwsError
+	if !errors.As(err, &awsErr) || awsErr.Code != "UnrecognizedClientException" {
+		t.Fatalf("%s failed: expected UnrecognizedClientException but received %#v", testName, err)
+	}
+
+	stub.Close()
+	if err = pinger.Ping(context.Background()); err == nil {
+		t.Fatalf("%s failed: ping must fail when the endpoint is unreachable", testName)
+	}
+}
+
+func TestConn_ResetSession_IsValid(t *testing.T) {
+	testName := "TestConn_ResetSession_IsValid"
+	conn := &Conn{}
+	if !conn.IsValid() {
+		t.Fatalf("%s failed: new connection must be valid", testName)
+	}
+	if _, err := conn.Begin(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	conn.txStmtList = append(conn.txStmtList, &txStmt{})
+	if !conn.IsValid() {
+		t.Fatalf("%s failed: connection with a started transaction must be valid", testName)
+	}
+	if err := conn.ResetSession(context.Background()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if conn.tx != nil || conn.txMode != txNone || conn.txStmtList != nil {
+		t.Fatalf("%s failed: transaction state must be cleared", testName)
+	}
+	if _, err := conn.Begin(); err != nil {
+		t.Fatalf("%s failed: a new transaction must be allowed after ResetSession: %s", testName, err)
+	}
+
+	for _, mode := range []txMode{txCommitting, txRollingBack} {
+		conn.txMode = mode
+		if conn.IsValid() {
+			t.Fatalf("%s failed: connection with a stuck transaction (mode %d) must not be valid", testName, mode)
+		}
+	}
+	conn.txMode = txNone
+	conn.lock.Lock()
+	if conn.IsValid() {
+		t.Fatalf("%s failed: connection being committed must not be valid", testName)
+	}
+	conn.lock.Unlock()
+}
+
+func TestConn_commit_context(t *testing.T) {
+	testName := "TestConn_commit_context"
+	numRequests := 0
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_, _ = w.Write([]byte(`{"Responses":[{}]}`))
+	}))
+	defer stub.Close()
+	conn, err := (&Driver{}).Open("Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint=" + stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	c := conn.(*Conn)
+	insert := func() {
+		stmt, err := c.Prepare(`INSERT INTO "tbl" VALUE {'id': ?}`)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		if _, err = stmt.(*StmtInsert).ExecContext(context.Background(), []driver.NamedValue{{Ordinal: 1, Value: "1"}}); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+	}
+
+	// the commit is bound to the context passed to BeginTx
+	ctx, cancel := context.WithCancel(context.Background())
+	tx, err := c.BeginTx(ctx, driver.TxOptions{})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	insert()
+	cancel()
+	if err = tx.Commit(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("%s failed: expected context.Canceled but received %#v", testName, err)
+	}
+	if numRequests != 0 {
+		t.Fatalf("%s failed: canceled transaction must not be sent to DynamoDB", testName)
+	}
+
+	if tx, err = c.BeginTx(context.Background(), driver.TxOptions{}); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	insert()
+	if err = tx.Commit(); err != nil || numRequests != 1 {
+		t.Fatalf("%s failed: expected the transaction to be committed but received %#v / %d requests", testName, err, numRequests)
+	}
+}
@@ -0,0 +1,154 @@
+package godynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Supported values of DSN setting CredentialsProvider.
+const (
+	credentialsProviderEnv    = "ENV"    // AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables
+	credentialsProviderShared = "SHARED" // shared config/credentials files, profile specified by DSN setting Profile
+	credentialsProviderStatic = "STATIC" // DSN settings AkId, Secret_Key and SessionToken
+	credentialsProviderChain  = "CHAIN"  // the AWS SDK's default credential chain
+)
+
+// credentialsKeys are the DSN settings that specify AWS credentials.
+var credentialsKeys = []string{"AKID", "SECRET_KEY", "SECRETKEY", "SESSIONTOKEN", "SESSION_TOKEN", "PROFILE",
+	"ROLEARN", "ROLE_ARN", "WEBIDENTITYTOKENFILE", "WEB_IDENTITY_TOKEN_FILE", "CREDENTIALSPROVIDER", "CREDENTIALS_PROVIDER"}
+
+// credentialsSettings holds the DSN settings related to AWS credentials.
+type credentialsSettings struct {
+	provider                    string // one of credentialsProvider* values
+	akid, secretKey, token      string
+	profile                     string
+	roleArn, externalId         string
+	roleSessionName             string
+	webIdentityTokenFile        string
+	stsEndpoint                 string
+	specifiedInConnectionString bool // true if credentials are specified in the DSN (not via environment variables)
+}
+
+// parseCredentialsSettings parses AWS credentials settings from the DSN params.
+//
+// If CredentialsProvider is not specified, it is STATIC if an access key is supplied, SHARED if a profile is specified,
+// and CHAIN otherwise.
+func parseCredentialsSettings(params map[string]string) (*credentialsSettings, error) {
+	cs := &credentialsSettings{
+		akid:                 parseStringParamValue(params, []string{"AKID"}, []string{"AWS_ACCESS_KEY_ID", "AWS_AKID"}),
+		secretKey:            parseStringParamValue(params, []string{"SECRET_KEY", "SECRETKEY"}, []string{"AWS_SECRET_KEY", "AWS_SECRET_ACCESS_KEY"}),
+		token:                parseStringParamValue(params, []string{"SESSIONTOKEN", "SESSION_TOKEN"}, nil),
+		profile:              parseStringParamValue(params, []string{"PROFILE"}, nil),
+		roleArn:              parseStringParamValue(params, []string{"ROLEARN", "ROLE_ARN"}, nil),
+		externalId:           parseStringParamValue(params, []string{"EXTERNALID", "EXTERNAL_ID"}, nil),
+		roleSessionName:      parseStringParamValue(params, []string{"ROLESESSIONNAME", "ROLE_SESSION_NAME"}, nil),
+		webIdentityTokenFile: parseStringParamValue(params, []string{"WEBIDENTITYTOKENFILE", "WEB_IDENTITY_TOKEN_FILE"}, nil),
+		stsEndpoint:          parseStringParamValue(params, []string{"STSENDPOINT", "STS_ENDPOINT"}, nil),
+		provider:             strings.ToUpper(parseStringParamValue(params, []string{"CREDENTIALSPROVIDER", "CREDENTIALS_PROVIDER"}, nil)),
+	}
+	if _, ok := params["AKID"]; !ok && cs.token == "" {
+		// the session token from the environment only goes with the access key from the environment
+		cs.token = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	for _, key := range credentialsKeys {
+		if _, ok := params[key]; ok {
+			cs.specifiedInConnectionString = true
+		}
+	}
+	switch cs.provider {
+	case credentialsProviderEnv, credentialsProviderShared, credentialsProviderStatic, credentialsProviderChain:
+	case "":
+		cs.provider = credentialsProviderChain
+		if cs.akid != "" || cs.secretKey != "" {
+			cs.provider = credentialsProviderStatic
+		} else if cs.profile != "" {
+			cs.provider = credentialsProviderShared
+		}
+	default:
+		return nil, fmt.Errorf("invalid CredentialsProvider <%s>, accepted values are env, shared, static and chain", cs.provider)
+	}
+	if cs.webIdentityTokenFile != "" && cs.roleArn == "" {
+		return nil, errors.New("WebIdentityTokenFile requires RoleArn")
+	}
+	return cs, nil
+}
+
+// credentialsProvider builds the credentials provider from the settings. The role specified by RoleArn, if any, is
+// assumed via STS using the base credentials, or using the web identity token if WebIdentityTokenFile is specified.
+func (cs *credentialsSettings) credentialsProvider(region string, httpClient aws.HTTPClient) (aws.CredentialsProvider, error) {
+	if cs.webIdentityTokenFile != "" {
+		stsClient := cs.stsClient(region, httpClient, aws.AnonymousCredentials{})
+		return aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(stsClient, cs.roleArn, stscreds.IdentityTokenFile(cs.webIdentityTokenFile),
+			func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = cs.roleSessionName
+			})), nil
+	}
+	base, err := cs.baseCredentialsProvider(region)
+	if err != nil || cs.roleArn == "" {
+		return base, err
+	}
+	stsClient := cs.stsClient(region, httpClient, base)
+	return aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, cs.roleArn, func(o *stscreds.AssumeRoleOptions) {
+		if cs.externalId != "" {
+			o.ExternalID = aws.String(cs.externalId)
+		}
+		if cs.roleSessionName != "" {
+			o.RoleSessionName = cs.roleSessionName
+		}
+	})), nil
+}
+
+func (cs *credentialsSettings) baseCredentialsProvider(region string) (aws.CredentialsProvider, error) {
+	switch cs.provider {
+	case credentialsProviderStatic:
+		if cs.akid == "" || cs.secretKey == "" {
+			return nil, errors.New("static credentials require both AkId and Secret_Key")
+		}
+		return credentials.NewStaticCredentialsProvider(cs.akid, cs.secretKey, cs.token), nil
+	case credentialsProviderEnv:
+		envConfig, err := config.NewEnvConfig()
+		if err != nil {
+			return nil, err
+		}
+		if !envConfig.Credentials.HasKeys() {
+			return nil, errors.New("no credentials found in environment variables AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		return credentials.StaticCredentialsProvider{Value: envConfig.Credentials}, nil
+	}
+	opts := []func(*config.LoadOptions) error{config.WithRegion(region)}
+	if cs.provider == credentialsProviderShared {
+		profile := cs.profile
+		if profile == "" {
+			profile = config.DefaultSharedConfigProfile
+		}
+		opts = append(opts, config.WithSharedConfigProfile(profile))
+	} else if cs.profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(cs.profile))
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Credentials, nil
+}
+
+func (cs *credentialsSettings) stsClient(region string, httpClient aws.HTTPClient, creds aws.CredentialsProvider) *sts.Client {
+	opts := sts.Options{
+		Region:      region,
+		Credentials: creds,
+		HTTPClient:  httpClient,
+	}
+	if cs.stsEndpoint != "" {
+		opts.BaseEndpoint = aws.String(cs.stsEndpoint)
+	}
+	return sts.New(opts)
+}
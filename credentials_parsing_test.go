@@ -0,0 +1,203 @@
+package godynamo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func _clearAwsCredentialsEnv(t *testing.T) {
+	for _, env := range []string{"AWS_ACCESS_KEY_ID", "AWS_AKID", "AWS_SECRET_KEY", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE"} {
+		t.Setenv(env, "")
+	}
+}
+
+func _parseConnString(t *testing.T, connStr string) map[string]string {
+	params, err := parseConnString(connStr)
+	if err != nil {
+		t.Fatalf("parseConnString(%#v) failed: %s", connStr, err)
+	}
+	return params
+}
+
+func Test_parseCredentialsSettings(t *testing.T) {
+	testName := "Test_parseCredentialsSettings"
+	_clearAwsCredentialsEnv(t)
+	testData := []struct {
+		name      string
+		connStr   string
+		provider  string
+		inDsn     bool
+		mustError bool
+	}{
+		{name: "default", connStr: "Region=us-east-1", provider: credentialsProviderChain},
+		{name: "static", connStr: "Region=us-east-1;AkId=id;Secret_Key=secret;SessionToken=token", provider: credentialsProviderStatic, inDsn: true},
+		{name: "shared", connStr: "Region=us-east-1;Profile=dev", provider: credentialsProviderShared, inDsn: true},
+		{name: "explicit", connStr: "Region=us-east-1;AkId=id;Secret_Key=secret;CredentialsProvider=env", provider: credentialsProviderEnv, inDsn: true},
+		{name: "chain_role", connStr: "Region=us-east-1;RoleArn=arn:aws:iam::123456789012:role/demo;CredentialsProvider=chain", provider: credentialsProviderChain, inDsn: true},
+		{name: "web_identity", connStr: "Region=us-east-1;RoleArn=arn:aws:iam::123456789012:role/demo;WebIdentityTokenFile=/tmp/token", provider: credentialsProviderChain, inDsn: true},
+
+		{name: "invalid_provider", connStr: "Region=us-east-1;CredentialsProvider=vault", mustError: true},
+		{name: "web_identity_no_role", connStr: "Region=us-east-1;WebIdentityTokenFile=/tmp/token", mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			cs, err := parseCredentialsSettings(_parseConnString(t, testCase.connStr))
+			if testCase.mustError {
+				if err == nil {
+					t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if cs.provider != testCase.provider || cs.specifiedInConnectionString != testCase.inDsn {
+				t.Fatalf("%s failed: expected %s/%v but received %s/%v", testName+"/"+testCase.name, testCase.provider, testCase.inDsn, cs.provider, cs.specifiedInConnectionString)
+			}
+		})
+	}
+}
+
+func Test_credentialsProvider_base(t *testing.T) {
+	testName := "Test_credentialsProvider_base"
+	_clearAwsCredentialsEnv(t)
+	dir := t.TempDir()
+	credsFile := filepath.Join(dir, "credentials")
+	_ = os.WriteFile(credsFile, []byte("[dev]\naws_access_key_id = shared-id\naws_secret_access_key = shared-secret\naws_session_token = shared-token\n"), 0600)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credsFile)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+
+	testData := []struct {
+		name      string
+		connStr   string
+		env       map[string]string
+		akid      string
+		token     string
+		mustError bool
+	}{
+		{name: "static", connStr: "AkId=static-id;Secret_Key=static-secret;SessionToken=static-token", akid: "static-id", token: "static-token"},
+		{name: "static_env_token", connStr: "CredentialsProvider=static",
+			env: map[string]string{"AWS_ACCESS_KEY_ID": "env-id", "AWS_SECRET_ACCESS_KEY": "env-secret", "AWS_SESSION_TOKEN": "env-token"}, akid: "env-id", token: "env-token"},
+		{name: "env", connStr: "CredentialsProvider=env",
+			env: map[string]string{"AWS_ACCESS_KEY_ID": "env-id", "AWS_SECRET_ACCESS_KEY": "env-secret", "AWS_SESSION_TOKEN": "env-token"}, akid: "env-id", token: "env-token"},
+		{name: "shared", connStr: "Profile=dev", akid: "shared-id", token: "shared-token"},
+
+		{name: "static_no_secret", connStr: "AkId=static-id", mustError: true},
+		{name: "env_no_keys", connStr: "CredentialsProvider=env", mustError: true},
+		{name: "shared_no_profile", connStr: "Profile=prod", mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			for k, v := range testCase.env {
+				t.Setenv(k, v)
+			}
+			cs, err := parseCredentialsSettings(_parseConnString(t, "Region=us-east-1;"+testCase.connStr))
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			var creds aws.Credentials
+			provider, err := cs.credentialsProvider("us-east-1", nil)
+			if err == nil {
+				creds, err = provider.Retrieve(context.Background())
+			}
+			if testCase.mustError {
+				if err == nil {
+					t.Fatalf("%s failed: resolving credentials must fail", testName+"/"+testCase.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if creds.AccessKeyID != testCase.akid || creds.SessionToken != testCase.token {
+				t.Fatalf("%s failed: expected %s/%s but received %s/%s", testName+"/"+testCase.name, testCase.akid, testCase.token, creds.AccessKeyID, creds.SessionToken)
+			}
+		})
+	}
+}
+
+const _stsResponseTemplate = `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>assumed-id</AccessKeyId>
+      <SecretAccessKey>assumed-secret</SecretAccessKey>
+      <SessionToken>assumed-token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::123456789012:assumed-role/demo/session</Arn>
+      <AssumedRoleId>AROA:session</AssumedRoleId>
+    </AssumedRoleUser>
+  </%[1]sResult>
+  <ResponseMetadata><RequestId>00000000-0000-0000-0000-000000000000</RequestId></ResponseMetadata>
+</%[1]sResponse>`
+
+func Test_credentialsProvider_assumeRole(t *testing.T) {
+	testName := "Test_credentialsProvider_assumeRole"
+	_clearAwsCredentialsEnv(t)
+	var requests []map[string]string
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		req := map[string]string{}
+		for _, k := range []string{"Action", "RoleArn", "ExternalId", "RoleSessionName", "WebIdentityToken"} {
+			req[k] = r.PostForm.Get(k)
+		}
+		req["Authorization"] = r.Header.Get("Authorization")
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = fmt.Fprintf(w, _stsResponseTemplate, req["Action"])
+	}))
+	defer stub.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	_ = os.WriteFile(tokenFile, []byte("web-identity-token"), 0600)
+
+	testData := []struct {
+		name     string
+		connStr  string
+		expected map[string]string
+		signed   bool
+	}{
+		{name: "assume_role", connStr: "AkId=base-id;Secret_Key=base-secret;ExternalId=ext;RoleSessionName=ci", signed: true,
+			expected: map[string]string{"Action": "AssumeRole", "RoleArn": "arn:aws:iam::123456789012:role/demo", "ExternalId": "ext", "RoleSessionName": "ci"}},
+		{name: "web_identity", connStr: "WebIdentityTokenFile=" + tokenFile + ";RoleSessionName=pod",
+			expected: map[string]string{"Action": "AssumeRoleWithWebIdentity", "RoleArn": "arn:aws:iam::123456789012:role/demo", "RoleSessionName": "pod", "WebIdentityToken": "web-identity-token"}},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			requests = nil
+			cs, err := parseCredentialsSettings(_parseConnString(t, "Region=us-east-1;RoleArn=arn:aws:iam::123456789012:role/demo;StsEndpoint="+stub.URL+";"+testCase.connStr))
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			provider, err := cs.credentialsProvider("us-east-1", nil)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			creds, err := provider.Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if creds.AccessKeyID != "assumed-id" || creds.SessionToken != "assumed-token" {
+				t.Fatalf("%s failed: unexpected credentials %#v", testName+"/"+testCase.name, creds)
+			}
+			if len(requests) != 1 {
+				t.Fatalf("%s failed: expected 1 STS request but received %d", testName+"/"+testCase.name, len(requests))
+			}
+			for k, v := range testCase.expected {
+				if requests[0][k] != v {
+					t.Fatalf("%s failed: expected %s=%#v but received %#v", testName+"/"+testCase.name, k, v, requests[0][k])
+				}
+			}
+			if signed := requests[0]["Authorization"] != ""; signed != testCase.signed {
+				t.Fatalf("%s failed: expected signed request %v but received %v", testName+"/"+testCase.name, testCase.signed, signed)
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package godynamo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// toDynamoDBJson converts an AttributeValue to its typed "DynamoDB JSON" form, e.g. {"S": "a string"} or {"N": "123"}.
+//
+// The result preserves DynamoDB data types (e.g. N vs S, sets vs lists) and can be passed to json.Marshal.
+func toDynamoDBJson(av types.AttributeValue) map[string]interface{} {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberB:
+		return map[string]interface{}{"B": v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return map[string]interface{}{"BOOL": v.Value}
+	case *types.AttributeValueMemberBS:
+		return map[string]interface{}{"BS": v.Value}
+	case *types.AttributeValueMemberL:
+		list := make([]interface{}, len(v.Value))
+		for i, e := range v.Value {
+			list[i] = toDynamoDBJson(e)
+		}
+		return map[string]interface{}{"L": list}
+	case *types.AttributeValueMemberM:
+		return map[string]interface{}{"M": itemToDynamoDBJson(v.Value)}
+	case *types.AttributeValueMemberN:
+		return map[string]interface{}{"N": v.Value}
+	case *types.AttributeValueMemberNS:
+		return map[string]interface{}{"NS": v.Value}
+	case *types.AttributeValueMemberNULL:
+		return map[string]interface{}{"NULL": true}
+	case *types.AttributeValueMemberS:
+		return map[string]interface{}{"S": v.Value}
+	case *types.AttributeValueMemberSS:
+		return map[string]interface{}{"SS": v.Value}
+	default:
+		return nil
+	}
+}
+
+// itemToDynamoDBJson converts an item to its typed "DynamoDB JSON" form.
+func itemToDynamoDBJson(item map[string]types.AttributeValue) map[string]interface{} {
+	result := make(map[string]interface{}, len(item))
+	for k, av := range item {
+		result[k] = toDynamoDBJson(av)
+	}
+	return result
+}
+
+// toPlainJson converts an AttributeValue to a value that can be passed to json.Marshal to produce "plain" JSON.
+//
+// Numbers are converted to json.Number to preserve precision, sets are converted to JSON arrays and binary values
+// are converted to []byte (which json.Marshal encodes as base64 strings).
+func toPlainJson(av types.AttributeValue) interface{} {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberB:
+		return v.Value
+	case *types.AttributeValueMemberBOOL:
+		return v.Value
+	case *types.AttributeValueMemberBS:
+		return v.Value
+	case *types.AttributeValueMemberL:
+		list := make([]interface{}, len(v.Value))
+		for i, e := range v.Value {
+			list[i] = toPlainJson(e)
+		}
+		return list
+	case *types.AttributeValueMemberM:
+		return itemToPlainJson(v.Value)
+	case *types.AttributeValueMemberN:
+		return json.Number(v.Value)
+	case *types.AttributeValueMemberNS:
+		list := make([]json.Number, len(v.Value))
+		for i, e := range v.Value {
+			list[i] = json.Number(e)
+		}
+		return list
+	case *types.AttributeValueMemberNULL:
+		return nil
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberSS:
+		return v.Value
+	default:
+		return nil
+	}
+}
+
+// itemToPlainJson converts an item to a map that can be passed to json.Marshal to produce "plain" JSON.
+func itemToPlainJson(item map[string]types.AttributeValue) map[string]interface{} {
+	result := make(map[string]interface{}, len(item))
+	for k, av := range item {
+		result[k] = toPlainJson(av)
+	}
+	return result
+}
+
+// toText converts an AttributeValue to a textual form, suitable for CSV cells.
+//
+// Strings and numbers are returned as-is, binary values are base64-encoded, NULL is converted to an empty string
+// and documents/sets are converted to plain JSON.
+func toText(av types.AttributeValue) string {
+	switch v := av.(type) {
+	case nil:
+		return ""
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	case *types.AttributeValueMemberNULL:
+		return ""
+	case *types.AttributeValueMemberBOOL:
+		if v.Value {
+			return "true"
+		}
+		return "false"
+	}
+	js, _ := json.Marshal(toPlainJson(av))
+	if len(js) > 1 && js[0] == '"' {
+		// binary value, encoded as base64 string
+		var str string
+		_ = json.Unmarshal(js, &str)
+		return str
+	}
+	return string(js)
+}
+
+// attributeTypes lists the type descriptors of DynamoDB JSON.
+var attributeTypes = map[string]bool{"B": true, "BOOL": true, "BS": true, "L": true, "M": true, "N": true, "NS": true, "NULL": true, "S": true, "SS": true}
+
+// fromDynamoDBJson converts a typed "DynamoDB JSON" value (e.g. {"S": "a string"}), decoded by a json.Decoder with
+// UseNumber enabled, to an AttributeValue.
+func fromDynamoDBJson(v interface{}) (types.AttributeValue, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return nil, fmt.Errorf("invalid DynamoDB JSON value: %v", v)
+	}
+	for typ, val := range m {
+		switch typ {
+		case "B":
+			b, err := toBytes(val)
+			return &types.AttributeValueMemberB{Value: b}, err
+		case "BOOL":
+			b, ok := val.(bool)
+			if !ok {
+				return nil, fmt.Errorf("invalid BOOL value: %v", val)
+			}
+			return &types.AttributeValueMemberBOOL{Value: b}, nil
+		case "BS":
+			list, ok := val.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid BS value: %v", val)
+			}
+			bs := make([][]byte, len(list))
+			for i, e := range list {
+				var err error
+				if bs[i], err = toBytes(e); err != nil {
+					return nil, err
+				}
+			}
+			return &types.AttributeValueMemberBS{Value: bs}, nil
+		case "L":
+			list, ok := val.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid L value: %v", val)
+			}
+			l := make([]types.AttributeValue, len(list))
+			for i, e := range list {
+				var err error
+				if l[i], err = fromDynamoDBJson(e); err != nil {
+					return nil, err
+				}
+			}
+			return &types.AttributeValueMemberL{Value: l}, nil
+		case "M":
+			doc, ok := val.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid M value: %v", val)
+			}
+			item, err := itemFromDynamoDBJson(doc)
+			return &types.AttributeValueMemberM{Value: item}, err
+		case "N":
+			n, err := toNumberString(val)
+			return &types.AttributeValueMemberN{Value: n}, err
+		case "NS":
+			list, ok := val.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid NS value: %v", val)
+			}
+			ns := make([]string, len(list))
+			for i, e := range list {
+				var err error
+				if ns[i], err = toNumberString(e); err != nil {
+					return nil, err
+				}
+			}
+			return &types.AttributeValueMemberNS{Value: ns}, nil
+		case "NULL":
+			return &types.AttributeValueMemberNULL{Value: true}, nil
+		case "S":
+			s, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid S value: %v", val)
+			}
+			return &types.AttributeValueMemberS{Value: s}, nil
+		case "SS":
+			list, ok := val.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid SS value: %v", val)
+			}
+			ss := make([]string, len(list))
+			for i, e := range list {
+				if ss[i], ok = e.(string); !ok {
+					return nil, fmt.Errorf("invalid SS element: %v", e)
+				}
+			}
+			return &types.AttributeValueMemberSS{Value: ss}, nil
+		}
+		return nil, fmt.Errorf("invalid DynamoDB JSON type <%s>", typ)
+	}
+	return nil, nil
+}
+
+// itemFromDynamoDBJson converts an item in typed "DynamoDB JSON" form to a map of AttributeValues.
+func itemFromDynamoDBJson(doc map[string]interface{}) (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, len(doc))
+	for k, v := range doc {
+		av, err := fromDynamoDBJson(v)
+		if err != nil {
+			return nil, fmt.Errorf("attribute <%s>: %s", k, err)
+		}
+		item[k] = av
+	}
+	return item, nil
+}
+
+// fromPlainJson converts a "plain" JSON value, decoded by a json.Decoder with UseNumber enabled, to an AttributeValue.
+//
+// Without type hint, JSON numbers become N, strings become S, arrays become L and objects become M. typeHint (one of
+// the DynamoDB JSON type descriptors, e.g. "N", "SS") overrides the conversion, e.g. a string "123" with hint "N" becomes
+// a number, and an array with hint "SS" becomes a string set.
+func fromPlainJson(v interface{}, typeHint string) (types.AttributeValue, error) {
+	if v == nil {
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	}
+	switch typeHint {
+	case "":
+		switch val := v.(type) {
+		case bool:
+			return &types.AttributeValueMemberBOOL{Value: val}, nil
+		case json.Number:
+			return &types.AttributeValueMemberN{Value: val.String()}, nil
+		case string:
+			return &types.AttributeValueMemberS{Value: val}, nil
+		case []interface{}:
+			l := make([]types.AttributeValue, len(val))
+			for i, e := range val {
+				var err error
+				if l[i], err = fromPlainJson(e, ""); err != nil {
+					return nil, err
+				}
+			}
+			return &types.AttributeValueMemberL{Value: l}, nil
+		case map[string]interface{}:
+			m := make(map[string]types.AttributeValue, len(val))
+			for k, e := range val {
+				var err error
+				if m[k], err = fromPlainJson(e, ""); err != nil {
+					return nil, err
+				}
+			}
+			return &types.AttributeValueMemberM{Value: m}, nil
+		}
+		return nil, fmt.Errorf("unsupported JSON value: %v", v)
+	case "S":
+		if s, ok := v.(string); ok {
+			return &types.AttributeValueMemberS{Value: s}, nil
+		}
+		return &types.AttributeValueMemberS{Value: fmt.Sprintf("%v", v)}, nil
+	case "BOOL":
+		if s, ok := v.(string); ok {
+			b, err := strconv.ParseBool(s)
+			return &types.AttributeValueMemberBOOL{Value: b}, err
+		}
+	case "NULL":
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case "L", "M":
+		if s, ok := v.(string); ok {
+			// nested document, encoded as JSON string
+			decoder := json.NewDecoder(strings.NewReader(s))
+			decoder.UseNumber()
+			if err := decoder.Decode(&v); err != nil {
+				return nil, fmt.Errorf("invalid %s value: %s", typeHint, err)
+			}
+		}
+		av, err := fromPlainJson(v, "")
+		if err == nil && nameFromAttributeValue(av) != typeHint {
+			err = fmt.Errorf("invalid %s value: %v", typeHint, v)
+		}
+		return av, err
+	case "SS", "NS", "BS":
+		if s, ok := v.(string); ok {
+			// set, encoded as JSON array
+			decoder := json.NewDecoder(strings.NewReader(s))
+			decoder.UseNumber()
+			if err := decoder.Decode(&v); err != nil {
+				return nil, fmt.Errorf("invalid %s value: %s", typeHint, err)
+			}
+		}
+	}
+	if _, ok := attributeTypes[typeHint]; !ok {
+		return nil, fmt.Errorf("invalid type hint <%s>", typeHint)
+	}
+	if typeHint == "SS" {
+		if list, ok := v.([]interface{}); ok {
+			for i, e := range list {
+				if _, ok := e.(string); !ok {
+					list[i] = fmt.Sprintf("%v", e)
+				}
+			}
+		}
+	}
+	// B, N and sets share the same representation as DynamoDB JSON
+	return fromDynamoDBJson(map[string]interface{}{typeHint: v})
+}
+
+func toBytes(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid binary value: %v", v)
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+func toNumberString(v interface{}) (string, error) {
+	var s string
+	switch val := v.(type) {
+	case json.Number:
+		s = val.String()
+	case string:
+		s = strings.TrimSpace(val)
+	default:
+		return "", fmt.Errorf("invalid number value: %v", v)
+	}
+	if _, ok := new(big.Float).SetString(s); !ok {
+		return "", fmt.Errorf("invalid number value: %v", v)
+	}
+	return s, nil
+}
@@ -3,16 +3,16 @@ package godynamo
 import (
 	"database/sql"
 	"database/sql/driver"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"os"
-	"reflect"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws/transport/http"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go/middleware"
 	"github.com/btnguyen2k/consu/reddo"
 )
 
@@ -25,45 +25,8 @@ func init() {
 type Driver struct {
 }
 
-func parseParamValue(params map[string]string, typ reflect.Type, validator func(val interface{}) bool,
-	defaultVal interface{}, pkeys []string, ekeys []string) interface{} {
-	for _, key := range pkeys {
-		val, ok := params[key]
-		if ok {
-			pval, err := reddo.Convert(val, typ)
-			if pval == nil || err != nil || (validator != nil && !validator(pval)) {
-				return defaultVal
-			}
-			return pval
-		}
-	}
-	for _, key := range ekeys {
-		val := os.Getenv(key)
-		if val != "" {
-			pval, err := reddo.Convert(val, typ)
-			if pval == nil || err != nil || (validator != nil && !validator(pval)) {
-				return defaultVal
-			}
-			return pval
-		}
-	}
-	return defaultVal
-}
-
-func parseConnString(connStr string) map[string]string {
-	params := make(map[string]string)
-	parts := strings.Split(connStr, ";")
-	for _, part := range parts {
-		tokens := strings.SplitN(strings.TrimSpace(part), "=", 2)
-		key := strings.ToUpper(strings.TrimSpace(tokens[0]))
-		if len(tokens) >= 2 {
-			params[key] = strings.TrimSpace(tokens[1])
-		} else {
-			params[key] = ""
-		}
-	}
-	return params
-}
+// reTablePrefix validates DSN setting TablePrefix against the characters allowed in DynamoDB table names.
+var reTablePrefix = regexp.MustCompile(`^[\w\-\.]*$`)
 
 // Open implements driver.Driver/Open.
 //
@@ -71,21 +34,67 @@ func parseConnString(connStr string) map[string]string {
 //
 //	Region=<region>;AkId=<aws-key-id>;Secret_Key=<aws-secret-key>[;Endpoint=<dynamodb-endpoint>][;TimeoutMs=<timeout-in-milliseconds>]
 //
-// If not supplied, default value for TimeoutMs is 10 seconds.
+// If not supplied, default value for TimeoutMs is 10 seconds. Since v1.4.0, TimeoutMs bounds each statement's
+// execution, unless the statement specifies clause WITH TIMEOUT=<ms>; deadlines of the caller's contexts are honored.
+// IMPORT and EXPORT statements are bounded by clause WITH TIMEOUT only, TimeoutMs bounds each of their requests.
+//
+// Since v1.4.0, connStr is validated strictly: unknown, duplicated or malformed settings and invalid values are
+// reported as errors instead of being ignored. Values containing semicolons can be enclosed in double or single quotes
+// (the quote character is escaped by doubling it), e.g. SessionToken="abc;def". TimeoutMs (alias Timeout) also accepts
+// durations such as 5s. connStr can also be a URL, whose user name and password are AkId and Secret_Key, whose host is
+// the HTTP Endpoint, and whose query parameters are settings:
+//
+//	dynamodb://<aws-key-id>:<aws-secret-key>@localhost:8000/?region=us-east-1&timeout=5s
+//
+// Since v1.4.0, the following optional settings are supported:
+//   - AllowScan=<true/false>: if false, SELECT statements that would run as full table/index scans are refused with
+//     an error wrapping ErrScanNotAllowed, unless the statement specifies WITH ALLOW_SCAN=true. Default value is true.
+//   - NumberMode=<float/json.Number/string/big>: how N attributes (including numbers nested inside M and L attributes)
+//     are returned in result sets: as float64 (default), json.Number, string or *BigNumber. See NumberModeFloat.
+//   - Strict=<true/false>: if true, failed condition checks of UPDATE/DELETE statements are returned as
+//     ConditionFailedError (carrying the current item) instead of 0 affected row. A statement can override this setting
+//     with clause WITH STRICT=<true/false>. Default value is false.
+//   - SessionToken=<token>: session token of temporary credentials, used together with AkId and Secret_Key.
+//   - Profile=<name>: load credentials from the named profile of the shared config/credentials files.
+//   - RoleArn=<arn>[;ExternalId=<id>][;RoleSessionName=<name>]: assume the role via STS, using the resolved
+//     credentials (or the web identity token, see below). StsEndpoint=<url> overrides the STS endpoint.
+//   - WebIdentityTokenFile=<path>: assume RoleArn with the web identity token read from the file.
+//   - CredentialsProvider=<env/shared/static/chain>: where the base credentials come from: environment variables,
+//     shared config/credentials files, AkId/Secret_Key/SessionToken, or the AWS SDK's default credential chain.
+//     If not specified, static is used if AkId or Secret_Key is supplied, shared if Profile is specified, and chain
+//     otherwise.
+//   - AwsConfig=<name>: use the aws.Config registered via RegisterNamedAWSConfig under the name, instead of the one
+//     registered via RegisterAWSConfig. Settings explicitly specified in the DSN (Region, Endpoint, credentials) take
+//     precedence over the named aws.Config's values. Opening with an unknown name returns an error.
+//   - TablePrefix=<prefix>: prefix of the physical table names. Table names of all statements (including PartiQL
+//     INSERT INTO/FROM/UPDATE/DELETE FROM targets) are prefixed transparently; LIST TABLES only lists the tables with
+//     the prefix, and DESCRIBE TABLE returns logical table names.
+//   - ReadOnly=<true/false>: if true, statements that may modify tables or data (CREATE/ALTER/DROP TABLE and GSI,
+//     INSERT, UPDATE, DELETE, IMPORT...) are rejected with ErrReadOnly; SELECT, DESCRIBE, LIST, EXPLAIN and EXPORT are
+//     allowed. Transactions started with sql.TxOptions.ReadOnly are restricted the same way. Default value is false.
+//   - StatementCacheSize=<n>: number of parsed statements cached (LRU, keyed by query text) and shared by connections
+//     opened with the same DSN, 0 disables the cache. Default value is DefaultStatementCacheSize. See
+//     Conn.StatementCacheStats.
 func (d *Driver) Open(connStr string) (driver.Conn, error) {
-	params := parseConnString(connStr)
-	timeoutMs := parseParamValue(params, reddo.TypeInt, func(val interface{}) bool {
-		return val.(int64) >= 0
-	}, int64(10000), []string{"TIMEOUTMS"}, nil).(int64)
-	region := parseParamValue(params, reddo.TypeString, nil, "", []string{"REGION"}, []string{"AWS_REGION"}).(string)
-	akid := parseParamValue(params, reddo.TypeString, nil, "", []string{"AKID"}, []string{"AWS_ACCESS_KEY_ID", "AWS_AKID"}).(string)
-	secretKey := parseParamValue(params, reddo.TypeString, nil, "", []string{"SECRET_KEY", "SECRETKEY"}, []string{"AWS_SECRET_KEY", "AWS_SECRET_ACCESS_KEY"}).(string)
+	params, err := parseConnString(connStr)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := parseTimeoutParamValue(params, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	region := parseStringParamValue(params, []string{"REGION"}, []string{"AWS_REGION"})
+	credsSettings, err := parseCredentialsSettings(params)
+	if err != nil {
+		return nil, err
+	}
 	opts := dynamodb.Options{
-		Credentials: credentials.NewStaticCredentialsProvider(akid, secretKey, ""),
-		HTTPClient:  http.NewBuildableClient().WithTimeout(time.Millisecond * time.Duration(timeoutMs)),
-		Region:      region,
+		HTTPClient: http.NewBuildableClient().WithTimeout(timeout),
+		Region:     region,
+		APIOptions: []func(*middleware.Stack) error{addRetryHooksMiddlewares},
 	}
-	endpoint := parseParamValue(params, reddo.TypeString, nil, "", []string{"ENDPOINT"}, []string{"AWS_DYNAMODB_ENDPOINT"}).(string)
+	endpoint := parseStringParamValue(params, []string{"ENDPOINT"}, []string{"AWS_DYNAMODB_ENDPOINT"})
 	if endpoint != "" {
 		//opts.EndpointResolver = dynamodb.EndpointResolverFromURL(endpoint)
 		opts.BaseEndpoint = aws.String(endpoint)
@@ -93,22 +102,73 @@ func (d *Driver) Open(connStr string) (driver.Conn, error) {
 			opts.EndpointOptions.DisableHTTPS = true
 		}
 	}
-	client := dynamodb.New(opts)
 
-	awsConfigLock.RLock()
-	defer awsConfigLock.RUnlock()
-	conf := awsConfig
+	conf, overrides, err := resolveAWSConfig(params, credsSettings)
+	if err != nil {
+		return nil, err
+	}
+	if conf == nil || conf.Credentials == nil || overrides.credentials {
+		// credentials specified in the DSN take precedence over the ones of the registered aws.Config
+		credsRegion := region
+		if credsRegion == "" && conf != nil {
+			credsRegion = conf.Region
+		}
+		if opts.Credentials, err = credsSettings.credentialsProvider(credsRegion, opts.HTTPClient); err != nil {
+			return nil, err
+		}
+	}
+	client := dynamodb.New(opts)
 	if conf != nil {
-		client = dynamodb.NewFromConfig(*conf, mergeDynamoDBOptions(opts))
+		client = dynamodb.NewFromConfig(*conf, mergeDynamoDBOptions(opts, overrides))
 	}
 
-	return &Conn{client: client, timeout: time.Duration(timeoutMs) * time.Millisecond}, nil
+	numberMode, err := normalizeNumberMode(parseStringParamValue(params, []string{"NUMBERMODE", "NUMBER_MODE"}, nil))
+	if err != nil {
+		return nil, err
+	}
+	tablePrefix := parseStringParamValue(params, []string{"TABLEPREFIX", "TABLE_PREFIX"}, nil)
+	if !reTablePrefix.MatchString(tablePrefix) {
+		return nil, fmt.Errorf("invalid value <%s> of DSN setting TablePrefix, only letters, digits, '_', '-' and '.' are allowed", tablePrefix)
+	}
+	allowScan, err := parseParamValue(params, reddo.TypeBool, nil, true, []string{"ALLOWSCAN", "ALLOW_SCAN"}, nil)
+	if err != nil {
+		return nil, err
+	}
+	strict, err := parseParamValue(params, reddo.TypeBool, nil, false, []string{"STRICT"}, nil)
+	if err != nil {
+		return nil, err
+	}
+	readOnly, err := parseParamValue(params, reddo.TypeBool, nil, false, []string{"READONLY", "READ_ONLY"}, nil)
+	if err != nil {
+		return nil, err
+	}
+	stmtCacheSize, err := parseParamValue(params, reddo.TypeInt, func(val interface{}) bool { return val.(int64) >= 0 },
+		int64(DefaultStatementCacheSize), []string{"STATEMENTCACHESIZE", "STATEMENT_CACHE_SIZE"}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := dsnCacheKey(params)
+	return &Conn{
+		client:            client,
+		timeout:           timeout,
+		numberMode:        numberMode,
+		disallowScan:      !allowScan.(bool),
+		strict:            strict.(bool),
+		tableDescriptions: tableDescriptionCacheFor(cacheKey),
+		tablePrefix:       tablePrefix,
+		readOnly:          readOnly.(bool),
+		stmtCache:         stmtCacheFor(cacheKey, int(stmtCacheSize.(int64))),
+	}, nil
 }
 
 // awsConfig is the AWS configuration to be used by the dynamodb client.
+//
+// @Since v1.4.0 named aws.Configs are stored in awsConfigs.
 var (
 	awsConfigLock = &sync.RWMutex{}
 	awsConfig     *aws.Config
+	awsConfigs    = make(map[string]*aws.Config)
 )
 
 // RegisterAWSConfig registers aws.Config to be used by the dynamodb client.
@@ -117,6 +177,10 @@ var (
 //   - HTTPClient
 //
 // @Available since v1.3.0
+//
+// @Since v1.4.0 settings explicitly specified in the DSN (Region, Endpoint, credentials such as AkId, Profile, RoleArn,
+// etc.) take precedence over the aws.Config's values. The aws.Config is not used by DSNs that select a named aws.Config
+// via AwsConfig=<name>.
 func RegisterAWSConfig(conf aws.Config) {
 	awsConfigLock.Lock()
 	defer awsConfigLock.Unlock()
@@ -132,18 +196,74 @@ func DeregisterAWSConfig() {
 	awsConfig = nil
 }
 
+// RegisterNamedAWSConfig registers aws.Config under a name, to be selected by DSN setting AwsConfig=<name>.
+// Registering under an existing name replaces the previous aws.Config.
+//
+// As with the aws.Config registered via RegisterAWSConfig, settings explicitly specified in the DSN (Region, Endpoint,
+// credentials) take precedence over the named aws.Config's values. HTTPClient does not apply.
+//
+// @Available since v1.4.0
+func RegisterNamedAWSConfig(name string, conf aws.Config) {
+	awsConfigLock.Lock()
+	defer awsConfigLock.Unlock()
+	awsConfigs[name] = &conf
+}
+
+// DeregisterNamedAWSConfig removes the aws.Config registered under the name.
+//
+// @Available since v1.4.0
+func DeregisterNamedAWSConfig(name string) {
+	awsConfigLock.Lock()
+	defer awsConfigLock.Unlock()
+	delete(awsConfigs, name)
+}
+
+// dynamodbOptionsOverrides tells which dynamodb.Options built from the DSN take precedence over the aws.Config's values.
+//
+// @Available since v1.4.0
+type dynamodbOptionsOverrides struct {
+	region, endpoint, credentials bool
+}
+
+// resolveAWSConfig returns the registered aws.Config to be used by the DSN (nil if none), along with the options
+// overridden by the DSN.
+//
+// @Available since v1.4.0
+func resolveAWSConfig(params map[string]string, credsSettings *credentialsSettings) (*aws.Config, dynamodbOptionsOverrides, error) {
+	overrides := dynamodbOptionsOverrides{credentials: credsSettings.specifiedInConnectionString}
+	_, overrides.region = params["REGION"]
+	_, overrides.endpoint = params["ENDPOINT"]
+	name := parseStringParamValue(params, []string{"AWSCONFIG", "AWS_CONFIG"}, nil)
+	awsConfigLock.RLock()
+	defer awsConfigLock.RUnlock()
+	if name == "" {
+		return awsConfig, overrides, nil
+	}
+	conf, ok := awsConfigs[name]
+	if !ok {
+		return nil, overrides, fmt.Errorf("no aws.Config registered with name <%s>", name)
+	}
+	return conf, overrides, nil
+}
+
 // mergeDynamoDBOptions merges the provided dynamodb.Options into the default dynamodb.Options.
-func mergeDynamoDBOptions(providedOpts dynamodb.Options) func(*dynamodb.Options) {
+//
+// The provided options fill in the values missing from the default options, except for the ones marked in overrides,
+// which replace the default values. HTTPClient is always replaced, APIOptions are appended.
+//
+// @Since v1.4.0 added param overrides.
+func mergeDynamoDBOptions(providedOpts dynamodb.Options, overrides dynamodbOptionsOverrides) func(*dynamodb.Options) {
 	return func(defaultOpts *dynamodb.Options) {
-		if defaultOpts.Region == "" {
+		if defaultOpts.Region == "" || (overrides.region && providedOpts.Region != "") {
 			defaultOpts.Region = providedOpts.Region
 		}
-		if defaultOpts.Credentials == nil {
+		if defaultOpts.Credentials == nil || overrides.credentials {
 			defaultOpts.Credentials = providedOpts.Credentials
 		}
 		defaultOpts.HTTPClient = providedOpts.HTTPClient
+		defaultOpts.APIOptions = append(defaultOpts.APIOptions, providedOpts.APIOptions...)
 
-		if defaultOpts.BaseEndpoint == nil {
+		if defaultOpts.BaseEndpoint == nil || (overrides.endpoint && providedOpts.BaseEndpoint != nil) {
 			defaultOpts.BaseEndpoint = providedOpts.BaseEndpoint
 			defaultOpts.EndpointOptions = providedOpts.EndpointOptions
 		}
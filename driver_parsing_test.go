@@ -1,6 +1,10 @@
 package godynamo
 
 import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/btnguyen2k/consu/reddo"
 	"os"
 	"reflect"
@@ -16,6 +20,7 @@ func Test_parseConnString_parseParamValue(t *testing.T) {
 		defaultValue  interface{}
 		expectedValue interface{}
 		validator     func(val interface{}) bool
+		mustError     bool
 	}
 
 	_ = os.Setenv("ENV_TIMEOUT", "1234")
@@ -60,9 +65,9 @@ func Test_parseConnString_parseParamValue(t *testing.T) {
 					validator: func(val interface{}) bool {
 						return val.(int64) >= 0
 					},
-					paramType:     reddo.TypeInt,
-					defaultValue:  int64(12345),
-					expectedValue: int64(12345),
+					paramType:    reddo.TypeInt,
+					defaultValue: int64(12345),
+					mustError:    true,
 				},
 			},
 		},
@@ -91,10 +96,10 @@ func Test_parseConnString_parseParamValue(t *testing.T) {
 			},
 			paramValues: []paramValueStruct{
 				{
-					pnames:        []string{"T", "TIMEOUT"},
-					paramType:     reddo.TypeInt,
-					defaultValue:  int64(1234),
-					expectedValue: int64(1234),
+					pnames:       []string{"T", "TIMEOUT"},
+					paramType:    reddo.TypeInt,
+					defaultValue: int64(1234),
+					mustError:    true,
 					validator: func(val interface{}) bool {
 						return val.(int64) >= 0
 					},
@@ -104,16 +109,97 @@ func Test_parseConnString_parseParamValue(t *testing.T) {
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			params := parseConnString(testCase.connStr)
-			if !reflect.DeepEqual(params, testCase.expectedParams) {
-				t.Fatalf("%s failed: expected %#v received %#v", testName+"/"+testCase.name, testCase.expectedParams, params)
+			params, err := parseConnString(testCase.connStr)
+			if err != nil || !reflect.DeepEqual(params, testCase.expectedParams) {
+				t.Fatalf("%s failed: expected %#v received %#v/%s", testName+"/"+testCase.name, testCase.expectedParams, params, err)
 			}
 			for _, paramValue := range testCase.paramValues {
-				val := parseParamValue(params, paramValue.paramType, paramValue.validator, paramValue.defaultValue, paramValue.pnames, paramValue.enames)
-				if !reflect.DeepEqual(val, paramValue.expectedValue) {
+				val, err := parseParamValue(params, paramValue.paramType, paramValue.validator, paramValue.defaultValue, paramValue.pnames, paramValue.enames)
+				if paramValue.mustError {
+					if err == nil {
+						t.Fatalf("%s failed: <%s> parsing must fail", testName+"/"+testCase.name, paramValue.pnames)
+					}
+					continue
+				}
+				if err != nil || !reflect.DeepEqual(val, paramValue.expectedValue) {
 					t.Fatalf("%s failed: <%s> expected %#v received %#v", testName+"/"+testCase.name, paramValue.pnames, paramValue.expectedValue, val)
 				}
 			}
 		})
 	}
 }
+
+func Test_mergeDynamoDBOptions(t *testing.T) {
+	testName := "Test_mergeDynamoDBOptions"
+	dsnCreds := credentials.NewStaticCredentialsProvider("dsn", "dsn", "")
+	cfgCreds := credentials.NewStaticCredentialsProvider("cfg", "cfg", "")
+	provided := dynamodb.Options{Region: "dsn-region", BaseEndpoint: aws.String("http://dsn:8000"), Credentials: dsnCreds}
+	testData := []struct {
+		name      string
+		config    aws.Config
+		overrides dynamodbOptionsOverrides
+		region    string
+		endpoint  string
+		akid      string
+	}{
+		{name: "empty_config", config: aws.Config{}, region: "dsn-region", endpoint: "http://dsn:8000", akid: "dsn"},
+		{name: "config_wins", config: aws.Config{Region: "cfg-region", BaseEndpoint: aws.String("http://cfg:8000"), Credentials: cfgCreds},
+			region: "cfg-region", endpoint: "http://cfg:8000", akid: "cfg"},
+		{name: "dsn_wins", config: aws.Config{Region: "cfg-region", BaseEndpoint: aws.String("http://cfg:8000"), Credentials: cfgCreds},
+			overrides: dynamodbOptionsOverrides{region: true, endpoint: true, credentials: true}, region: "dsn-region", endpoint: "http://dsn:8000", akid: "dsn"},
+		{name: "credentials_only", config: aws.Config{Region: "cfg-region", BaseEndpoint: aws.String("http://cfg:8000"), Credentials: cfgCreds},
+			overrides: dynamodbOptionsOverrides{credentials: true}, region: "cfg-region", endpoint: "http://cfg:8000", akid: "dsn"},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts := dynamodb.NewFromConfig(testCase.config, mergeDynamoDBOptions(provided, testCase.overrides)).Options()
+			creds, _ := opts.Credentials.Retrieve(context.Background())
+			if opts.Region != testCase.region || aws.ToString(opts.BaseEndpoint) != testCase.endpoint || creds.AccessKeyID != testCase.akid {
+				t.Fatalf("%s failed: expected %s/%s/%s but received %s/%s/%s", testName+"/"+testCase.name, testCase.region, testCase.endpoint, testCase.akid,
+					opts.Region, aws.ToString(opts.BaseEndpoint), creds.AccessKeyID)
+			}
+		})
+	}
+}
+
+func TestDriver_Open_namedAWSConfig(t *testing.T) {
+	testName := "TestDriver_Open_namedAWSConfig"
+	RegisterAWSConfig(aws.Config{Region: "default-region", BaseEndpoint: aws.String("http://default:8000")})
+	defer DeregisterAWSConfig()
+	RegisterNamedAWSConfig("prod", aws.Config{Region: "prod-region", BaseEndpoint: aws.String("http://prod:8000")})
+	defer DeregisterNamedAWSConfig("prod")
+
+	testData := []struct {
+		name      string
+		dsn       string
+		region    string
+		endpoint  string
+		mustError bool
+	}{
+		{name: "default", dsn: "AkId=id;Secret_Key=secret", region: "default-region", endpoint: "http://default:8000"},
+		{name: "default_dsn_wins", dsn: "Region=us-east-1;Endpoint=http://localhost:8000;AkId=id;Secret_Key=secret",
+			region: "us-east-1", endpoint: "http://localhost:8000"},
+		{name: "named", dsn: "AwsConfig=prod;AkId=id;Secret_Key=secret", region: "prod-region", endpoint: "http://prod:8000"},
+		{name: "named_dsn_wins", dsn: "AwsConfig=prod;Region=us-east-1;Endpoint=http://localhost:8000;AkId=id;Secret_Key=secret",
+			region: "us-east-1", endpoint: "http://localhost:8000"},
+		{name: "unknown", dsn: "AwsConfig=staging;AkId=id;Secret_Key=secret", mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			conn, err := (&Driver{}).Open(testCase.dsn)
+			if testCase.mustError {
+				if err == nil {
+					t.Fatalf("%s failed: opening must fail", testName+"/"+testCase.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			opts := conn.(*Conn).client.Options()
+			if opts.Region != testCase.region || aws.ToString(opts.BaseEndpoint) != testCase.endpoint {
+				t.Fatalf("%s failed: expected %s/%s but received %s/%s", testName+"/"+testCase.name, testCase.region, testCase.endpoint, opts.Region, aws.ToString(opts.BaseEndpoint))
+			}
+		})
+	}
+}
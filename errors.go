@@ -0,0 +1,156 @@
+package godynamo
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/smithy-go"
+)
+
+var (
+	// ErrTableNotFound is matched (via errors.Is) by errors returned when the table (or another resource, such as the
+	// index of a GSI statement) does not exist, AWS error code ResourceNotFoundException.
+	//
+	// @Available since v1.4.0
+	ErrTableNotFound = errors.New("table not found")
+
+	// ErrTableExists is matched (via errors.Is) by errors returned by CREATE TABLE when the table already exists.
+	//
+	// @Available since v1.4.0
+	ErrTableExists = errors.New("table already exists")
+
+	// ErrIndexExists is matched (via errors.Is) by errors returned by CREATE GSI when the index already exists.
+	//
+	// @Available since v1.4.0
+	ErrIndexExists = errors.New("index already exists")
+
+	// ErrDuplicateItem is matched (via errors.Is) by errors returned by INSERT when the item already exists, AWS error
+	// code DuplicateItemException.
+	//
+	// @Available since v1.4.0
+	ErrDuplicateItem = errors.New("item already exists")
+
+	// ErrThrottled is matched (via errors.Is) by errors returned when requests are throttled by DynamoDB (after the AWS
+	// SDK gave up retrying), AWS error codes ProvisionedThroughputExceededException, ThrottlingException and
+	// RequestLimitExceeded.
+	//
+	// @Available since v1.4.0
+	ErrThrottled = errors.New("request throttled")
+
+	// ErrValidation is matched (via errors.Is) by errors returned when DynamoDB rejects a request as invalid, AWS error
+	// code ValidationException.
+	//
+	// @Available since v1.4.0
+	ErrValidation = errors.New("validation error")
+)
+
+// awsErrorKinds maps AWS error codes to the sentinel errors matched by AwsError.
+var awsErrorKinds = map[string]error{
+	"ResourceNotFoundException":              ErrTableNotFound,
+	"ConditionalCheckFailedException":        ErrConditionFailed,
+	"DuplicateItemException":                 ErrDuplicateItem,
+	"ProvisionedThroughputExceededException": ErrThrottled,
+	"ThrottlingException":                    ErrThrottled,
+	"RequestLimitExceeded":                   ErrThrottled,
+	"ValidationException":                    ErrValidation,
+}
+
+// AwsError wraps an error returned by the AWS SDK. errors.Is reports true for the sentinel error matching the error
+// code (e.g. ErrTableNotFound for ResourceNotFoundException), and errors.As can still be used to access the SDK's error
+// types (e.g. *types.ResourceNotFoundException) or *smithy.OperationError.
+//
+// @Available since v1.4.0
+type AwsError struct {
+	Code string // the AWS error code, see AwsErrorCode
+	Kind error  // the sentinel error matching the error, e.g. ErrTableNotFound; nil if none applies
+	Err  error  // the error returned by the AWS SDK
+}
+
+// Error implements error/Error.
+func (e *AwsError) Error() string {
+	return e.Err.Error()
+}
+
+// Is reports true if target is the sentinel error matching the error.
+func (e *AwsError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// Unwrap returns the error returned by the AWS SDK.
+func (e *AwsError) Unwrap() error {
+	return e.Err
+}
+
+// Message returns the error message returned by DynamoDB.
+func (e *AwsError) Message() string {
+	var apiErr smithy.APIError
+	if errors.As(e.Err, &apiErr) {
+		return apiErr.ErrorMessage()
+	}
+	return ""
+}
+
+// wrapAwsError wraps an error returned by the AWS SDK into an *AwsError. kinds maps AWS error codes to the sentinel
+// errors specific to the operation, which take precedence over the default ones. Other errors (nil included) are
+// returned as-is.
+func wrapAwsError(err error, kinds map[string]error) error {
+	var awsErr *AwsError
+	if err == nil || errors.As(err, &awsErr) {
+		return err
+	}
+	code := AwsErrorCode(err)
+	if code == "" {
+		return err
+	}
+	kind, ok := kinds[code]
+	if !ok {
+		kind = awsErrorKinds[code]
+	}
+	return &AwsError{Code: code, Kind: kind, Err: err}
+}
+
+/*----------------------------------------------------------------------*/
+
+// ParseError is returned when a statement cannot be parsed. errors.As can be used to access the statement and the
+// position of the error.
+//
+// @Available since v1.4.0
+type ParseError struct {
+	Statement string // the statement being parsed
+	Position  int    // position (0-based, in runes) in the statement where the error was detected, -1 if unknown
+	Err       error  // the parsing error
+}
+
+// Error implements error/Error.
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the parsing error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError wraps err into a *ParseError of the statement. If err wraps a *ParseError (e.g. an error of the PartiQL
+// parser), its statement and position are kept.
+func newParseError(statement string, position int, err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		if parseErr == err {
+			return err
+		}
+		statement, position = parseErr.Statement, parseErr.Position
+	}
+	return &ParseError{Statement: statement, Position: position, Err: err}
+}
+
+// wrapCreateIndexError wraps an error returned by the AWS SDK when creating an index. DynamoDB reports that the index
+// already exists either as ResourceInUseException or as ValidationException, the latter is distinguished by its message.
+func wrapCreateIndexError(err error) error {
+	err = wrapAwsError(err, map[string]error{"ResourceInUseException": ErrIndexExists})
+	var awsErr *AwsError
+	if errors.As(err, &awsErr) && awsErr.Code == "ValidationException" && strings.Contains(awsErr.Message(), "already exist") {
+		awsErr.Kind = ErrIndexExists
+	}
+	return err
+}
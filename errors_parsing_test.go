@@ -0,0 +1,133 @@
+package godynamo
+
+import (
+	"errors"
+	"testing"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func _awsError(err error) error {
+	return &smithy.OperationError{ServiceID: "DynamoDB", OperationName: "ExecuteStatement",
+		Err: &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{Err: err}}}
+}
+
+func Test_wrapAwsError(t *testing.T) {
+	testName := "Test_wrapAwsError"
+	testData := []struct {
+		name  string
+		err   error
+		kinds map[string]error
+		code  string
+		kind  error
+	}{
+		{name: "table_not_found", err: _awsError(&types.ResourceNotFoundException{}), code: "ResourceNotFoundException", kind: ErrTableNotFound},
+		{name: "condition_failed", err: _awsError(&types.ConditionalCheckFailedException{}), code: "ConditionalCheckFailedException", kind: ErrConditionFailed},
+		{name: "duplicate_item", err: _awsError(&types.DuplicateItemException{}), code: "DuplicateItemException", kind: ErrDuplicateItem},
+		{name: "throttled", err: _awsError(&types.ProvisionedThroughputExceededException{}), code: "ProvisionedThroughputExceededException", kind: ErrThrottled},
+		{name: "throttled_generic", err: _awsError(&smithy.GenericAPIError{Code: "ThrottlingException"}), code: "ThrottlingException", kind: ErrThrottled},
+		{name: "validation", err: _awsError(&smithy.GenericAPIError{Code: "ValidationException"}), code: "ValidationException", kind: ErrValidation},
+		{name: "table_exists", err: _awsError(&types.ResourceInUseException{}), kinds: map[string]error{"ResourceInUseException": ErrTableExists},
+			code: "ResourceInUseException", kind: ErrTableExists},
+		{name: "no_kind", err: _awsError(&types.ResourceInUseException{}), code: "ResourceInUseException"},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := wrapAwsError(testCase.err, testCase.kinds)
+			var awsErr *AwsError
+			if !errors.As(err, &awsErr) || awsErr.Code != testCase.code || awsErr.Kind != testCase.kind {
+				t.Fatalf("%s failed: expected %s/%v but received %#v", testName+"/"+testCase.name, testCase.code, testCase.kind, err)
+			}
+			if testCase.kind != nil && !errors.Is(err, testCase.kind) {
+				t.Fatalf("%s failed: errors.Is(err, %v) must be true", testName+"/"+testCase.name, testCase.kind)
+			}
+			if errors.Is(err, ErrReadOnly) {
+				t.Fatalf("%s failed: errors.Is(err, ErrReadOnly) must be false", testName+"/"+testCase.name)
+			}
+			if !IsAwsError(err, "GenericAPIError") && !IsAwsError(err, testCase.code) {
+				t.Fatalf("%s failed: IsAwsError must still match the wrapped error", testName+"/"+testCase.name)
+			}
+			var opErr *smithy.OperationError
+			if !errors.As(err, &opErr) || err.Error() != testCase.err.Error() {
+				t.Fatalf("%s failed: the SDK error must be accessible via errors.As", testName+"/"+testCase.name)
+			}
+			if wrapAwsError(err, nil) != err {
+				t.Fatalf("%s failed: wrapping twice must return the same error", testName+"/"+testCase.name)
+			}
+		})
+	}
+	if err := wrapAwsError(nil, nil); err != nil {
+		t.Fatalf("%s failed: expected nil but received %#v", testName, err)
+	}
+	if err := wrapAwsError(ErrInTx, nil); err != ErrInTx {
+		t.Fatalf("%s failed: non-AWS errors must be returned as-is, received %#v", testName, err)
+	}
+}
+
+func Test_wrapCreateIndexError(t *testing.T) {
+	testName := "Test_wrapCreateIndexError"
+	testData := []struct {
+		name   string
+		err    error
+		exists bool
+	}{
+		{name: "resource_in_use", err: _awsError(&types.ResourceInUseException{}), exists: true},
+		{name: "validation_exists", err: _awsError(&smithy.GenericAPIError{Code: "ValidationException", Message: "Attempting to create an index which already exists"}), exists: true},
+		{name: "validation_other", err: _awsError(&smithy.GenericAPIError{Code: "ValidationException", Message: "One or more parameter values were invalid"})},
+		{name: "not_found", err: _awsError(&types.ResourceNotFoundException{})},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := wrapCreateIndexError(testCase.err); errors.Is(err, ErrIndexExists) != testCase.exists {
+				t.Fatalf("%s failed: expected errors.Is(err, ErrIndexExists) %v for %s", testName+"/"+testCase.name, testCase.exists, err)
+			}
+		})
+	}
+}
+
+func Test_parseQuery_parseError(t *testing.T) {
+	testName := "Test_parseQuery_parseError"
+	testData := []struct {
+		name      string
+		query     string
+		statement string
+		position  int
+	}{
+		{name: "invalid_query", query: "SELEKT * FROM tbl", statement: "SELEKT * FROM tbl", position: 0},
+		{name: "create_table", query: "CREATE TABLE tbl WITH pk=id:int", statement: "CREATE TABLE tbl WITH pk=id:int", position: -1},
+		{name: "parallel_scan", query: "SELECT * FROM tbl WHERE a = = 1 WITH parallel=2", statement: "SELECT * FROM tbl WHERE a = = 1", position: 28},
+		{name: "parallel_scan_tokenizer", query: "SELECT * FROM tbl WHERE a = ; WITH parallel=2", statement: "SELECT * FROM tbl WHERE a = ;", position: 28},
+		{name: "explain", query: "EXPLAIN SELECT * FROM tbl WHERE", statement: "SELECT * FROM tbl WHERE", position: 23},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := parseQuery(nil, testCase.query)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("%s failed: expected *ParseError but received %#v", testName+"/"+testCase.name, err)
+			}
+			if parseErr.Statement != testCase.statement || parseErr.Position != testCase.position {
+				t.Fatalf("%s failed: expected %#v at %d but received %#v at %d (%s)", testName+"/"+testCase.name,
+					testCase.statement, testCase.position, parseErr.Statement, parseErr.Position, err)
+			}
+		})
+	}
+}
+
+func TestConn_awsErrors(t *testing.T) {
+	testName := "TestConn_awsErrors"
+	stub := _newHooksStub()
+	defer stub.Close()
+	db := _openDbWithHooks(t, stub.URL)
+	for _, query := range []string{`DROP TABLE tbl`, `CREATE TABLE tbl WITH pk=id:string`, `DROP GSI idx ON tbl`} {
+		if _, err := db.Exec(query); !errors.Is(err, ErrValidation) {
+			t.Fatalf("%s failed: expected ErrValidation for <%s> but received %#v", testName, query, err)
+		}
+	}
+	if _, err := db.Query(`DESCRIBE TABLE tbl`); !errors.Is(err, ErrValidation) {
+		t.Fatalf("%s failed: expected ErrValidation for <DESCRIBE TABLE> but received %#v", testName, err)
+	}
+}
@@ -3,6 +3,7 @@ package godynamo
 import (
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
 
@@ -44,6 +45,9 @@ var (
 )
 
 // IsAwsError returns true if err is an AWS-specific error, and it matches awsErrCode.
+//
+// Since v1.4.0, errors returned by the driver match sentinel errors such as ErrTableNotFound or ErrThrottled via
+// errors.Is, see AwsError.
 func IsAwsError(err error, awsErrCode string) bool {
 	var aerr *smithy.OperationError
 	if errors.As(err, &aerr) {
@@ -60,6 +64,22 @@ func IsAwsError(err error, awsErrCode string) bool {
 	return false
 }
 
+// AwsErrorCode returns the code of an AWS-specific error, named the same way IsAwsError matches it (e.g.
+// "ResourceNotFoundException"), or empty string if err is not an AWS-specific error. For errors not modeled by the AWS
+// SDK, the error code returned by DynamoDB is used (e.g. "ValidationException").
+//
+// The error does not need to be wrapped in a *smithy.OperationError, e.g. RetryInfo.Err is the error of the failed
+// attempt, as returned by the AWS SDK's middleware stack.
+//
+// @Available since v1.4.0
+func AwsErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
 // ValuesToNamedValues transforms a []driver.Value to []driver.NamedValue.
 //
 // @Available since v0.2.0
@@ -80,8 +100,12 @@ func ToAttributeValueUnsafe(value interface{}) types.AttributeValue {
 }
 
 // ToAttributeValue marshals a Go value to AWS AttributeValue.
+//
+// @Since v1.4.0 values implementing types.AttributeValue (e.g. *types.AttributeValueMemberS) are returned as-is.
 func ToAttributeValue(value interface{}) (types.AttributeValue, error) {
 	switch v := value.(type) {
+	case types.AttributeValue:
+		return v, nil
 	case types.AttributeValueMemberB:
 		return &v, nil
 	case types.AttributeValueMemberBOOL:
@@ -107,6 +131,32 @@ func ToAttributeValue(value interface{}) (types.AttributeValue, error) {
 	}
 }
 
+// toItemAttributeValue marshals a struct or map to an M AttributeValue, used for the whole-item parameter of
+// "INSERT ... VALUE ?" statement. Structs are marshalled via attributevalue.MarshalMap, honoring `dynamodbav` struct
+// tags (including omitempty).
+func toItemAttributeValue(value interface{}) (types.AttributeValue, error) {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberM:
+		return v, nil
+	case types.AttributeValueMemberM:
+		return &v, nil
+	case map[string]types.AttributeValue:
+		return &types.AttributeValueMemberM{Value: v}, nil
+	}
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct && (rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String) {
+		return nil, fmt.Errorf("whole-item parameter must be a struct or a map with string keys, received %T", value)
+	}
+	item, err := attributevalue.MarshalMap(value)
+	if err != nil {
+		return nil, err
+	}
+	return &types.AttributeValueMemberM{Value: item}, nil
+}
+
 // nameFromAttributeValue returns the name of the attribute value.
 //
 // e.g.
@@ -0,0 +1,441 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// StatementInfo describes a statement executed by the driver, passed to Hooks.
+//
+// @Available since v1.4.0
+type StatementInfo struct {
+	Kind             string        // kind of the statement, e.g. "SELECT", "INSERT", "CREATE TABLE", "LIST TABLES"
+	Table            string        // (logical) name of the target table, empty if the statement has none
+	Query            string        // the statement, as sent to DynamoDB for PartiQL statements
+	NumParams        int           // number of parameters passed to the statement
+	InTx             bool          // true if the statement is added to an ongoing transaction, hence executed on commit
+	Duration         time.Duration // execution time, available in AfterStatement
+	ConsumedCapacity float64       // capacity units consumed by INSERT/SELECT/UPDATE/DELETE/EXPORT/IMPORT statements, available in AfterStatement
+	ItemCount        int           // number of rows returned, or of rows affected, available in AfterStatement
+	PageCount        int           // number of pages fetched by SELECT/EXPORT statements, available in AfterStatement
+	Err              error         // error returned by the statement, available in AfterStatement
+}
+
+// TxInfo describes a transaction committed or rolled back by the driver, passed to Hooks.
+//
+// @Available since v1.4.0
+type TxInfo struct {
+	NumStatements    int           // number of statements in the transaction
+	Duration         time.Duration // time taken to commit or roll back the transaction
+	ConsumedCapacity float64       // capacity units consumed by the transaction (commit only)
+	Err              error         // error returned by the commit or rollback
+}
+
+// RetryInfo describes a request to DynamoDB retried by the AWS SDK, passed to Hooks.
+//
+// @Available since v1.4.0
+type RetryInfo struct {
+	Statement *StatementInfo // the statement being executed, nil for transaction commits
+	Operation string         // the DynamoDB API operation, e.g. "ExecuteStatement"
+	Attempt   int            // the attempt about to be made, starting from 2 (the first retry)
+	Err       error          // error of the previous attempt
+}
+
+// Hooks observes the statements and transactions executed by the driver, e.g. to log queries, create tracing spans
+// or count metrics.
+//
+// BeforeStatement is called before a statement is executed, and may return a derived context (e.g. carrying a tracing
+// span) that is passed to the statement's execution (and thus to the AWS SDK) as well as to AfterStatement.
+// AfterStatement is called once the statement completes, with StatementInfo filled with the execution's outcome.
+// OnRetry is called before the AWS SDK retries a request. OnCommit and OnRollback are called once a transaction is
+// committed or rolled back.
+//
+// Hooks are called synchronously from the goroutine executing the statement, except OnRetry which is called from the
+// scanning goroutines of parallel scans. Embed NopHooks to implement only some of the methods.
+//
+// @Available since v1.4.0
+type Hooks interface {
+	BeforeStatement(ctx context.Context, info *StatementInfo) context.Context
+	AfterStatement(ctx context.Context, info *StatementInfo)
+	OnRetry(ctx context.Context, info *RetryInfo)
+	OnCommit(ctx context.Context, info *TxInfo)
+	OnRollback(ctx context.Context, info *TxInfo)
+}
+
+// NopHooks is an implementation of Hooks that does nothing, to be embedded by implementations that observe only some
+// of the events.
+//
+// @Available since v1.4.0
+type NopHooks struct {
+}
+
+// BeforeStatement implements Hooks/BeforeStatement.
+func (NopHooks) BeforeStatement(ctx context.Context, _ *StatementInfo) context.Context {
+	return ctx
+}
+
+// AfterStatement implements Hooks/AfterStatement.
+func (NopHooks) AfterStatement(_ context.Context, _ *StatementInfo) {}
+
+// OnRetry implements Hooks/OnRetry.
+func (NopHooks) OnRetry(_ context.Context, _ *RetryInfo) {}
+
+// OnCommit implements Hooks/OnCommit.
+func (NopHooks) OnCommit(_ context.Context, _ *TxInfo) {}
+
+// OnRollback implements Hooks/OnRollback.
+func (NopHooks) OnRollback(_ context.Context, _ *TxInfo) {}
+
+var (
+	globalHooksLock sync.RWMutex
+	globalHooks     []Hooks
+)
+
+// RegisterHooks registers hooks that apply to all connections, including the ones already opened. Hooks apply to
+// statements prepared after the registration.
+//
+// @Available since v1.4.0
+func RegisterHooks(hooks Hooks) {
+	globalHooksLock.Lock()
+	defer globalHooksLock.Unlock()
+	globalHooks = append(globalHooks, hooks)
+}
+
+// DeregisterHooks removes all hooks registered via RegisterHooks.
+//
+// @Available since v1.4.0
+func DeregisterHooks() {
+	globalHooksLock.Lock()
+	defer globalHooksLock.Unlock()
+	globalHooks = nil
+}
+
+// Connector is AWS DynamoDB implementation of driver.Connector, which opens connections with the hooks specified in
+// addition to the ones registered via RegisterHooks. Use it with sql.OpenDB:
+//
+//	connector, err := godynamo.NewConnector(dsn, myHooks)
+//	db := sql.OpenDB(connector)
+//
+// @Available since v1.4.0
+type Connector struct {
+	dsn   string
+	hooks []Hooks
+}
+
+// NewConnector creates a Connector that opens connections to the DSN, observed by the hooks. The DSN is validated
+// right away.
+//
+// @Available since v1.4.0
+func NewConnector(dsn string, hooks ...Hooks) (*Connector, error) {
+	if _, err := parseConnString(dsn); err != nil {
+		return nil, err
+	}
+	return &Connector{dsn: dsn, hooks: hooks}, nil
+}
+
+// Connect implements driver.Connector/Connect.
+func (c *Connector) Connect(_ context.Context) (driver.Conn, error) {
+	conn, err := (&Driver{}).Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	conn.(*Conn).hooks = c.hooks
+	return conn, nil
+}
+
+// Driver implements driver.Connector/Driver.
+func (c *Connector) Driver() driver.Driver {
+	return &Driver{}
+}
+
+// allHooks returns the hooks registered via RegisterHooks followed by the connection's hooks.
+func (c *Conn) allHooks() []Hooks {
+	globalHooksLock.RLock()
+	defer globalHooksLock.RUnlock()
+	if len(globalHooks) == 0 {
+		return c.hooks
+	}
+	return append(append(make([]Hooks, 0, len(globalHooks)+len(c.hooks)), globalHooks...), c.hooks...)
+}
+
+// withTxHooks executes the commit (or rollback) of the ongoing transaction with the context ctx (the transaction's
+// context, context.Background() if nil), and reports it to the connection's hooks.
+func (c *Conn) withTxHooks(ctx context.Context, commit bool, f func(ctx context.Context) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	hooks := c.allHooks()
+	if len(hooks) == 0 {
+		return f(ctx)
+	}
+	info := &TxInfo{NumStatements: len(c.txStmtList)}
+	ctx = context.WithValue(ctx, hookStateKey{}, &hookState{hooks: hooks})
+	start := time.Now()
+	err := f(ctx)
+	info.Duration, info.Err = time.Since(start), err
+	if commit && info.NumStatements > 0 {
+		info.ConsumedCapacity = c.lastConsumedCapacity
+	}
+	for _, h := range hooks {
+		if commit {
+			h.OnCommit(ctx, info)
+		} else {
+			h.OnRollback(ctx, info)
+		}
+	}
+	return err
+}
+
+/*----------------------------------------------------------------------*/
+
+// hookState is carried by the context of a statement's execution, so that retries of the requests sent to DynamoDB
+// can be reported.
+type hookState struct {
+	hooks     []Hooks
+	statement *StatementInfo
+}
+
+type hookStateKey struct{}
+
+// attemptCounter counts the attempts of a request sent to DynamoDB.
+type attemptCounter struct {
+	attempts int
+	lastErr  error
+}
+
+type attemptCounterKey struct{}
+
+// addRetryHooksMiddlewares adds to the AWS SDK's middleware stack the middlewares that report retried requests to
+// Hooks.OnRetry.
+func addRetryHooksMiddlewares(stack *middleware.Stack) error {
+	err := stack.Finalize.Insert(middleware.FinalizeMiddlewareFunc("godynamo/RetryHooksInit",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			if ctx.Value(hookStateKey{}) != nil {
+				ctx = context.WithValue(ctx, attemptCounterKey{}, &attemptCounter{})
+			}
+			return next.HandleFinalize(ctx, in)
+		}), "Retry", middleware.Before)
+	if err != nil {
+		return err
+	}
+	return stack.Finalize.Insert(middleware.FinalizeMiddlewareFunc("godynamo/RetryHooks",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			state, _ := ctx.Value(hookStateKey{}).(*hookState)
+			counter, _ := ctx.Value(attemptCounterKey{}).(*attemptCounter)
+			if state == nil || counter == nil {
+				return next.HandleFinalize(ctx, in)
+			}
+			counter.attempts++
+			if counter.attempts > 1 {
+				info := &RetryInfo{Statement: state.statement, Operation: awsmiddleware.GetOperationName(ctx), Attempt: counter.attempts, Err: counter.lastErr}
+				for _, h := range state.hooks {
+					h.OnRetry(ctx, info)
+				}
+			}
+			out, metadata, err := next.HandleFinalize(ctx, in)
+			counter.lastErr = err
+			return out, metadata, err
+		}), "Retry", middleware.After)
+}
+
+/*----------------------------------------------------------------------*/
+
+// hookedStmt wraps a statement to report its execution to Hooks.
+type hookedStmt struct {
+	driver.Stmt
+	conn  *Conn
+	hooks []Hooks
+	kind  string
+	table string
+	query string
+}
+
+// withHooks wraps the statement to report its execution to the connection's hooks, if any.
+func (c *Conn) withHooks(stmt driver.Stmt) driver.Stmt {
+	hooks := c.allHooks()
+	if len(hooks) == 0 {
+		return stmt
+	}
+	kind, table := c.stmtKindAndTable(stmt)
+	return &hookedStmt{Stmt: stmt, conn: c, hooks: hooks, kind: kind, table: table, query: stmtQuery(stmt)}
+}
+
+// Exec implements driver.Stmt/Exec.
+func (s *hookedStmt) Exec(values []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), ValuesToNamedValues(values))
+}
+
+// ExecContext implements driver.StmtExecContext/ExecContext.
+func (s *hookedStmt) ExecContext(ctx context.Context, values []driver.NamedValue) (driver.Result, error) {
+	ctx, info, start := s.before(ctx, values)
+	result, err := s.Stmt.(driver.StmtExecContext).ExecContext(ctx, values)
+	if err == nil && result != nil {
+		if affected, e := result.RowsAffected(); e == nil {
+			info.ItemCount = int(affected)
+		}
+	}
+	s.after(ctx, info, start, err)
+	return result, err
+}
+
+// Query implements driver.Stmt/Query.
+func (s *hookedStmt) Query(values []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), ValuesToNamedValues(values))
+}
+
+// QueryContext implements driver.StmtQueryContext/QueryContext.
+func (s *hookedStmt) QueryContext(ctx context.Context, values []driver.NamedValue) (driver.Rows, error) {
+	ctx, info, start := s.before(ctx, values)
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, values)
+	if counter, ok := rows.(interface{ numRows() int }); ok && err == nil {
+		info.ItemCount = counter.numRows()
+	}
+	s.after(ctx, info, start, err)
+	return rows, err
+}
+
+func (s *hookedStmt) before(ctx context.Context, values []driver.NamedValue) (context.Context, *StatementInfo, time.Time) {
+	info := &StatementInfo{Kind: s.kind, Table: s.table, Query: s.query, NumParams: len(values), InTx: s.conn.txMode == txStarted}
+	ctx, start := s.conn.beforeStatement(ctx, s.hooks, info)
+	return ctx, info, start
+}
+
+func (s *hookedStmt) after(ctx context.Context, info *StatementInfo, start time.Time, err error) {
+	s.conn.afterStatement(ctx, s.hooks, info, start, err, consumesCapacity(s.Stmt))
+}
+
+// withStatementHooks executes f, an operation that is not a prepared statement (e.g. functions Export and Import), and
+// reports it to the connection's hooks as a statement described by info. f returns the number of items it read or
+// wrote, reported as StatementInfo.ItemCount.
+func (c *Conn) withStatementHooks(ctx context.Context, info *StatementInfo, f func(ctx context.Context) (int64, error)) error {
+	hooks := c.allHooks()
+	if len(hooks) == 0 {
+		_, err := f(ctx)
+		return err
+	}
+	ctx, start := c.beforeStatement(ctx, hooks, info)
+	numItems, err := f(ctx)
+	info.ItemCount = int(numItems)
+	c.afterStatement(ctx, hooks, info, start, err, true)
+	return err
+}
+
+func (c *Conn) beforeStatement(ctx context.Context, hooks []Hooks, info *StatementInfo) (context.Context, time.Time) {
+	for _, h := range hooks {
+		ctx = h.BeforeStatement(ctx, info)
+	}
+	c.lastPageCount = 0
+	return context.WithValue(ctx, hookStateKey{}, &hookState{hooks: hooks, statement: info}), time.Now()
+}
+
+func (c *Conn) afterStatement(ctx context.Context, hooks []Hooks, info *StatementInfo, start time.Time, err error, consumesCapacity bool) {
+	info.Duration = time.Since(start)
+	if errors.Is(err, ErrInTx) {
+		err = nil
+	}
+	info.Err = err
+	if !info.InTx && consumesCapacity {
+		info.ConsumedCapacity = c.lastConsumedCapacity
+		info.PageCount = c.lastPageCount
+	}
+	for _, h := range hooks {
+		h.AfterStatement(ctx, info)
+	}
+}
+
+// consumesCapacity returns true if the statement reads or writes items, so that its consumed capacity is tracked.
+func consumesCapacity(stmt driver.Stmt) bool {
+	switch stmt.(type) {
+	case *StmtInsert, *StmtSelect, *StmtUpdate, *StmtDelete, *StmtExport, *StmtImport:
+		return true
+	}
+	return false
+}
+
+// stmtQuery returns the query of the statement.
+func stmtQuery(stmt driver.Stmt) string {
+	if s, ok := stmt.(interface{ stmtQuery() string }); ok {
+		return s.stmtQuery()
+	}
+	return ""
+}
+
+func (s *Stmt) stmtQuery() string {
+	return s.query
+}
+
+// stmtKindAndTable returns the kind and the (logical) target table name of the statement.
+func (c *Conn) stmtKindAndTable(stmt driver.Stmt) (string, string) {
+	var kind, table string
+	var reTarget *regexp.Regexp
+	switch s := stmt.(type) {
+	case *StmtCreateTable:
+		kind, table = "CREATE TABLE", s.tableName
+	case *StmtListTables:
+		kind = "LIST TABLES"
+	case *StmtDescribeTable:
+		kind, table = "DESCRIBE TABLE", s.tableName
+	case *StmtAlterTable:
+		kind, table = "ALTER TABLE", s.tableName
+	case *StmtDropTable:
+		kind, table = "DROP TABLE", s.tableName
+	case *StmtDescribeLSI:
+		kind, table = "DESCRIBE LSI", s.tableName
+	case *StmtCreateGSI:
+		kind, table = "CREATE GSI", s.tableName
+	case *StmtDescribeGSI:
+		kind, table = "DESCRIBE GSI", s.tableName
+	case *StmtAlterGSI:
+		kind, table = "ALTER GSI", s.tableName
+	case *StmtDropGSI:
+		kind, table = "DROP GSI", s.tableName
+	case *StmtImport:
+		kind, table = "IMPORT", s.tableName
+	case *StmtExport:
+		_, table = c.stmtKindAndTable(s.selectStmt)
+		return "EXPORT", table
+	case *StmtExplain:
+		_, table = c.stmtKindAndTable(s.selectStmt)
+		return "EXPLAIN", table
+	case *StmtInsert:
+		kind, reTarget = "INSERT", reInsert
+	case *StmtSelect:
+		kind, reTarget = "SELECT", reSelectFrom
+	case *StmtUpdate:
+		kind, reTarget = "UPDATE", reUpdate
+	case *StmtDelete:
+		kind, reTarget = "DELETE", reDelete
+	}
+	if reTarget != nil {
+		table, _, _ = findTargetTable(stmtQuery(stmt), reTarget)
+	}
+	table, _ = c.logicalTableName(table)
+	return kind, table
+}
+
+// numRows returns the number of rows of the result set, reported to Hooks as StatementInfo.ItemCount.
+func (r *ResultResultSet) numRows() int {
+	return r.count
+}
+
+// numRows returns the number of rows of the result set, reported to Hooks as StatementInfo.ItemCount.
+func (r *RowsListTables) numRows() int {
+	return r.count
+}
+
+// numRows returns the number of rows of the result set, reported to Hooks as StatementInfo.ItemCount.
+func (r *RowsDescribeIndex) numRows() int {
+	return r.count
+}
+
+// numRows returns the number of rows of the result set, reported to Hooks as StatementInfo.ItemCount.
+func (r *RowsDescribeTable) numRows() int {
+	return r.count
+}
@@ -0,0 +1,267 @@
+package godynamo
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+type _hooksCtxKey struct{}
+
+type _recordingHooks struct {
+	NopHooks
+	lock      sync.Mutex
+	before    []StatementInfo
+	after     []StatementInfo
+	afterCtx  []interface{}
+	retries   []RetryInfo
+	commits   []TxInfo
+	rollbacks []TxInfo
+}
+
+func (h *_recordingHooks) BeforeStatement(ctx context.Context, info *StatementInfo) context.Context {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.before = append(h.before, *info)
+	return context.WithValue(ctx, _hooksCtxKey{}, info.Kind)
+}
+
+func (h *_recordingHooks) AfterStatement(ctx context.Context, info *StatementInfo) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.after = append(h.after, *info)
+	h.afterCtx = append(h.afterCtx, ctx.Value(_hooksCtxKey{}))
+}
+
+func (h *_recordingHooks) OnRetry(_ context.Context, info *RetryInfo) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.retries = append(h.retries, *info)
+}
+
+func (h *_recordingHooks) OnCommit(_ context.Context, info *TxInfo) {
+	h.commits = append(h.commits, *info)
+}
+
+func (h *_recordingHooks) OnRollback(_ context.Context, info *TxInfo) {
+	h.rollbacks = append(h.rollbacks, *info)
+}
+
+// _newHooksStub starts a stub DynamoDB server: SELECT statements return 2 pages of 1 item each, statements containing
+// "retry" fail once with HTTP 500 before succeeding.
+func _newHooksStub() *httptest.Server {
+	var lock sync.Mutex
+	failed := map[string]bool{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		switch r.Header.Get("X-Amz-Target") {
+		case "DynamoDB_20120810.ExecuteStatement":
+			statement, _ := input["Statement"].(string)
+			lock.Lock()
+			if strings.Contains(statement, "retry") && !failed[statement] {
+				failed[statement] = true
+				lock.Unlock()
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#InternalServerError","message":"try again"}`))
+				return
+			}
+			lock.Unlock()
+			if !strings.HasPrefix(statement, "SELECT") {
+				_, _ = w.Write([]byte(`{"Items":[{"id":{"S":"a"}}],"ConsumedCapacity":{"CapacityUnits":1}}`))
+			} else if input["NextToken"] == nil {
+				_, _ = w.Write([]byte(`{"Items":[{"id":{"S":"a"}}],"NextToken":"page2","ConsumedCapacity":{"CapacityUnits":0.5}}`))
+			} else {
+				_, _ = w.Write([]byte(`{"Items":[{"id":{"S":"b"}}],"ConsumedCapacity":{"CapacityUnits":0.5}}`))
+			}
+		case "DynamoDB_20120810.ExecuteTransaction":
+			_, _ = w.Write([]byte(`{"ConsumedCapacity":[{"CapacityUnits":2},{"CapacityUnits":2}]}`))
+		case "DynamoDB_20120810.BatchWriteItem":
+			_, _ = w.Write([]byte(`{"ConsumedCapacity":[{"CapacityUnits":3}]}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"com.amazon.coral.validate#ValidationException","message":"unsupported"}`))
+		}
+	}))
+}
+
+func _openDbWithHooks(t *testing.T, endpoint string, hooks ...Hooks) *sql.DB {
+	RegisterNamedAWSConfig("hooks_test", aws.Config{Retryer: func() aws.Retryer {
+		return retry.NewStandard(func(o *retry.StandardOptions) {
+			o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+		})
+	}})
+	t.Cleanup(func() { DeregisterNamedAWSConfig("hooks_test") })
+	connector, err := NewConnector("AwsConfig=hooks_test;Region=us-east-1;AkId=id;Secret_Key=secret;TablePrefix=dev_;Endpoint="+endpoint, hooks...)
+	if err != nil {
+		t.Fatalf("NewConnector failed: %s", err)
+	}
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestHooks_statements(t *testing.T) {
+	testName := "TestHooks_statements"
+	stub := _newHooksStub()
+	defer stub.Close()
+	hooks := &_recordingHooks{}
+	db := _openDbWithHooks(t, stub.URL, hooks)
+
+	rows, err := db.Query(`SELECT * FROM items WHERE id=?`, "a")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	_ = rows.Close()
+	if _, err = db.Exec(`UPDATE items SET v=? WHERE id=? AND retry=1`, 1, "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = db.Exec(`DELETE FROM missing WHERE id=?`, "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = db.Exec(`DROP TABLE IF EXISTS items`); err == nil {
+		t.Fatalf("%s failed: DROP TABLE must fail", testName)
+	}
+
+	expected := []StatementInfo{
+		{Kind: "SELECT", Table: "items", Query: `SELECT * FROM "dev_items" WHERE id=?`, NumParams: 1, ConsumedCapacity: 1, ItemCount: 2, PageCount: 2},
+		{Kind: "UPDATE", Table: "items", Query: `UPDATE "dev_items" SET v=? WHERE id=? AND retry=1 RETURNING ALL OLD *`, NumParams: 2, ConsumedCapacity: 1, ItemCount: 1, PageCount: 1},
+		{Kind: "DELETE", Table: "missing", Query: `DELETE FROM "dev_missing" WHERE id=? RETURNING ALL OLD *`, NumParams: 1, ConsumedCapacity: 1, ItemCount: 1, PageCount: 1},
+		{Kind: "DROP TABLE", Table: "items", Query: `DROP TABLE IF EXISTS items`},
+	}
+	if len(hooks.before) != len(expected) || len(hooks.after) != len(expected) {
+		t.Fatalf("%s failed: expected %d statements but received %d/%d", testName, len(expected), len(hooks.before), len(hooks.after))
+	}
+	for i, exp := range expected {
+		before, after := hooks.before[i], hooks.after[i]
+		if before.Kind != exp.Kind || before.Table != exp.Table || before.Query != exp.Query || before.NumParams != exp.NumParams || before.Duration != 0 {
+			t.Fatalf("%s failed: expected %#v but received %#v", testName+"/before/"+exp.Kind, exp, before)
+		}
+		if hooks.afterCtx[i] != exp.Kind {
+			t.Fatalf("%s failed: context returned by BeforeStatement not passed to AfterStatement", testName+"/after/"+exp.Kind)
+		}
+		if (after.Err != nil) != (exp.Kind == "DROP TABLE") {
+			t.Fatalf("%s failed: unexpected error %v", testName+"/after/"+exp.Kind, after.Err)
+		}
+		after.Err, after.Duration = nil, 0
+		if after != exp {
+			t.Fatalf("%s failed: expected %#v but received %#v", testName+"/after/"+exp.Kind, exp, after)
+		}
+	}
+
+	if len(hooks.retries) != 1 {
+		t.Fatalf("%s failed: expected 1 retry but received %d", testName, len(hooks.retries))
+	}
+	if r := hooks.retries[0]; r.Attempt != 2 || r.Operation != "ExecuteStatement" || r.Err == nil || r.Statement == nil || r.Statement.Kind != "UPDATE" {
+		t.Fatalf("%s failed: unexpected retry %#v", testName, r)
+	}
+}
+
+func TestHooks_exportImport(t *testing.T) {
+	testName := "TestHooks_exportImport"
+	stub := _newHooksStub()
+	defer stub.Close()
+	hooks := &_recordingHooks{}
+	db := _openDbWithHooks(t, stub.URL, hooks)
+
+	if _, err := Export(context.Background(), db, `SELECT * FROM items WHERE id=?`, &bytes.Buffer{}, ExportFormatJsonl, "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	input := strings.NewReader("{\"id\":\"a\"}\n{\"id\":\"b\"}\n")
+	if _, err := Import(context.Background(), db, "items", input, ImportFormatJsonl, &ImportOptions{Workers: 1}); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	expected := []StatementInfo{
+		{Kind: "EXPORT", Table: "items", Query: `SELECT * FROM "dev_items" WHERE id=?`, NumParams: 1, ConsumedCapacity: 1, ItemCount: 2, PageCount: 2},
+		{Kind: "IMPORT", Table: "items", ConsumedCapacity: 3, ItemCount: 2},
+	}
+	if len(hooks.before) != len(expected) || len(hooks.after) != len(expected) {
+		t.Fatalf("%s failed: expected %d statements but received %d/%d", testName, len(expected), len(hooks.before), len(hooks.after))
+	}
+	for i, exp := range expected {
+		before, after := hooks.before[i], hooks.after[i]
+		if before.Kind != exp.Kind || before.Table != exp.Table || before.Query != exp.Query || before.NumParams != exp.NumParams {
+			t.Fatalf("%s failed: expected %#v but received %#v", testName+"/before/"+exp.Kind, exp, before)
+		}
+		if hooks.afterCtx[i] != exp.Kind {
+			t.Fatalf("%s failed: context returned by BeforeStatement not passed to AfterStatement", testName+"/after/"+exp.Kind)
+		}
+		if after.Err != nil || after.Duration <= 0 {
+			t.Fatalf("%s failed: unexpected outcome %#v", testName+"/after/"+exp.Kind, after)
+		}
+		after.Duration = 0
+		if after != exp {
+			t.Fatalf("%s failed: expected %#v but received %#v", testName+"/after/"+exp.Kind, exp, after)
+		}
+	}
+}
+
+func TestHooks_transactions(t *testing.T) {
+	testName := "TestHooks_transactions"
+	stub := _newHooksStub()
+	defer stub.Close()
+	hooks := &_recordingHooks{}
+	db := _openDbWithHooks(t, stub.URL)
+	RegisterHooks(hooks)
+	defer DeregisterHooks()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	for _, id := range []string{"a", "b"} {
+		if _, err = tx.Exec(`INSERT INTO items VALUE {'id': ?}`, id); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(hooks.after) != 2 || !hooks.after[0].InTx || hooks.after[0].Err != nil || hooks.after[0].Table != "items" {
+		t.Fatalf("%s failed: unexpected statements %#v", testName, hooks.after)
+	}
+	if len(hooks.commits) != 1 || hooks.commits[0].NumStatements != 2 || hooks.commits[0].ConsumedCapacity != 4 || hooks.commits[0].Err != nil {
+		t.Fatalf("%s failed: unexpected commits %#v", testName, hooks.commits)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.Exec(`DELETE FROM items WHERE id=?`, "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(hooks.rollbacks) != 1 || hooks.rollbacks[0].NumStatements != 1 || hooks.rollbacks[0].Err != nil {
+		t.Fatalf("%s failed: unexpected rollbacks %#v", testName, hooks.rollbacks)
+	}
+
+	DeregisterHooks()
+	if _, err = db.Exec(`DELETE FROM items WHERE id=?`, "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(hooks.after) != 3 {
+		t.Fatalf("%s failed: deregistered hooks must not be called", testName)
+	}
+}
+
+func TestNewConnector_invalidDSN(t *testing.T) {
+	testName := "TestNewConnector_invalidDSN"
+	if _, err := NewConnector("Region=us-east-1;Regoin=us-west-2"); err == nil || !strings.Contains(err.Error(), "Regoin") {
+		t.Fatalf("%s failed: expected error about Regoin but received %v", testName, err)
+	}
+}
@@ -0,0 +1,483 @@
+// Package metrics provides a ready-made metrics collector for the godynamo driver.
+//
+// A Collector implements godynamo.Hooks and tracks, per table and statement kind: latency histograms, error counts by
+// AWS error code (named the same way as godynamo.IsAwsError), throttles, retries, consumed read/write capacity units
+// and pages fetched per SELECT/EXPORT statement; as well as transaction commit/rollback outcomes. Metrics are published
+// via expvar and/or a text exposition handler compatible with Prometheus:
+//
+//	collector := metrics.Enable()                      // registers the collector's hooks and publishes expvar "godynamo"
+//	http.Handle("/metrics", collector.Handler())       // Prometheus text exposition format
+//
+// @Available since v1.4.0
+package metrics
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/btnguyen2k/godynamo"
+)
+
+var (
+	// DefaultLatencyBuckets are the upper bounds (in seconds) of the buckets of the latency histograms.
+	//
+	// @Available since v1.4.0
+	DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+	// DefaultPageBuckets are the upper bounds of the buckets of the histograms of pages fetched per statement.
+	//
+	// @Available since v1.4.0
+	DefaultPageBuckets = []float64{1, 2, 5, 10, 25, 50, 100}
+
+	// ThrottlingErrorCodes are the AWS error codes counted as throttles.
+	//
+	// @Available since v1.4.0
+	ThrottlingErrorCodes = []string{"ProvisionedThroughputExceededException", "ThrottlingException", "RequestLimitExceeded"}
+)
+
+// ExpvarName is the name under which Enable publishes the default collector via expvar.
+//
+// @Available since v1.4.0
+const ExpvarName = "godynamo"
+
+// errorCodeOther is the error code of errors that are not AWS-specific (e.g. parsing errors).
+const errorCodeOther = "Other"
+
+// readKinds are the statement kinds whose consumed capacity units are read capacity units, the ones of the other
+// statement kinds are write capacity units.
+var readKinds = map[string]bool{"SELECT": true, "EXPORT": true}
+
+/*----------------------------------------------------------------------*/
+
+// Histogram is a snapshot of a histogram: Counts[i] is the number of observations less than or equal to Buckets[i]
+// (cumulative, as in Prometheus), Count is the total number of observations and Sum their sum.
+//
+// @Available since v1.4.0
+type Histogram struct {
+	Buckets []float64 `json:"buckets"`
+	Counts  []int64   `json:"counts"`
+	Count   int64     `json:"count"`
+	Sum     float64   `json:"sum"`
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{Buckets: buckets, Counts: make([]int64, len(buckets))}
+}
+
+func (h *Histogram) observe(v float64) {
+	for i, bound := range h.Buckets {
+		if v <= bound {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += v
+}
+
+func (h *Histogram) clone() *Histogram {
+	if h == nil {
+		return nil
+	}
+	return &Histogram{Buckets: h.Buckets, Counts: append([]int64(nil), h.Counts...), Count: h.Count, Sum: h.Sum}
+}
+
+// StatementMetrics holds the metrics of the statements of a kind executed against a table.
+//
+// @Available since v1.4.0
+type StatementMetrics struct {
+	Table              string           `json:"table"`
+	Kind               string           `json:"kind"`
+	Count              int64            `json:"count"`
+	Errors             map[string]int64 `json:"errors"` // number of errors, by AWS error code ("Other" for non-AWS errors)
+	Throttles          int64            `json:"throttles"`
+	Retries            int64            `json:"retries"`
+	ReadCapacityUnits  float64          `json:"read_capacity_units"`
+	WriteCapacityUnits float64          `json:"write_capacity_units"`
+	Latency            *Histogram       `json:"latency_seconds"`
+	Pages              *Histogram       `json:"pages,omitempty"` // pages fetched per SELECT/EXPORT statement
+}
+
+// TxMetrics holds the metrics of transactions.
+//
+// @Available since v1.4.0
+type TxMetrics struct {
+	Commits         int64            `json:"commits"`
+	CommitErrors    map[string]int64 `json:"commit_errors"` // number of failed commits, by AWS error code
+	Rollbacks       int64            `json:"rollbacks"`
+	Retries         int64            `json:"retries"`
+	Throttles       int64            `json:"throttles"`
+	CapacityUnits   float64          `json:"capacity_units"`
+	Statements      int64            `json:"statements"` // number of statements of the committed transactions
+	CommitLatency   *Histogram       `json:"commit_latency_seconds"`
+	RollbackLatency *Histogram       `json:"rollback_latency_seconds"`
+}
+
+// Snapshot is a point-in-time copy of the metrics of a Collector.
+//
+// @Available since v1.4.0
+type Snapshot struct {
+	Statements []*StatementMetrics `json:"statements"` // sorted by table then kind
+	Tx         *TxMetrics          `json:"tx"`
+}
+
+/*----------------------------------------------------------------------*/
+
+type statementKey struct {
+	table, kind string
+}
+
+// Collector collects metrics of the statements and transactions executed by the driver. It implements godynamo.Hooks,
+// register it via godynamo.RegisterHooks or godynamo.NewConnector (or simply use Enable).
+//
+// @Available since v1.4.0
+type Collector struct {
+	lock           sync.Mutex
+	latencyBuckets []float64
+	pageBuckets    []float64
+	statements     map[statementKey]*StatementMetrics
+	tx             *TxMetrics
+}
+
+// NewCollector creates a new Collector with DefaultLatencyBuckets and DefaultPageBuckets.
+//
+// @Available since v1.4.0
+func NewCollector() *Collector {
+	c := &Collector{latencyBuckets: DefaultLatencyBuckets, pageBuckets: DefaultPageBuckets}
+	c.Reset()
+	return c
+}
+
+var (
+	defaultCollector     *Collector
+	defaultCollectorOnce sync.Once
+)
+
+// Enable creates the default Collector, registers it via godynamo.RegisterHooks and publishes it via expvar under name
+// ExpvarName. Subsequent calls return the same Collector.
+//
+// @Available since v1.4.0
+func Enable() *Collector {
+	defaultCollectorOnce.Do(func() {
+		defaultCollector = NewCollector()
+		godynamo.RegisterHooks(defaultCollector)
+		defaultCollector.Publish(ExpvarName)
+	})
+	return defaultCollector
+}
+
+// Publish publishes the collector's snapshot via expvar under the name. As with expvar.Publish, it panics if the name
+// is already in use.
+//
+// @Available since v1.4.0
+func (c *Collector) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return c.Snapshot()
+	}))
+}
+
+// Reset clears all metrics collected so far.
+//
+// @Available since v1.4.0
+func (c *Collector) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.statements = make(map[statementKey]*StatementMetrics)
+	c.tx = &TxMetrics{
+		CommitErrors:    make(map[string]int64),
+		CommitLatency:   newHistogram(c.latencyBuckets),
+		RollbackLatency: newHistogram(c.latencyBuckets),
+	}
+}
+
+// Snapshot returns a copy of the metrics collected so far.
+//
+// @Available since v1.4.0
+func (c *Collector) Snapshot() Snapshot {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	snapshot := Snapshot{Statements: make([]*StatementMetrics, 0, len(c.statements))}
+	for _, m := range c.statements {
+		clone := *m
+		clone.Errors = copyCounts(m.Errors)
+		clone.Latency = m.Latency.clone()
+		clone.Pages = m.Pages.clone()
+		snapshot.Statements = append(snapshot.Statements, &clone)
+	}
+	sort.Slice(snapshot.Statements, func(i, j int) bool {
+		a, b := snapshot.Statements[i], snapshot.Statements[j]
+		return a.Table < b.Table || (a.Table == b.Table && a.Kind < b.Kind)
+	})
+	tx := *c.tx
+	tx.CommitErrors = copyCounts(c.tx.CommitErrors)
+	tx.CommitLatency = c.tx.CommitLatency.clone()
+	tx.RollbackLatency = c.tx.RollbackLatency.clone()
+	snapshot.Tx = &tx
+	return snapshot
+}
+
+func copyCounts(m map[string]int64) map[string]int64 {
+	result := make(map[string]int64, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
+
+// statementMetrics returns the metrics of the statement's table and kind, creating them if needed.
+func (c *Collector) statementMetrics(info *godynamo.StatementInfo) *StatementMetrics {
+	key := statementKey{table: info.Table, kind: info.Kind}
+	m, ok := c.statements[key]
+	if !ok {
+		m = &StatementMetrics{Table: info.Table, Kind: info.Kind, Errors: make(map[string]int64), Latency: newHistogram(c.latencyBuckets)}
+		if readKinds[info.Kind] {
+			m.Pages = newHistogram(c.pageBuckets)
+		}
+		c.statements[key] = m
+	}
+	return m
+}
+
+// errorCode returns the AWS error code of err, or errorCodeOther if err is not an AWS-specific error.
+func errorCode(err error) string {
+	if code := godynamo.AwsErrorCode(err); code != "" {
+		return code
+	}
+	return errorCodeOther
+}
+
+// isThrottle returns true if err is an AWS throttling error, see ThrottlingErrorCodes.
+func isThrottle(err error) bool {
+	code := godynamo.AwsErrorCode(err)
+	for _, throttleCode := range ThrottlingErrorCodes {
+		if code == throttleCode {
+			return true
+		}
+	}
+	return false
+}
+
+// BeforeStatement implements godynamo.Hooks/BeforeStatement.
+func (c *Collector) BeforeStatement(ctx context.Context, _ *godynamo.StatementInfo) context.Context {
+	return ctx
+}
+
+// AfterStatement implements godynamo.Hooks/AfterStatement.
+func (c *Collector) AfterStatement(_ context.Context, info *godynamo.StatementInfo) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	m := c.statementMetrics(info)
+	m.Count++
+	m.Latency.observe(info.Duration.Seconds())
+	if info.Err != nil {
+		m.Errors[errorCode(info.Err)]++
+		if isThrottle(info.Err) {
+			m.Throttles++
+		}
+	}
+	if readKinds[info.Kind] {
+		m.ReadCapacityUnits += info.ConsumedCapacity
+		if !info.InTx && info.Err == nil {
+			m.Pages.observe(float64(info.PageCount))
+		}
+	} else {
+		m.WriteCapacityUnits += info.ConsumedCapacity
+	}
+}
+
+// OnRetry implements godynamo.Hooks/OnRetry.
+func (c *Collector) OnRetry(_ context.Context, info *godynamo.RetryInfo) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	throttle := isThrottle(info.Err)
+	if info.Statement == nil {
+		c.tx.Retries++
+		if throttle {
+			c.tx.Throttles++
+		}
+		return
+	}
+	m := c.statementMetrics(info.Statement)
+	m.Retries++
+	if throttle {
+		m.Throttles++
+	}
+}
+
+// OnCommit implements godynamo.Hooks/OnCommit.
+func (c *Collector) OnCommit(_ context.Context, info *godynamo.TxInfo) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tx.Commits++
+	c.tx.Statements += int64(info.NumStatements)
+	c.tx.CapacityUnits += info.ConsumedCapacity
+	c.tx.CommitLatency.observe(info.Duration.Seconds())
+	if info.Err != nil {
+		c.tx.CommitErrors[errorCode(info.Err)]++
+		if isThrottle(info.Err) {
+			c.tx.Throttles++
+		}
+	}
+}
+
+// OnRollback implements godynamo.Hooks/OnRollback.
+func (c *Collector) OnRollback(_ context.Context, info *godynamo.TxInfo) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tx.Rollbacks++
+	c.tx.RollbackLatency.observe(info.Duration.Seconds())
+}
+
+/*----------------------------------------------------------------------*/
+
+// Handler returns a http.Handler serving the collector's metrics in the Prometheus text exposition format.
+//
+// @Available since v1.4.0
+func (c *Collector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = c.WriteText(w)
+	})
+}
+
+// WriteText writes the collector's metrics to w in the Prometheus text exposition format.
+//
+// @Available since v1.4.0
+func (c *Collector) WriteText(w io.Writer) error {
+	snapshot := c.Snapshot()
+	tw := &textWriter{w: w}
+	tw.header("godynamo_statements_total", "counter", "Number of executed statements.")
+	for _, m := range snapshot.Statements {
+		tw.sample("godynamo_statements_total", statementLabels(m), float64(m.Count))
+	}
+	tw.header("godynamo_statement_errors_total", "counter", "Number of failed statements, by AWS error code.")
+	for _, m := range snapshot.Statements {
+		for _, code := range sortedKeys(m.Errors) {
+			tw.sample("godynamo_statement_errors_total", append(statementLabels(m), "code", code), float64(m.Errors[code]))
+		}
+	}
+	tw.header("godynamo_statement_throttles_total", "counter", "Number of throttled requests.")
+	for _, m := range snapshot.Statements {
+		tw.sample("godynamo_statement_throttles_total", statementLabels(m), float64(m.Throttles))
+	}
+	tw.header("godynamo_statement_retries_total", "counter", "Number of retried requests.")
+	for _, m := range snapshot.Statements {
+		tw.sample("godynamo_statement_retries_total", statementLabels(m), float64(m.Retries))
+	}
+	tw.header("godynamo_consumed_read_capacity_units_total", "counter", "Consumed read capacity units.")
+	for _, m := range snapshot.Statements {
+		tw.sample("godynamo_consumed_read_capacity_units_total", statementLabels(m), m.ReadCapacityUnits)
+	}
+	tw.header("godynamo_consumed_write_capacity_units_total", "counter", "Consumed write capacity units.")
+	for _, m := range snapshot.Statements {
+		tw.sample("godynamo_consumed_write_capacity_units_total", statementLabels(m), m.WriteCapacityUnits)
+	}
+	tw.header("godynamo_statement_duration_seconds", "histogram", "Latency of statements.")
+	for _, m := range snapshot.Statements {
+		tw.histogram("godynamo_statement_duration_seconds", statementLabels(m), m.Latency)
+	}
+	tw.header("godynamo_statement_pages", "histogram", "Pages fetched per SELECT/EXPORT statement.")
+	for _, m := range snapshot.Statements {
+		if m.Pages != nil {
+			tw.histogram("godynamo_statement_pages", statementLabels(m), m.Pages)
+		}
+	}
+
+	tx := snapshot.Tx
+	tw.header("godynamo_tx_commits_total", "counter", "Number of committed transactions, by outcome.")
+	numErrors := int64(0)
+	for _, n := range tx.CommitErrors {
+		numErrors += n
+	}
+	tw.sample("godynamo_tx_commits_total", []string{"outcome", "success"}, float64(tx.Commits-numErrors))
+	tw.sample("godynamo_tx_commits_total", []string{"outcome", "error"}, float64(numErrors))
+	tw.header("godynamo_tx_commit_errors_total", "counter", "Number of failed commits, by AWS error code.")
+	for _, code := range sortedKeys(tx.CommitErrors) {
+		tw.sample("godynamo_tx_commit_errors_total", []string{"code", code}, float64(tx.CommitErrors[code]))
+	}
+	tw.header("godynamo_tx_rollbacks_total", "counter", "Number of rolled back transactions.")
+	tw.sample("godynamo_tx_rollbacks_total", nil, float64(tx.Rollbacks))
+	tw.header("godynamo_tx_retries_total", "counter", "Number of retried transaction requests.")
+	tw.sample("godynamo_tx_retries_total", nil, float64(tx.Retries))
+	tw.header("godynamo_tx_throttles_total", "counter", "Number of throttled transaction requests.")
+	tw.sample("godynamo_tx_throttles_total", nil, float64(tx.Throttles))
+	tw.header("godynamo_tx_statements_total", "counter", "Number of statements of committed transactions.")
+	tw.sample("godynamo_tx_statements_total", nil, float64(tx.Statements))
+	tw.header("godynamo_tx_consumed_capacity_units_total", "counter", "Capacity units consumed by transactions.")
+	tw.sample("godynamo_tx_consumed_capacity_units_total", nil, tx.CapacityUnits)
+	tw.header("godynamo_tx_duration_seconds", "histogram", "Latency of transaction commits and rollbacks.")
+	tw.histogram("godynamo_tx_duration_seconds", []string{"op", "commit"}, tx.CommitLatency)
+	tw.histogram("godynamo_tx_duration_seconds", []string{"op", "rollback"}, tx.RollbackLatency)
+	return tw.err
+}
+
+func statementLabels(m *StatementMetrics) []string {
+	return []string{"table", m.Table, "kind", m.Kind}
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// textWriter writes metrics in the Prometheus text exposition format, remembering the first write error.
+type textWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (tw *textWriter) printf(format string, args ...interface{}) {
+	if tw.err == nil {
+		_, tw.err = fmt.Fprintf(tw.w, format, args...)
+	}
+}
+
+func (tw *textWriter) header(name, typ, help string) {
+	tw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a sample, labels are name/value pairs.
+func (tw *textWriter) sample(name string, labels []string, value float64) {
+	if len(labels) == 0 {
+		tw.printf("%s %s\n", name, formatFloat(value))
+		return
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+escapeLabelValue(labels[i+1])+`"`)
+	}
+	tw.printf("%s{%s} %s\n", name, strings.Join(pairs, ","), formatFloat(value))
+}
+
+func (tw *textWriter) histogram(name string, labels []string, h *Histogram) {
+	for i, bound := range h.Buckets {
+		tw.sample(name+"_bucket", append(append([]string(nil), labels...), "le", formatFloat(bound)), float64(h.Counts[i]))
+	}
+	tw.sample(name+"_bucket", append(append([]string(nil), labels...), "le", "+Inf"), float64(h.Count))
+	tw.sample(name+"_sum", labels, h.Sum)
+	tw.sample(name+"_count", labels, float64(h.Count))
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueReplacer.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
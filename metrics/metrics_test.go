@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/btnguyen2k/godynamo"
+)
+
+func awsError(err error) error {
+	return &smithy.OperationError{ServiceID: "DynamoDB", OperationName: "ExecuteStatement",
+		Err: &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{Err: err}}}
+}
+
+func TestAwsErrorCode(t *testing.T) {
+	testName := "TestAwsErrorCode"
+	testData := []struct {
+		name string
+		err  error
+		code string
+	}{
+		{name: "modeled", err: awsError(&types.ResourceNotFoundException{}), code: "ResourceNotFoundException"},
+		{name: "generic", err: awsError(&smithy.GenericAPIError{Code: "ValidationException"}), code: "ValidationException"},
+		{name: "attempt", err: &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{Err: &types.ProvisionedThroughputExceededException{}}},
+			code: "ProvisionedThroughputExceededException"},
+		{name: "not_aws", err: errors.New("error"), code: ""},
+		{name: "nil", err: nil, code: ""},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			if code := godynamo.AwsErrorCode(testCase.err); code != testCase.code {
+				t.Fatalf("%s failed: expected %#v but received %#v", testName+"/"+testCase.name, testCase.code, code)
+			}
+		})
+	}
+	if !godynamo.IsAwsError(testData[0].err, "ResourceNotFoundException") {
+		t.Fatalf("%s failed: IsAwsError must match the same error code", testName)
+	}
+}
+
+func TestCollector_retryMiddleware(t *testing.T) {
+	testName := "TestCollector_retryMiddleware"
+	var lock sync.Mutex
+	numRequests := 0
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		numRequests++
+		throttled := numRequests == 1
+		lock.Unlock()
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if throttled {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException","message":"throttled"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"Items":[]}`))
+	}))
+	defer stub.Close()
+
+	godynamo.RegisterNamedAWSConfig("metrics_test", aws.Config{Retryer: func() aws.Retryer {
+		return retry.NewStandard(func(o *retry.StandardOptions) {
+			o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+		})
+	}})
+	defer godynamo.DeregisterNamedAWSConfig("metrics_test")
+	c := NewCollector()
+	connector, err := godynamo.NewConnector("AwsConfig=metrics_test;Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint="+stub.URL, c)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	db := sql.OpenDB(connector)
+	defer func() { _ = db.Close() }()
+	if _, err = db.Exec(`INSERT INTO items VALUE {'id': ?}`, "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	snapshot := c.Snapshot()
+	if len(snapshot.Statements) != 1 {
+		t.Fatalf("%s failed: expected 1 statement metrics but received %d", testName, len(snapshot.Statements))
+	}
+	if m := snapshot.Statements[0]; m.Count != 1 || m.Retries != 1 || m.Throttles != 1 || len(m.Errors) != 0 {
+		t.Fatalf("%s failed: expected the throttled attempt to be counted but received %#v", testName, m)
+	}
+}
+
+func _collect() *Collector {
+	c := NewCollector()
+	ctx := context.Background()
+	throttled := awsError(&types.ProvisionedThroughputExceededException{})
+	selectInfo := &godynamo.StatementInfo{Kind: "SELECT", Table: "items"}
+	c.OnRetry(ctx, &godynamo.RetryInfo{Statement: selectInfo, Operation: "ExecuteStatement", Attempt: 2, Err: throttled})
+	selectInfo.Duration, selectInfo.ConsumedCapacity, selectInfo.ItemCount, selectInfo.PageCount = 30*time.Millisecond, 1.5, 10, 3
+	c.AfterStatement(ctx, selectInfo)
+	c.AfterStatement(ctx, &godynamo.StatementInfo{Kind: "SELECT", Table: "items", Duration: 2 * time.Second, ConsumedCapacity: 0.5, PageCount: 1})
+	c.AfterStatement(ctx, &godynamo.StatementInfo{Kind: "INSERT", Table: "items", Duration: time.Millisecond, ConsumedCapacity: 1, Err: throttled})
+	c.AfterStatement(ctx, &godynamo.StatementInfo{Kind: "INSERT", Table: "items", Duration: time.Millisecond, Err: errors.New("parse error")})
+	c.AfterStatement(ctx, &godynamo.StatementInfo{Kind: "DROP TABLE", Table: "other", Duration: time.Millisecond,
+		Err: awsError(&types.ResourceNotFoundException{})})
+	c.OnRetry(ctx, &godynamo.RetryInfo{Operation: "ExecuteTransaction", Attempt: 2, Err: throttled})
+	c.OnCommit(ctx, &godynamo.TxInfo{NumStatements: 2, Duration: 20 * time.Millisecond, ConsumedCapacity: 4})
+	c.OnCommit(ctx, &godynamo.TxInfo{NumStatements: 1, Duration: 20 * time.Millisecond, Err: awsError(&types.TransactionCanceledException{})})
+	c.OnRollback(ctx, &godynamo.TxInfo{NumStatements: 1})
+	return c
+}
+
+func TestCollector_Snapshot(t *testing.T) {
+	testName := "TestCollector_Snapshot"
+	snapshot := _collect().Snapshot()
+	if len(snapshot.Statements) != 3 {
+		t.Fatalf("%s failed: expected 3 statement metrics but received %d", testName, len(snapshot.Statements))
+	}
+	insert, sel, drop := snapshot.Statements[0], snapshot.Statements[1], snapshot.Statements[2]
+	if insert.Kind != "INSERT" || sel.Kind != "SELECT" || drop.Table != "other" {
+		t.Fatalf("%s failed: unexpected order %s/%s/%s", testName, insert.Kind, sel.Kind, drop.Kind)
+	}
+	if sel.Count != 2 || sel.Retries != 1 || sel.Throttles != 1 || sel.ReadCapacityUnits != 2 || sel.WriteCapacityUnits != 0 || len(sel.Errors) != 0 {
+		t.Fatalf("%s failed: unexpected SELECT metrics %#v", testName, sel)
+	}
+	if sel.Latency.Count != 2 || sel.Latency.Counts[3] != 1 || sel.Latency.Counts[len(sel.Latency.Counts)-1] != 2 || sel.Pages.Sum != 4 || sel.Pages.Counts[0] != 1 {
+		t.Fatalf("%s failed: unexpected SELECT histograms %#v %#v", testName, sel.Latency, sel.Pages)
+	}
+	if insert.Count != 2 || insert.Throttles != 1 || insert.WriteCapacityUnits != 1 || insert.Pages != nil ||
+		insert.Errors["ProvisionedThroughputExceededException"] != 1 || insert.Errors["Other"] != 1 {
+		t.Fatalf("%s failed: unexpected INSERT metrics %#v", testName, insert)
+	}
+	if drop.Errors["ResourceNotFoundException"] != 1 || drop.Throttles != 0 {
+		t.Fatalf("%s failed: unexpected DROP TABLE metrics %#v", testName, drop)
+	}
+	tx := snapshot.Tx
+	if tx.Commits != 2 || tx.CommitErrors["TransactionCanceledException"] != 1 || tx.Rollbacks != 1 || tx.Retries != 1 || tx.Throttles != 1 ||
+		tx.CapacityUnits != 4 || tx.Statements != 3 || tx.CommitLatency.Count != 2 || tx.RollbackLatency.Count != 1 {
+		t.Fatalf("%s failed: unexpected tx metrics %#v", testName, tx)
+	}
+}
+
+func TestCollector_Reset(t *testing.T) {
+	testName := "TestCollector_Reset"
+	c := _collect()
+	before := c.Snapshot()
+	c.Reset()
+	if snapshot := c.Snapshot(); len(snapshot.Statements) != 0 || snapshot.Tx.Commits != 0 {
+		t.Fatalf("%s failed: metrics not reset", testName)
+	}
+	if len(before.Statements) != 3 || before.Statements[1].Latency.Count != 2 {
+		t.Fatalf("%s failed: snapshots must not be affected by Reset", testName)
+	}
+}
+
+func TestCollector_Handler(t *testing.T) {
+	testName := "TestCollector_Handler"
+	c := _collect()
+	c.AfterStatement(context.Background(), &godynamo.StatementInfo{Kind: "SELECT", Table: `we"ird`})
+	recorder := httptest.NewRecorder()
+	c.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := recorder.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("%s failed: unexpected content type %s", testName, ct)
+	}
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		"# TYPE godynamo_statements_total counter\n",
+		`godynamo_statements_total{table="items",kind="SELECT"} 2` + "\n",
+		`godynamo_statement_errors_total{table="items",kind="INSERT",code="Other"} 1` + "\n",
+		`godynamo_statement_errors_total{table="items",kind="INSERT",code="ProvisionedThroughputExceededException"} 1` + "\n",
+		`godynamo_statement_throttles_total{table="items",kind="SELECT"} 1` + "\n",
+		`godynamo_consumed_read_capacity_units_total{table="items",kind="SELECT"} 2` + "\n",
+		`godynamo_consumed_write_capacity_units_total{table="items",kind="INSERT"} 1` + "\n",
+		`godynamo_statement_duration_seconds_bucket{table="items",kind="SELECT",le="0.05"} 1` + "\n",
+		`godynamo_statement_duration_seconds_bucket{table="items",kind="SELECT",le="+Inf"} 2` + "\n",
+		`godynamo_statement_duration_seconds_count{table="items",kind="SELECT"} 2` + "\n",
+		`godynamo_statement_pages_sum{table="items",kind="SELECT"} 4` + "\n",
+		`godynamo_statements_total{table="we\"ird",kind="SELECT"} 1` + "\n",
+		`godynamo_tx_commits_total{outcome="success"} 1` + "\n",
+		`godynamo_tx_commits_total{outcome="error"} 1` + "\n",
+		`godynamo_tx_commit_errors_total{code="TransactionCanceledException"} 1` + "\n",
+		"godynamo_tx_rollbacks_total 1\n",
+		"godynamo_tx_consumed_capacity_units_total 4\n",
+		`godynamo_tx_duration_seconds_count{op="commit"} 2` + "\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("%s failed: expected %q in\n%s", testName, expected, body)
+		}
+	}
+}
+
+func TestCollector_Publish(t *testing.T) {
+	testName := "TestCollector_Publish"
+	c := _collect()
+	c.Publish("godynamo_test_metrics")
+	v := expvar.Get("godynamo_test_metrics")
+	if v == nil {
+		t.Fatalf("%s failed: expvar not published", testName)
+	}
+	if s := v.String(); !strings.Contains(s, `"kind":"SELECT"`) || !strings.Contains(s, `"commits":2`) {
+		t.Fatalf("%s failed: unexpected expvar value %s", testName, s)
+	}
+}
+
+func TestEnable(t *testing.T) {
+	testName := "TestEnable"
+	defer godynamo.DeregisterHooks()
+	c := Enable()
+	if c == nil || Enable() != c {
+		t.Fatalf("%s failed: Enable must return the same collector", testName)
+	}
+	if expvar.Get(ExpvarName) == nil {
+		t.Fatalf("%s failed: expvar %s not published", testName, ExpvarName)
+	}
+}
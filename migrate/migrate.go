@@ -0,0 +1,488 @@
+// Package migrate provides a schema migration runner for AWS DynamoDB, built on top of the godynamo driver.
+//
+// Migrations are versioned .sql files (see LoadMigrations for the naming convention) containing statements supported by
+// godynamo, e.g. CREATE TABLE, CREATE GSI, INSERT, etc. Applied versions are recorded in a bookkeeping DynamoDB table;
+// a lock item, acquired via conditional write, prevents concurrent runs from applying migrations at the same time. The
+// lock is renewed while migrations are running, and versions are recorded only if the lock is still held.
+//
+// After each table/index DDL statement, the runner waits for the table/index to reach ACTIVE status (or to be deleted,
+// in case of DROP statements) before moving to the next statement.
+//
+// @Available since v1.4.0
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/btnguyen2k/godynamo"
+)
+
+const (
+	// DefaultTableName is the default name of the bookkeeping table.
+	DefaultTableName = "godynamo_migrations"
+
+	// DefaultLockTTL is the default duration after which an unreleased lock is considered stale.
+	DefaultLockTTL = 15 * time.Minute
+
+	// DefaultWaitTimeout is the default timeout to wait for a table/index to reach the desired status.
+	DefaultWaitTimeout = 10 * time.Minute
+
+	// DefaultPollInterval is the default interval between status checks.
+	DefaultPollInterval = 1 * time.Second
+
+	lockId = "__lock"
+
+	// unlockTimeout bounds the release of the lock, which does not use the caller's context as it may be done already.
+	unlockTimeout = 30 * time.Second
+)
+
+var (
+	// ErrLocked is returned when the migration lock is being held by another runner.
+	ErrLocked = errors.New("migration lock is being held by another runner")
+
+	// ErrLockLost is returned when the migration lock is no longer held by the runner, e.g. it could not be renewed in
+	// time and was taken over by another runner.
+	ErrLockLost = errors.New("migration lock is no longer held by this runner")
+
+	// ErrNoDownMigration is returned when reverting a migration that has no "down" file.
+	ErrNoDownMigration = errors.New("migration has no \"down\" statements")
+)
+
+var (
+	reCreateTable = regexp.MustCompile(`(?is)^CREATE\s+TABLE(\s+IF\s+NOT\s+EXISTS)?\s+([\w\-]+)`)
+	reAlterTable  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+([\w\-]+)`)
+	reDropTable   = regexp.MustCompile(`(?is)^(DROP|DELETE)\s+TABLE(\s+IF\s+EXISTS)?\s+([\w\-]+)`)
+	reCreateGSI   = regexp.MustCompile(`(?is)^CREATE\s+GSI(\s+IF\s+NOT\s+EXISTS)?\s+([\w\-]+)\s+ON\s+([\w\-]+)`)
+	reAlterGSI    = regexp.MustCompile(`(?is)^ALTER\s+GSI\s+([\w\-]+)\s+ON\s+([\w\-]+)`)
+	reDropGSI     = regexp.MustCompile(`(?is)^(DROP|DELETE)\s+GSI(\s+IF\s+EXISTS)?\s+([\w\-]+)\s+ON\s+([\w\-]+)`)
+)
+
+// Options holds the settings of a Migrator. Zero values are replaced by defaults.
+//
+// @Available since v1.4.0
+type Options struct {
+	TableName    string        // name of the bookkeeping table, default value is DefaultTableName
+	Owner        string        // identity of the runner, recorded in the lock item; default value is <hostname>:<pid>
+	LockTTL      time.Duration // lock not renewed nor released after this duration is considered stale and can be taken over, default value is DefaultLockTTL
+	WaitTimeout  time.Duration // timeout to wait for a table/index to reach the desired status, default value is DefaultWaitTimeout
+	PollInterval time.Duration // interval between status checks, default value is DefaultPollInterval
+	DryRun       bool          // if true, statements are printed to Output but not executed, and versions are not recorded
+	Output       io.Writer     // where progress messages are written to, nil means no output
+}
+
+// MigrationStatus describes whether a migration has been applied.
+//
+// @Available since v1.4.0
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time // zero if the migration has not been applied
+}
+
+// Migrator applies and reverts migrations against a database opened with the godynamo driver.
+//
+// @Available since v1.4.0
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+	opts       Options
+}
+
+// New creates a new Migrator that loads migrations from fsys (see LoadMigrations).
+//
+// @Available since v1.4.0
+func New(db *sql.DB, fsys fs.FS, opts *Options) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	m := &Migrator{db: db, migrations: migrations}
+	if opts != nil {
+		m.opts = *opts
+	}
+	if m.opts.TableName == "" {
+		m.opts.TableName = DefaultTableName
+	}
+	if m.opts.Owner == "" {
+		hostname, _ := os.Hostname()
+		m.opts.Owner = fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	}
+	if m.opts.LockTTL <= 0 {
+		m.opts.LockTTL = DefaultLockTTL
+	}
+	if m.opts.WaitTimeout <= 0 {
+		m.opts.WaitTimeout = DefaultWaitTimeout
+	}
+	if m.opts.PollInterval <= 0 {
+		m.opts.PollInterval = DefaultPollInterval
+	}
+	return m, nil
+}
+
+// NewFromDir creates a new Migrator that loads migrations from a directory.
+//
+// @Available since v1.4.0
+func NewFromDir(db *sql.DB, dir string, opts *Options) (*Migrator, error) {
+	return New(db, os.DirFS(dir), opts)
+}
+
+// Migrations returns the loaded migrations, sorted by version in ascending order.
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+func (m *Migrator) logf(format string, a ...interface{}) {
+	if m.opts.Output != nil {
+		_, _ = fmt.Fprintf(m.opts.Output, format+"\n", a...)
+	}
+}
+
+// ensureTable creates the bookkeeping table if it does not exist.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s WITH PK=id:string`, m.opts.TableName)); err != nil {
+		return err
+	}
+	return m.waitForTable(ctx, m.opts.TableName, "ACTIVE")
+}
+
+// appliedVersions returns the recorded versions, mapped to their applied time.
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int64]time.Time, error) {
+	// the bookkeeping table is small, scanning it is intended even if the DSN disallows full scans (AllowScan=false)
+	dbrows, err := m.db.QueryContext(ctx, fmt.Sprintf(`SELECT "id", "version", "applied_at" FROM "%s" WITH ALLOW_SCAN=true`, m.opts.TableName))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = dbrows.Close() }()
+	result := make(map[int64]time.Time)
+	for dbrows.Next() {
+		var id, version, appliedAt interface{}
+		if err := dbrows.Scan(&id, &version, &appliedAt); err != nil {
+			return nil, err
+		}
+		if id == lockId {
+			continue
+		}
+		v, ok := versionNumber(version)
+		if !ok {
+			continue
+		}
+		t := time.Time{}
+		if s, ok := appliedAt.(string); ok {
+			t, _ = time.Parse(time.RFC3339, s)
+		}
+		result[v] = t
+	}
+	return result, dbrows.Err()
+}
+
+// versionNumber converts a recorded version to int64. The type of the value depends on the DSN setting NumberMode:
+// float64, json.Number, string or *godynamo.BigNumber.
+func versionNumber(version interface{}) (int64, bool) {
+	switch v := version.(type) {
+	case nil:
+		return 0, false
+	case float64:
+		return int64(v), true
+	}
+	v, err := strconv.ParseInt(fmt.Sprint(version), 10, 64)
+	return v, err == nil
+}
+
+// lock acquires the migration lock. A stale lock (older than LockTTL) is taken over.
+func (m *Migrator) lock(ctx context.Context) error {
+	now := time.Now()
+	expiry := now.Add(m.opts.LockTTL).UnixMilli()
+	_, err := m.db.ExecContext(ctx, fmt.Sprintf(`INSERT INTO "%s" VALUE {'id': ?, 'owner': ?, 'expiry': ?}`, m.opts.TableName),
+		lockId, m.opts.Owner, expiry)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, godynamo.ErrDuplicateItem) {
+		return err
+	}
+	// lock item exists, try to take over a stale lock
+	result, err := m.db.ExecContext(ctx, fmt.Sprintf(`UPDATE "%s" SET "owner"=? SET "expiry"=? WHERE "id"=? AND "expiry"<?`, m.opts.TableName),
+		m.opts.Owner, expiry, lockId, now.UnixMilli())
+	if err != nil {
+		return err
+	}
+	if numRows, err := result.RowsAffected(); err != nil || numRows == 0 {
+		return ErrLocked
+	}
+	return nil
+}
+
+// renewLock extends the expiry of the migration lock, if it is still being held by this runner.
+func (m *Migrator) renewLock(ctx context.Context) error {
+	result, err := m.db.ExecContext(ctx, fmt.Sprintf(`UPDATE "%s" SET "expiry"=? WHERE "id"=? AND "owner"=?`, m.opts.TableName),
+		time.Now().Add(m.opts.LockTTL).UnixMilli(), lockId, m.opts.Owner)
+	if err != nil {
+		return err
+	}
+	if numRows, err := result.RowsAffected(); err != nil || numRows == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// keepLock renews the migration lock every LockTTL/3 until ctx is done. If the lock is lost, onLost is called and the
+// renewal stops; other errors are retried at the next renewal.
+func (m *Migrator) keepLock(ctx context.Context, onLost func()) {
+	ticker := time.NewTicker(m.opts.LockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.renewLock(ctx); errors.Is(err, ErrLockLost) {
+				onLost()
+				return
+			}
+		}
+	}
+}
+
+// execHoldingLock executes a statement recording or unrecording a migration in the same transaction as the renewal of
+// the lock, so that the statement takes effect only if the lock is still being held by this runner.
+func (m *Migrator) execHoldingLock(ctx context.Context, query string, args ...interface{}) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, query, args...); err == nil {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`UPDATE "%s" SET "expiry"=? WHERE "id"=? AND "owner"=? WITH STRICT=true`, m.opts.TableName),
+			time.Now().Add(m.opts.LockTTL).UnixMilli(), lockId, m.opts.Owner)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); errors.Is(err, godynamo.ErrConditionFailed) {
+		return ErrLockLost
+	}
+	return err
+}
+
+// unlock releases the migration lock if it is being held by this runner. The caller's context is not used, so that the
+// lock is released even if the run was canceled.
+func (m *Migrator) unlock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+	defer cancel()
+	_, err := m.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM "%s" WHERE "id"=? AND "owner"=?`, m.opts.TableName), lockId, m.opts.Owner)
+	return err
+}
+
+func (m *Migrator) waitForTable(ctx context.Context, tableName string, status ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.opts.WaitTimeout)
+	defer cancel()
+	return godynamo.WaitForTableStatus(ctx, m.db, tableName, status, m.opts.PollInterval)
+}
+
+func (m *Migrator) waitForGSI(ctx context.Context, tableName, indexName string, status ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.opts.WaitTimeout)
+	defer cancel()
+	return godynamo.WaitForGSIStatus(ctx, m.db, tableName, indexName, status, m.opts.PollInterval)
+}
+
+// waitForStatement waits until the table/index affected by a DDL statement reaches its final status.
+// Non-DDL statements return immediately.
+func (m *Migrator) waitForStatement(ctx context.Context, stmt string) error {
+	if groups := reCreateTable.FindStringSubmatch(stmt); groups != nil {
+		return m.waitForTable(ctx, groups[2], "ACTIVE")
+	}
+	if groups := reAlterTable.FindStringSubmatch(stmt); groups != nil {
+		return m.waitForTable(ctx, groups[1], "ACTIVE")
+	}
+	if groups := reDropTable.FindStringSubmatch(stmt); groups != nil {
+		return m.waitForTable(ctx, groups[3], "")
+	}
+	if groups := reCreateGSI.FindStringSubmatch(stmt); groups != nil {
+		if err := m.waitForGSI(ctx, groups[3], groups[2], "ACTIVE"); err != nil {
+			return err
+		}
+		return m.waitForTable(ctx, groups[3], "ACTIVE")
+	}
+	if groups := reAlterGSI.FindStringSubmatch(stmt); groups != nil {
+		if err := m.waitForGSI(ctx, groups[2], groups[1], "ACTIVE"); err != nil {
+			return err
+		}
+		return m.waitForTable(ctx, groups[2], "ACTIVE")
+	}
+	if groups := reDropGSI.FindStringSubmatch(stmt); groups != nil {
+		if err := m.waitForGSI(ctx, groups[4], groups[3], ""); err != nil {
+			return err
+		}
+		return m.waitForTable(ctx, groups[4], "ACTIVE")
+	}
+	return nil
+}
+
+func (m *Migrator) runStatements(ctx context.Context, mig *Migration, direction string, stmts []string) error {
+	for i, stmt := range stmts {
+		m.logf("[%d/%s] %s", mig.Version, direction, stmt)
+		if m.opts.DryRun {
+			continue
+		}
+		if _, err := m.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %d (%s), %s statement #%d failed: %w", mig.Version, mig.Name, direction, i+1, err)
+		}
+		if err := m.waitForStatement(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %d (%s), %s statement #%d: error waiting for status: %w", mig.Version, mig.Name, direction, i+1, err)
+		}
+	}
+	return nil
+}
+
+// withLock prepares the bookkeeping table, acquires the lock and invokes f with the set of applied versions.
+// In dry-run mode, the bookkeeping table is neither created nor locked.
+//
+// The lock is renewed while f runs; if it is lost, the context passed to f is canceled and ErrLockLost is returned.
+func (m *Migrator) withLock(ctx context.Context, f func(ctx context.Context, applied map[int64]time.Time) error) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !m.opts.DryRun {
+		if err = m.ensureTable(ctx); err != nil {
+			return err
+		}
+		if err = m.lock(ctx); err != nil {
+			return err
+		}
+		var lost int32
+		lockCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			m.keepLock(lockCtx, func() {
+				atomic.StoreInt32(&lost, 1)
+				cancel()
+			})
+		}()
+		defer func() {
+			cancel()
+			<-done
+			if atomic.LoadInt32(&lost) != 0 && !errors.Is(err, ErrLockLost) {
+				err = fmt.Errorf("%w: %v", ErrLockLost, err)
+			}
+			if e := m.unlock(); e != nil && err == nil {
+				err = e
+			}
+		}()
+		ctx = lockCtx
+	}
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		if !m.opts.DryRun || !errors.Is(err, godynamo.ErrTableNotFound) {
+			return err
+		}
+		applied = make(map[int64]time.Time)
+	}
+	return f(ctx, applied)
+}
+
+// Up applies all pending migrations in ascending version order.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.UpTo(ctx, -1)
+}
+
+// UpTo applies pending migrations with version less than or equal to the target version, in ascending version order.
+// A negative target version means "all pending migrations".
+func (m *Migrator) UpTo(ctx context.Context, targetVersion int64) error {
+	return m.withLock(ctx, func(ctx context.Context, applied map[int64]time.Time) error {
+		for _, mig := range m.migrations {
+			if targetVersion >= 0 && mig.Version > targetVersion {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := m.runStatements(ctx, mig, "up", mig.Up); err != nil {
+				return err
+			}
+			if m.opts.DryRun {
+				continue
+			}
+			err := m.execHoldingLock(ctx, fmt.Sprintf(`INSERT INTO "%s" VALUE {'id': ?, 'version': ?, 'name': ?, 'applied_at': ?}`, m.opts.TableName),
+				fmt.Sprintf("v%d", mig.Version), mig.Version, mig.Name, time.Now().UTC().Format(time.RFC3339))
+			if err != nil {
+				return fmt.Errorf("migration %d (%s) applied but could not be recorded: %w", mig.Version, mig.Name, err)
+			}
+			m.logf("[%d/up] applied", mig.Version)
+		}
+		return nil
+	})
+}
+
+// Down reverts the last steps applied migrations, in descending version order.
+// A non-positive steps value means 1.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		steps = 1
+	}
+	return m.withLock(ctx, func(ctx context.Context, applied map[int64]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			steps--
+			if mig.DownFile == "" {
+				return fmt.Errorf("migration %d (%s): %w", mig.Version, mig.Name, ErrNoDownMigration)
+			}
+			if err := m.runStatements(ctx, mig, "down", mig.Down); err != nil {
+				return err
+			}
+			if m.opts.DryRun {
+				continue
+			}
+			err := m.execHoldingLock(ctx, fmt.Sprintf(`DELETE FROM "%s" WHERE "id"=?`, m.opts.TableName), fmt.Sprintf("v%d", mig.Version))
+			if err != nil {
+				return fmt.Errorf("migration %d (%s) reverted but could not be unrecorded: %w", mig.Version, mig.Name, err)
+			}
+			m.logf("[%d/down] reverted", mig.Version)
+		}
+		return nil
+	})
+}
+
+// Status returns the status of all loaded migrations, sorted by version in ascending order.
+//
+// Status does not create the bookkeeping table; if the table does not exist, all migrations are reported as not applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	applied, err := m.appliedVersions(ctx)
+	if err != nil && !errors.Is(err, godynamo.ErrTableNotFound) {
+		return nil, err
+	}
+	result := make([]MigrationStatus, len(m.migrations))
+	for i, mig := range m.migrations {
+		result[i] = MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if t, ok := applied[mig.Version]; ok {
+			result[i].Applied = true
+			result[i].AppliedAt = t
+		}
+	}
+	return result, nil
+}
+
+// String implements fmt.Stringer.
+func (s MigrationStatus) String() string {
+	if !s.Applied {
+		return fmt.Sprintf("%d_%s: pending", s.Version, s.Name)
+	}
+	return fmt.Sprintf("%d_%s: applied at %s", s.Version, s.Name, s.AppliedAt.Format(time.RFC3339))
+}
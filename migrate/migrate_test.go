@@ -0,0 +1,283 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/btnguyen2k/godynamo"
+)
+
+// _newBookkeepingStub returns a stub DynamoDB endpoint whose bookkeeping table holds the lock item and version 1, and
+// records the executed statements.
+func _newBookkeepingStub(statements *[]string) *httptest.Server {
+	var lock sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		switch r.Header.Get("X-Amz-Target") {
+		case "DynamoDB_20120810.ExecuteStatement":
+			statement, _ := input["Statement"].(string)
+			lock.Lock()
+			*statements = append(*statements, statement)
+			lock.Unlock()
+			_, _ = w.Write([]byte(`{"Items":[` +
+				`{"id":{"S":"__lock"},"owner":{"S":"someone"},"expiry":{"N":"1"}},` +
+				`{"id":{"S":"v1"},"version":{"N":"1"},"name":{"S":"init"},"applied_at":{"S":"2024-01-02T03:04:05Z"}}]}`))
+		case "DynamoDB_20120810.DescribeTable":
+			_, _ = w.Write([]byte(`{"Table":{"TableName":"godynamo_migrations","TableStatus":"ACTIVE",` +
+				`"KeySchema":[{"AttributeName":"id","KeyType":"HASH"}],"AttributeDefinitions":[{"AttributeName":"id","AttributeType":"S"}]}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"com.amazon.coral.validate#ValidationException","message":"unsupported"}`))
+		}
+	}))
+}
+
+// _newLockStub returns a stub DynamoDB endpoint with an empty bookkeeping table, which records the executed statements
+// (transactions are recorded as their statements joined by " | "). Statements on table "slow" take slowDuration to
+// complete; while *lost is non-zero, renewing the lock fails as if it had been taken over by another runner.
+func _newLockStub(statements *[]string, slowDuration time.Duration, lost *int32) *httptest.Server {
+	var lock sync.Mutex
+	record := func(statement string) {
+		lock.Lock()
+		*statements = append(*statements, statement)
+		lock.Unlock()
+	}
+	fail := func(w http.ResponseWriter, body string) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(body))
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		switch r.Header.Get("X-Amz-Target") {
+		case "DynamoDB_20120810.CreateTable":
+			fail(w, `{"__type":"com.amazonaws.dynamodb.v20120810#ResourceInUseException","message":"table exists"}`)
+		case "DynamoDB_20120810.DescribeTable":
+			_, _ = w.Write([]byte(`{"Table":{"TableName":"godynamo_migrations","TableStatus":"ACTIVE",` +
+				`"KeySchema":[{"AttributeName":"id","KeyType":"HASH"}],"AttributeDefinitions":[{"AttributeName":"id","AttributeType":"S"}]}}`))
+		case "DynamoDB_20120810.ExecuteStatement":
+			statement, _ := input["Statement"].(string)
+			record(statement)
+			switch {
+			case strings.Contains(statement, `"slow"`):
+				select {
+				case <-time.After(slowDuration):
+				case <-r.Context().Done():
+				}
+				_, _ = w.Write([]byte(`{"Items":[{}]}`))
+			case strings.HasPrefix(statement, "SELECT"):
+				_, _ = w.Write([]byte(`{"Items":[]}`))
+			case strings.Contains(statement, `SET "expiry"=? WHERE`) && atomic.LoadInt32(lost) != 0:
+				fail(w, `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"condition failed"}`)
+			default:
+				_, _ = w.Write([]byte(`{"Items":[{}]}`))
+			}
+		case "DynamoDB_20120810.ExecuteTransaction":
+			var txStatements []string
+			items, _ := input["TransactStatements"].([]interface{})
+			for _, item := range items {
+				statement, _ := item.(map[string]interface{})["Statement"].(string)
+				txStatements = append(txStatements, statement)
+			}
+			record(strings.Join(txStatements, " | "))
+			if atomic.LoadInt32(lost) != 0 {
+				fail(w, `{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException","message":"transaction canceled",`+
+					`"CancellationReasons":[{"Code":"None"},{"Code":"ConditionalCheckFailed","Message":"condition failed"}]}`)
+				return
+			}
+			_, _ = w.Write([]byte(`{"Responses":[{},{}]}`))
+		default:
+			fail(w, `{"__type":"com.amazon.coral.validate#ValidationException","message":"unsupported"}`)
+		}
+	}))
+}
+
+// _countStatements returns the number of recorded statements containing substr.
+func _countStatements(statements []string, substr string) int {
+	count := 0
+	for _, statement := range statements {
+		if strings.Contains(statement, substr) {
+			count++
+		}
+	}
+	return count
+}
+
+const (
+	_renewLockStmt   = `UPDATE "godynamo_migrations" SET "expiry"=? WHERE "id"=? AND "owner"=?`
+	_releaseLockStmt = `DELETE FROM "godynamo_migrations" WHERE "id"=? AND "owner"=?`
+)
+
+func TestMigrator_Up_renewLock(t *testing.T) {
+	testName := "TestMigrator_Up_renewLock"
+	var statements []string
+	var lost int32
+	stub := _newLockStub(&statements, 300*time.Millisecond, &lost)
+	defer stub.Close()
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint="+stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+	fsys := fstest.MapFS{"0001_init.up.sql": {Data: []byte(`UPDATE "slow" SET "a"=1 WHERE "id"='x';`)}}
+	m, err := New(db, fsys, &Options{LockTTL: 90 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err = m.Up(context.Background()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if n := _countStatements(statements, _renewLockStmt+" RETURNING"); n < 3 {
+		t.Fatalf("%s failed: expected the lock to be renewed while the migration runs but received %#v", testName, statements)
+	}
+	if n := _countStatements(statements, `INSERT INTO "godynamo_migrations" VALUE {'id': ?, 'version': ?, 'name': ?, 'applied_at': ?} | `+_renewLockStmt); n != 1 {
+		t.Fatalf("%s failed: expected the version to be recorded conditionally on holding the lock but received %#v", testName, statements)
+	}
+	if !strings.HasPrefix(statements[len(statements)-1], _releaseLockStmt) {
+		t.Fatalf("%s failed: expected the lock to be released but received %#v", testName, statements)
+	}
+}
+
+func TestMigrator_Up_lockLost(t *testing.T) {
+	testName := "TestMigrator_Up_lockLost"
+	testData := map[string]time.Duration{"heartbeat": 5 * time.Second, "record": 0}
+	for name, slowDuration := range testData {
+		var statements []string
+		lost := int32(1)
+		stub := _newLockStub(&statements, slowDuration, &lost)
+		db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint="+stub.URL)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+name, err)
+		}
+		fsys := fstest.MapFS{"0001_init.up.sql": {Data: []byte(`UPDATE "slow" SET "a"=1 WHERE "id"='x';`)}}
+		m, err := New(db, fsys, &Options{LockTTL: 90 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+name, err)
+		}
+		start := time.Now()
+		if err = m.Up(context.Background()); !errors.Is(err, ErrLockLost) {
+			t.Fatalf("%s failed: expected ErrLockLost but received %#v", testName+"/"+name, err)
+		}
+		if d := time.Since(start); d >= slowDuration && slowDuration > 0 {
+			t.Fatalf("%s failed: the migration must be canceled once the lock is lost but it took %s", testName+"/"+name, d)
+		}
+		if !strings.HasPrefix(statements[len(statements)-1], _releaseLockStmt) {
+			t.Fatalf("%s failed: expected the lock to be released but received %#v", testName+"/"+name, statements)
+		}
+		_ = db.Close()
+		stub.Close()
+	}
+}
+
+func TestMigrator_Up_unlockCanceled(t *testing.T) {
+	testName := "TestMigrator_Up_unlockCanceled"
+	var statements []string
+	var lost int32
+	stub := _newLockStub(&statements, 5*time.Second, &lost)
+	defer stub.Close()
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint="+stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+	fsys := fstest.MapFS{"0001_init.up.sql": {Data: []byte(`UPDATE "slow" SET "a"=1 WHERE "id"='x';`)}}
+	m, err := New(db, fsys, nil)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err = m.Up(ctx); err == nil {
+		t.Fatalf("%s failed: expected the migration to fail as its context is done", testName)
+	}
+	if !strings.HasPrefix(statements[len(statements)-1], _releaseLockStmt) {
+		t.Fatalf("%s failed: expected the lock to be released even though the context is done but received %#v", testName, statements)
+	}
+}
+
+func TestMigrator_Status_scanNotAllowed(t *testing.T) {
+	testName := "TestMigrator_Status_scanNotAllowed"
+	var statements []string
+	stub := _newBookkeepingStub(&statements)
+	defer stub.Close()
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;AllowScan=false;Endpoint="+stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+	fsys := fstest.MapFS{
+		"0001_init.up.sql": {Data: []byte("CREATE TABLE t1 WITH PK=id:string;")},
+		"0002_next.up.sql": {Data: []byte("CREATE TABLE t2 WITH PK=id:string;")},
+	}
+	m, err := New(db, fsys, nil)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	status, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(status) != 2 || !status[0].Applied || status[1].Applied {
+		t.Fatalf("%s failed: expected version 1 applied and version 2 pending but received %v", testName, status)
+	}
+	if len(statements) != 1 || !strings.Contains(statements[0], "FROM \"godynamo_migrations\"") {
+		t.Fatalf("%s failed: unexpected statements %#v", testName, statements)
+	}
+}
+
+func TestMigrator_Status_numberMode(t *testing.T) {
+	testName := "TestMigrator_Status_numberMode"
+	fsys := fstest.MapFS{
+		"0001_init.up.sql": {Data: []byte("CREATE TABLE t1 WITH PK=id:string;")},
+		"0002_next.up.sql": {Data: []byte("CREATE TABLE t2 WITH PK=id:string;")},
+	}
+	for _, mode := range []string{"float", "json.Number", "string", "big"} {
+		var statements []string
+		stub := _newBookkeepingStub(&statements)
+		db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;NumberMode="+mode+";Endpoint="+stub.URL)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+mode, err)
+		}
+		m, err := New(db, fsys, nil)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+mode, err)
+		}
+		status, err := m.Status(context.Background())
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+mode, err)
+		}
+		if len(status) != 2 || !status[0].Applied || status[1].Applied {
+			t.Fatalf("%s failed: expected version 1 applied and version 2 pending but received %v", testName+"/"+mode, status)
+		}
+		_ = db.Close()
+		stub.Close()
+	}
+}
+
+func Test_versionNumber(t *testing.T) {
+	testName := "Test_versionNumber"
+	testData := []interface{}{float64(20240102030405), json.Number("20240102030405"), "20240102030405"}
+	for _, version := range testData {
+		if v, ok := versionNumber(version); !ok || v != 20240102030405 {
+			t.Fatalf("%s failed: expected 20240102030405 for %#v but received %d", testName, version, v)
+		}
+	}
+	for _, version := range []interface{}{nil, "abc", "1.5"} {
+		if _, ok := versionNumber(version); ok {
+			t.Fatalf("%s failed: %#v must not be a valid version", testName, version)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	reMigrationFile = regexp.MustCompile(`^(\d+)_(.*?)\.(up|down)\.sql$`)
+	reLineComment   = regexp.MustCompile(`^\s*--`)
+)
+
+// Migration holds the statements of a versioned migration.
+//
+// @Available since v1.4.0
+type Migration struct {
+	Version  int64    // version number, parsed from the file name
+	Name     string   // descriptive name, parsed from the file name
+	Up       []string // statements to apply the migration
+	Down     []string // statements to revert the migration
+	UpFile   string   // name of the "up" file
+	DownFile string   // name of the "down" file
+}
+
+// LoadMigrations reads versioned migration files from the root directory of fsys.
+//
+// Migration files are expected to be named <version>_<name>.up.sql and <version>_<name>.down.sql, for example:
+//
+//		0001_create_table_users.up.sql
+//		0001_create_table_users.down.sql
+//
+//	  - A file can contain multiple statements, each statement must be terminated by a semicolon at the end of a line.
+//	  - Lines starting with "--" are treated as comments and ignored.
+//	  - The "down" file is optional; a migration without "down" file can not be reverted.
+//	  - Files not matching the naming convention are ignored.
+//
+// Returned migrations are sorted by version in ascending order.
+//
+// @Available since v1.4.0
+func LoadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		groups := reMigrationFile.FindStringSubmatch(entry.Name())
+		if groups == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(groups[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in migration file <%s>: %s", entry.Name(), err)
+		}
+		m := migrations[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: groups[2]}
+			migrations[version] = m
+		} else if m.Name != groups[2] {
+			return nil, fmt.Errorf("conflict migration names for version %d: <%s> and <%s>", version, m.Name, groups[2])
+		}
+		content, err := fs.ReadFile(fsys, path.Join(".", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		stmts, err := SplitStatements(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing migration file <%s>: %s", entry.Name(), err)
+		}
+		if groups[3] == "up" {
+			if m.UpFile != "" {
+				return nil, fmt.Errorf("duplicated \"up\" file for version %d", version)
+			}
+			m.UpFile, m.Up = entry.Name(), stmts
+		} else {
+			if m.DownFile != "" {
+				return nil, fmt.Errorf("duplicated \"down\" file for version %d", version)
+			}
+			m.DownFile, m.Down = entry.Name(), stmts
+		}
+	}
+
+	result := make([]*Migration, 0, len(migrations))
+	for _, m := range migrations {
+		if m.UpFile == "" {
+			return nil, fmt.Errorf("missing \"up\" file for version %d", m.Version)
+		}
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// SplitStatements splits the content of a migration file into statements.
+//
+// A statement is terminated by a semicolon at the end of a line (trailing spaces are allowed), or by the end of the content.
+// Semicolons inside quoted strings do not terminate a statement. Lines starting with "--" are ignored.
+//
+// @Available since v1.4.0
+func SplitStatements(content string) ([]string, error) {
+	stmts := make([]string, 0)
+	buf := strings.Builder{}
+	var quote rune
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if quote == 0 && reLineComment.MatchString(line) {
+			continue
+		}
+		for _, r := range line {
+			switch {
+			case quote != 0 && r == quote:
+				quote = 0
+			case quote == 0 && (r == '\'' || r == '"'):
+				quote = r
+			}
+		}
+		trimmed := strings.TrimRight(line, " \t")
+		if quote == 0 && strings.HasSuffix(trimmed, ";") {
+			buf.WriteString(strings.TrimSuffix(trimmed, ";"))
+			if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			buf.Reset()
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quoted string")
+	}
+	if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitStatements(t *testing.T) {
+	testName := "TestSplitStatements"
+	testCases := []struct {
+		name      string
+		content   string
+		expected  []string
+		mustError bool
+	}{
+		{name: "empty", content: "", expected: []string{}},
+		{name: "single_no_semicolon", content: "LIST TABLES", expected: []string{"LIST TABLES"}},
+		{name: "single", content: "LIST TABLES;\n", expected: []string{"LIST TABLES"}},
+		{name: "multiple", content: "CREATE TABLE t1 WITH PK=id:string;\nCREATE TABLE t2 WITH PK=id:string ; \n",
+			expected: []string{"CREATE TABLE t1 WITH PK=id:string", "CREATE TABLE t2 WITH PK=id:string"}},
+		{name: "multi_lines", content: "CREATE TABLE t1\nWITH PK=id:string\nWITH SK=name:string;",
+			expected: []string{"CREATE TABLE t1\nWITH PK=id:string\nWITH SK=name:string"}},
+		{name: "comments", content: "-- create table\nCREATE TABLE t1 WITH PK=id:string;\n  -- the end",
+			expected: []string{"CREATE TABLE t1 WITH PK=id:string"}},
+		{name: "semicolon_in_string", content: "INSERT INTO \"t1\" VALUE {'id': 'a;\nb;'};",
+			expected: []string{"INSERT INTO \"t1\" VALUE {'id': 'a;\nb;'}"}},
+		{name: "semicolon_mid_line", content: "CREATE TABLE t1 WITH PK=id:string WITH LSI=idx:a:string:b;c,d;",
+			expected: []string{"CREATE TABLE t1 WITH PK=id:string WITH LSI=idx:a:string:b;c,d"}},
+		{name: "unterminated_string", content: "INSERT INTO \"t1\" VALUE {'id': 'a};", mustError: true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			stmts, err := SplitStatements(testCase.content)
+			if testCase.mustError && err == nil {
+				t.Fatalf("%s failed: expected error", testName+"/"+testCase.name)
+			}
+			if testCase.mustError {
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if !reflect.DeepEqual(stmts, testCase.expected) {
+				t.Fatalf("%s failed: expected %#v but received %#v", testName+"/"+testCase.name, testCase.expected, stmts)
+			}
+		})
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	testName := "TestLoadMigrations"
+	fsys := fstest.MapFS{
+		"0002_create_gsi.up.sql":     {Data: []byte("CREATE GSI idx ON t1 WITH PK=email:string;")},
+		"0002_create_gsi.down.sql":   {Data: []byte("DROP GSI idx ON t1;")},
+		"0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t1 WITH PK=id:string;\nCREATE TABLE t2 WITH PK=id:string;")},
+		"0010_seed.up.sql":           {Data: []byte(`INSERT INTO "t1" VALUE {'id': 'admin'};`)},
+		"README.md":                  {Data: []byte("not a migration")},
+		"0001_create_table.down.sql": {Data: []byte("DROP TABLE t2;\nDROP TABLE t1;")},
+	}
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(migrations) != 3 {
+		t.Fatalf("%s failed: expected %d migrations but received %d", testName, 3, len(migrations))
+	}
+	expectedVersions := []int64{1, 2, 10}
+	expectedNames := []string{"create_table", "create_gsi", "seed"}
+	expectedNumUp := []int{2, 1, 1}
+	expectedNumDown := []int{2, 1, 0}
+	for i, m := range migrations {
+		if m.Version != expectedVersions[i] || m.Name != expectedNames[i] || len(m.Up) != expectedNumUp[i] || len(m.Down) != expectedNumDown[i] {
+			t.Fatalf("%s failed: unexpected migration #%d: %#v", testName, i, m)
+		}
+	}
+	if migrations[2].DownFile != "" {
+		t.Fatalf("%s failed: expected no down file but received %#v", testName, migrations[2].DownFile)
+	}
+}
+
+func TestLoadMigrations_invalid(t *testing.T) {
+	testName := "TestLoadMigrations_invalid"
+	testCases := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{name: "missing_up", fsys: fstest.MapFS{"0001_t.down.sql": {Data: []byte("DROP TABLE t;")}}},
+		{name: "name_conflict", fsys: fstest.MapFS{
+			"0001_a.up.sql": {Data: []byte("CREATE TABLE a WITH PK=id:string;")},
+			"0001_b.up.sql": {Data: []byte("CREATE TABLE b WITH PK=id:string;")},
+		}},
+		{name: "unterminated_string", fsys: fstest.MapFS{"0001_t.up.sql": {Data: []byte("INSERT INTO \"t\" VALUE {'id': 'a};")}}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := LoadMigrations(testCase.fsys); err == nil {
+				t.Fatalf("%s failed: expected error", testName+"/"+testCase.name)
+			}
+		})
+	}
+}
@@ -3,7 +3,7 @@ package godynamo
 
 const (
 	// Version holds the semantic version number of this module.
-	Version = "1.3.0"
+	Version = "1.4.0"
 )
 
 // This file contains module's metadata only, which is package level documentation and module Version string.
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
@@ -68,6 +69,7 @@ func Test_OpenDatabase_With_AWSConfig_Endpoint(t *testing.T) {
 		BaseEndpoint: aws.String(cfgEndpoint),
 	})
 	{
+		// since v1.4.0, the DSN's Endpoint (if specified) takes precedence over the AWSConfig's endpoint
 		db, err := sql.Open(dbdriver, dsn)
 		if err != nil {
 			t.Fatalf("%s failed: %s", testName+"/open", err)
@@ -76,6 +78,19 @@ func Test_OpenDatabase_With_AWSConfig_Endpoint(t *testing.T) {
 		if err == nil {
 			t.Fatalf("%s failed: expected error", testName+"/query")
 		}
+		if strings.Index(err.Error(), fmt.Sprintf(`"%s"`, dsnEndpoint)) < 0 {
+			t.Fatalf("%s failed: expected error message to contain [%s], but received [%s]", testName, dsnEndpoint, err)
+		}
+	}
+	{
+		db, err := sql.Open(dbdriver, "Region=dummy-region;AkId=dummy-key-id;SecretKey=dummy-key")
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/open", err)
+		}
+		_, err = db.QueryContext(context.Background(), "LIST TABLES")
+		if err == nil {
+			t.Fatalf("%s failed: expected error", testName+"/query")
+		}
 		if strings.Index(err.Error(), fmt.Sprintf(`"%s"`, cfgEndpoint)) < 0 {
 			t.Fatalf("%s failed: expected error message to contain [%s], but received [%s]", testName, cfgEndpoint, err)
 		}
@@ -197,3 +212,82 @@ func TestDriver_Open_With_AWSConfig(t *testing.T) {
 		t.Fatalf("%s failed: %s", testName, err)
 	}
 }
+
+type _countingHooks struct {
+	godynamo.NopHooks
+	statements []godynamo.StatementInfo
+}
+
+func (h *_countingHooks) AfterStatement(_ context.Context, info *godynamo.StatementInfo) {
+	h.statements = append(h.statements, *info)
+}
+
+func TestConnector_Hooks(t *testing.T) {
+	testName := "TestConnector_Hooks"
+	db := _openDb(t, testName)
+	_initTest(db)
+	defer func() { _ = db.Close() }()
+
+	url := strings.ReplaceAll(os.Getenv("AWS_DYNAMODB_URL"), `"`, "")
+	hooks := &_countingHooks{}
+	connector, err := godynamo.NewConnector(url, hooks)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/NewConnector", err)
+	}
+	dbHooks := sql.OpenDB(connector)
+	defer func() { _ = dbHooks.Close() }()
+
+	if _, err = dbHooks.Exec(`CREATE TABLE ` + tblTestTemp + ` WITH PK=id:string WITH rcu=1 WITH wcu=1`); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/create_table", err)
+	}
+	if _, err = dbHooks.Exec(`INSERT INTO `+tblTestTemp+` VALUE {'id': ?}`, "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/insert", err)
+	}
+	dbrows, err := dbHooks.Query(`SELECT * FROM `+tblTestTemp+` WHERE id=?`, "a")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/select", err)
+	}
+	_ = dbrows.Close()
+
+	expected := []string{"CREATE TABLE", "INSERT", "SELECT"}
+	if len(hooks.statements) != len(expected) {
+		t.Fatalf("%s failed: expected %d statements but received %#v", testName, len(expected), hooks.statements)
+	}
+	for i, kind := range expected {
+		info := hooks.statements[i]
+		if info.Kind != kind || info.Table != tblTestTemp || info.Err != nil {
+			t.Fatalf("%s failed: expected %s on %s but received %#v", testName, kind, tblTestTemp, info)
+		}
+	}
+	if info := hooks.statements[2]; info.ItemCount != 1 || info.PageCount != 1 {
+		t.Fatalf("%s failed: expected 1 item/1 page but received %d/%d", testName+"/select", info.ItemCount, info.PageCount)
+	}
+}
+
+func TestErrors_Sentinels(t *testing.T) {
+	testName := "TestErrors_Sentinels"
+	db := _openDb(t, testName)
+	_initTest(db)
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.Exec(`DROP TABLE ` + tblTestTemp); !errors.Is(err, godynamo.ErrTableNotFound) {
+		t.Fatalf("%s failed: expected ErrTableNotFound but received %s", testName+"/drop_table", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE ` + tblTestTemp + ` WITH PK=id:string WITH rcu=1 WITH wcu=1`); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/create_table", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE ` + tblTestTemp + ` WITH PK=id:string WITH rcu=1 WITH wcu=1`); !errors.Is(err, godynamo.ErrTableExists) {
+		t.Fatalf("%s failed: expected ErrTableExists but received %s", testName+"/create_table", err)
+	}
+	if _, err := db.Exec(`INSERT INTO `+tblTestTemp+` VALUE {'id': ?}`, "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/insert", err)
+	}
+	if _, err := db.Exec(`INSERT INTO `+tblTestTemp+` VALUE {'id': ?}`, "a"); !errors.Is(err, godynamo.ErrDuplicateItem) {
+		t.Fatalf("%s failed: expected ErrDuplicateItem but received %s", testName+"/insert", err)
+	}
+	_, err := db.Exec(`SELEKT * FROM ` + tblTestTemp)
+	var parseErr *godynamo.ParseError
+	if !errors.As(err, &parseErr) || parseErr.Position != 0 {
+		t.Fatalf("%s failed: expected *ParseError but received %#v", testName+"/parse", err)
+	}
+}
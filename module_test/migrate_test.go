@@ -0,0 +1,82 @@
+package godynamo_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/btnguyen2k/godynamo"
+	"github.com/btnguyen2k/godynamo/migrate"
+)
+
+const tblTestMigrations = "test_migrations"
+
+func Test_Migrate(t *testing.T) {
+	testName := "Test_Migrate"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	_initTest(db)
+	_, _ = db.Exec(`DROP TABLE IF EXISTS ` + tblTestMigrations)
+	_ = godynamo.WaitForTableStatus(nil, db, tblTestMigrations, []string{""}, 100*time.Millisecond)
+
+	fsys := fstest.MapFS{
+		"0001_create_table.up.sql":   {Data: []byte(fmt.Sprintf("CREATE TABLE %s WITH PK=id:string WITH rcu=1 WITH wcu=1;", tblTestTemp))},
+		"0001_create_table.down.sql": {Data: []byte(fmt.Sprintf("DROP TABLE %s;", tblTestTemp))},
+		"0002_create_gsi.up.sql":     {Data: []byte(fmt.Sprintf("CREATE GSI idxemail ON %s WITH PK=email:string WITH rcu=1 WITH wcu=1;", tblTestTemp))},
+		"0002_create_gsi.down.sql":   {Data: []byte(fmt.Sprintf("DROP GSI idxemail ON %s;", tblTestTemp))},
+		"0003_seed.up.sql":           {Data: []byte(fmt.Sprintf(`INSERT INTO "%s" VALUE {'id': 'admin', 'email': 'admin@local'};`, tblTestTemp))},
+		"0003_seed.down.sql":         {Data: []byte(fmt.Sprintf(`DELETE FROM "%s" WHERE id='admin';`, tblTestTemp))},
+	}
+	opts := &migrate.Options{TableName: tblTestMigrations, PollInterval: 100 * time.Millisecond, WaitTimeout: 10 * time.Second}
+
+	// dry-run must not touch the database
+	out := &bytes.Buffer{}
+	dryRunOpts := *opts
+	dryRunOpts.DryRun, dryRunOpts.Output = true, out
+	m, err := migrate.New(db, fsys, &dryRunOpts)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/New", err)
+	}
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/dry-run", err)
+	}
+	if !strings.Contains(out.String(), "CREATE GSI idxemail") {
+		t.Fatalf("%s failed: expected dry-run output to contain statements, received %s", testName+"/dry-run", out.String())
+	}
+	dbrows, err := db.Query(`DESCRIBE TABLE ` + tblTestTemp)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/describe", err)
+	}
+	if rows, _ := _fetchAllRows(dbrows); len(rows) != 0 {
+		t.Fatalf("%s failed: dry-run must not create table", testName+"/dry-run")
+	}
+
+	m, _ = migrate.New(db, fsys, opts)
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/up", err)
+	}
+	status, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/status", err)
+	}
+	for _, s := range status {
+		if !s.Applied {
+			t.Fatalf("%s failed: expected migration %d to be applied", testName+"/status", s.Version)
+		}
+	}
+	if err := godynamo.WaitForGSIStatus(nil, db, tblTestTemp, "idxemail", []string{"ACTIVE"}, 0); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/gsi", err)
+	}
+
+	if err := m.Down(context.Background(), 2); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/down", err)
+	}
+	status, _ = m.Status(context.Background())
+	if !status[0].Applied || status[1].Applied || status[2].Applied {
+		t.Fatalf("%s failed: unexpected status after down %v", testName+"/down", status)
+	}
+}
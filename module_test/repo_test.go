@@ -0,0 +1,81 @@
+package godynamo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/btnguyen2k/godynamo/repo"
+)
+
+type repoSession struct {
+	App     string   `dynamodbav:"app" godynamo:"pk"`
+	User    string   `dynamodbav:"user" godynamo:"sk"`
+	Os      string   `dynamodbav:"os,omitempty"`
+	Tags    []string `dynamodbav:"tags,stringset,omitempty"`
+	Version int64    `dynamodbav:"version" godynamo:"version"`
+}
+
+func Test_Repository(t *testing.T) {
+	testName := "Test_Repository"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	_initTest(db)
+
+	if _, err := db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH PK=app:string WITH SK=user:string WITH rcu=5 WITH wcu=5`, tblTestTemp)); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/create_table", err)
+	}
+	r, err := repo.New[repoSession](db, tblTestTemp)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/New", err)
+	}
+	ctx := context.Background()
+
+	item := &repoSession{App: "app0", User: "user0", Os: "linux", Tags: []string{"a", "b"}}
+	if err = r.Put(ctx, item); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Put", err)
+	}
+	if err = r.Put(ctx, &repoSession{App: "app0", User: "user1"}); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Put", err)
+	}
+	fetched, err := r.Get(ctx, "app0", "user0")
+	if err != nil || !reflect.DeepEqual(fetched, item) {
+		t.Fatalf("%s failed: expected %#v but received %#v/%s", testName+"/Get", item, fetched, err)
+	}
+	if _, err = r.Get(ctx, "app0", "user9"); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("%s failed: expected ErrNotFound but received %v", testName+"/Get", err)
+	}
+
+	stale := *fetched
+	fetched.Os = ""
+	if err = r.Update(ctx, fetched); err != nil || fetched.Version != 2 {
+		t.Fatalf("%s failed: %s / version %d", testName+"/Update", err, fetched.Version)
+	}
+	if err = r.Update(ctx, &stale); !errors.Is(err, repo.ErrVersionConflict) {
+		t.Fatalf("%s failed: expected ErrVersionConflict but received %v", testName+"/Update", err)
+	}
+
+	items, err := r.Query(ctx, `"app"=?`, "app0")
+	if err != nil || len(items) != 2 || !reflect.DeepEqual(items[0], *fetched) {
+		t.Fatalf("%s failed: unexpected result %#v/%s", testName+"/Query", items, err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Begin", err)
+	}
+	if err = r.WithTx(tx).Delete(ctx, fetched); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Delete", err)
+	}
+	if err = r.WithTx(tx).DeleteByKey(ctx, "app0", "user1"); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/DeleteByKey", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Commit", err)
+	}
+	if items, err = r.Query(ctx, `"app"=?`, "app0"); err != nil || len(items) != 0 {
+		t.Fatalf("%s failed: expected no item but received %#v/%s", testName+"/Query", items, err)
+	}
+}
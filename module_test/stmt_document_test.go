@@ -1,9 +1,13 @@
 package godynamo_test
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/aws/smithy-go"
 	"github.com/btnguyen2k/godynamo"
+	"math/big"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
@@ -23,6 +27,45 @@ func Test_Query_Insert(t *testing.T) {
 	}
 }
 
+func Test_Query_Insert_Returning(t *testing.T) {
+	testName := "Test_Query_Insert_Returning"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	_initTest(db)
+
+	_, _ = db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH pk=id:string WITH rcu=1 WITH wcu=1`, tblTestTemp))
+
+	testData := []struct {
+		name     string
+		sql      string
+		params   []interface{}
+		expected []map[string]interface{}
+	}{
+		{name: "all_new", sql: `INSERT INTO "%s" VALUE {'id': ?, 'grade': ?, 'tags': <<'a'>>} RETURNING ALL NEW *`, params: []interface{}{"1", 1},
+			expected: []map[string]interface{}{{"id": "1", "grade": 1.0, "tags": []string{"a"}}}},
+		{name: "item_param", sql: `INSERT INTO "%s" VALUE ? RETURNING MODIFIED NEW *`, params: []interface{}{map[string]interface{}{"id": "2", "active": true}},
+			expected: []map[string]interface{}{{"id": "2", "active": true}}},
+		{name: "all_old", sql: `INSERT INTO "%s" VALUE {'id': ?} RETURNING ALL OLD *`, params: []interface{}{"3"},
+			expected: []map[string]interface{}{}},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			dbrows, err := db.Query(fmt.Sprintf(testCase.sql, tblTestTemp), testCase.params...)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			rows, err := _fetchAllRows(dbrows)
+			if err != nil || !reflect.DeepEqual(rows, testCase.expected) {
+				t.Fatalf("%s failed: expected %#v but received %#v/%s", testName+"/"+testCase.name, testCase.expected, rows, err)
+			}
+		})
+	}
+
+	if _, err := db.Query(fmt.Sprintf(`INSERT INTO "%s" VALUE {'id': ?} RETURNING ALL NEW *`, tblTestTemp), "1"); err == nil {
+		t.Fatalf("%s failed: inserting an existing item must fail", testName+"/duplicated")
+	}
+}
+
 func Test_Exec_Insert(t *testing.T) {
 	testName := "Test_Exec_Insert"
 	db := _openDb(t, testName)
@@ -40,6 +83,14 @@ func Test_Exec_Insert(t *testing.T) {
 		{name: "basic", sql: fmt.Sprintf(`INSERT INTO "%s" VALUE {'id': '1', 'name': 'User 1'}`, tblTestTemp), affectedRows: 1},
 		{name: "parameterized", sql: fmt.Sprintf(`INSERT INTO "%s" VALUE {'id': ?, 'name': ?, 'active': ?, 'grade': ?, 'list': ?, 'map': ?}`, tblTestTemp), affectedRows: 1,
 			params: []interface{}{"2", "User 2", true, 10, []interface{}{1.2, false, "3"}, map[string]interface{}{"N": -3.4, "B": false, "S": "3"}}},
+		{name: "struct", sql: fmt.Sprintf(`INSERT INTO "%s" VALUE ?`, tblTestTemp), affectedRows: 1,
+			params: []interface{}{struct {
+				Id    string `dynamodbav:"id"`
+				Name  string `dynamodbav:"name,omitempty"`
+				Grade int    `dynamodbav:"grade"`
+			}{Id: "3", Grade: 10}}},
+		{name: "map", sql: fmt.Sprintf(`INSERT INTO "%s" VALUE ?`, tblTestTemp), affectedRows: 1,
+			params: []interface{}{map[string]interface{}{"id": "4", "name": "User 4", "list": []interface{}{1.2, false, "3"}}}},
 	}
 
 	for _, testCase := range testData {
@@ -162,6 +213,234 @@ func Test_Query_Select_withLimit(t *testing.T) {
 	}
 }
 
+func Test_Query_Select_withParallel(t *testing.T) {
+	testName := "Test_Query_Select_withParallel"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	_initTest(db)
+
+	_, err := db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH PK=app:string WITH SK=user:string WITH rcu=5 WITH wcu=5`, tblTestTemp))
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	numItems := 50
+	for i := 0; i < numItems; i++ {
+		_, err = db.Exec(fmt.Sprintf(`INSERT INTO "%s" VALUE {'app': ?, 'user': ?, 'active': ?, 'duration': ?}`, tblTestTemp), fmt.Sprintf("app%d", i%5), fmt.Sprintf("user%02d", i), i%2 == 0, i)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/insert", err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		sql      string
+		params   []interface{}
+		expected int
+	}{
+		{name: "all", sql: `SELECT * FROM "%s" WITH PARALLEL=4`, expected: numItems},
+		{name: "filter", sql: `SELECT * FROM "%s" WHERE active=? AND duration>=? WITH PARALLEL=3`, params: []interface{}{true, 10}, expected: 20},
+		{name: "filter_or", sql: `SELECT "user" FROM "%s" WHERE app='app1' OR begins_with("user", 'user0') WITH ConsistentRead=true, WITH PARALLEL=2`, expected: 18},
+		{name: "limit", sql: `SELECT * FROM "%s" LIMIT 7 WITH PARALLEL=4`, expected: 7},
+	}
+	for _, testCase := range testCases {
+		dbresult, err := db.Query(fmt.Sprintf(testCase.sql, tblTestTemp), testCase.params...)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+		}
+		rows, err := _fetchAllRows(dbresult)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+		}
+		if len(rows) != testCase.expected {
+			t.Fatalf("%s failed: expected %#v rows but received %#v", testName+"/"+testCase.name, testCase.expected, len(rows))
+		}
+	}
+
+	if _, err = db.Query(fmt.Sprintf(`SELECT * FROM "%s" ORDER BY "user" WITH PARALLEL=2`, tblTestTemp)); err == nil {
+		t.Fatalf("%s failed: ORDER BY must be rejected", testName+"/order_by")
+	}
+}
+
+func Test_Query_Select_AllowScan(t *testing.T) {
+	testName := "Test_Query_Select_AllowScan"
+	db := _openDb(t, testName)
+	_initTest(db)
+	_ = db.Close()
+
+	url := strings.ReplaceAll(os.Getenv("AWS_DYNAMODB_URL"), `"`, "")
+	db, err := sql.Open("godynamo", url+";AllowScan=false")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/sql.Open", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	_, err = db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH PK=app:string WITH SK=user:string WITH LSI=idx_os:os:string WITH rcu=5 WITH wcu=5`, tblTestTemp))
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	_, _ = db.Exec(fmt.Sprintf(`INSERT INTO "%s" VALUE {'app': 'app', 'user': 'user1', 'os': 'Linux'}`, tblTestTemp))
+
+	testCases := []struct {
+		name      string
+		sql       string
+		params    []interface{}
+		mustError bool
+	}{
+		{name: "get_item", sql: `SELECT * FROM "%s" WHERE app=? AND "user"=?`, params: []interface{}{"app", "user1"}},
+		{name: "query", sql: `SELECT * FROM "%s" WHERE app=? AND os<>'Windows'`, params: []interface{}{"app"}},
+		{name: "query_lsi", sql: `SELECT * FROM "%s"."idx_os" WHERE app=? AND os=?`, params: []interface{}{"app", "Linux"}},
+		{name: "scan_allowed", sql: `SELECT * FROM "%s" WHERE os=? WITH ALLOW_SCAN=true`, params: []interface{}{"Linux"}},
+		{name: "scan", sql: `SELECT * FROM "%s" WHERE os=?`, params: []interface{}{"Linux"}, mustError: true},
+		{name: "scan_no_where", sql: `SELECT * FROM "%s"`, mustError: true},
+	}
+	for _, testCase := range testCases {
+		dbresult, err := db.Query(fmt.Sprintf(testCase.sql, tblTestTemp), testCase.params...)
+		if testCase.mustError {
+			if !errors.Is(err, godynamo.ErrScanNotAllowed) || !strings.Contains(err.Error(), tblTestTemp) || !strings.Contains(err.Error(), "<app>") {
+				t.Fatalf("%s failed: expected ErrScanNotAllowed naming table and partition key but received %v", testName+"/"+testCase.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+		}
+		rows, err := _fetchAllRows(dbresult)
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("%s failed: expected 1 row but received %d (%v)", testName+"/"+testCase.name, len(rows), err)
+		}
+	}
+}
+
+func Test_Query_Explain(t *testing.T) {
+	testName := "Test_Query_Explain"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	_initTest(db)
+
+	_, err := db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH PK=app:string WITH SK=user:string WITH LSI=idx_os:os:string WITH rcu=5 WITH wcu=5`, tblTestTemp))
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	testCases := []struct {
+		name       string
+		sql        string
+		params     []interface{}
+		accessPath string
+		indexType  string
+	}{
+		{name: "get_item", sql: `EXPLAIN SELECT * FROM "%s" WHERE app=? AND "user"=?`, params: []interface{}{"app", "user1"}, accessPath: "GetItem"},
+		{name: "query", sql: `EXPLAIN SELECT * FROM "%s" WHERE app=? AND os<>'Windows'`, params: []interface{}{"app"}, accessPath: "Query"},
+		{name: "query_lsi", sql: `EXPLAIN SELECT * FROM "%s"."idx_os" WHERE app=? AND os=?`, params: []interface{}{"app", "Linux"}, accessPath: "Query", indexType: "LSI"},
+		{name: "scan", sql: `EXPLAIN SELECT * FROM "%s" WHERE os=?`, params: []interface{}{"Linux"}, accessPath: "Scan"},
+	}
+	for _, testCase := range testCases {
+		dbresult, err := db.Query(fmt.Sprintf(testCase.sql, tblTestTemp), testCase.params...)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+		}
+		rows, err := _fetchAllRows(dbresult)
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("%s failed: expected 1 row but received %d (%v)", testName+"/"+testCase.name, len(rows), err)
+		}
+		if rows[0]["AccessPath"] != testCase.accessPath || rows[0]["IndexType"] != testCase.indexType || rows[0]["TableName"] != tblTestTemp {
+			t.Fatalf("%s failed: unexpected result %#v", testName+"/"+testCase.name, rows[0])
+		}
+	}
+}
+
+func Test_Query_Select_NumberMode(t *testing.T) {
+	testName := "Test_Query_Select_NumberMode"
+	db := _openDb(t, testName)
+	_initTest(db)
+	_ = db.Close()
+
+	url := strings.ReplaceAll(os.Getenv("AWS_DYNAMODB_URL"), `"`, "")
+	for _, mode := range []string{"json.Number", "string", "big"} {
+		db, err := sql.Open("godynamo", url+";NumberMode="+mode)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/sql.Open", err)
+		}
+		_, _ = db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s WITH PK=id:string WITH rcu=5 WITH wcu=5`, tblTestTemp))
+		_, _ = db.Exec(fmt.Sprintf(`INSERT INTO "%s" VALUE {'id': '1', 'n': 9007199254740993, 'doc': {'n': 9007199254740993}}`, tblTestTemp))
+
+		row := db.QueryRow(fmt.Sprintf(`SELECT n, doc FROM "%s" WHERE id='1'`, tblTestTemp))
+		if mode == "big" {
+			var n *big.Float
+			var doc map[string]interface{}
+			if err := row.Scan(&n, &doc); err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+mode, err)
+			}
+			if n.Text('f', 0) != "9007199254740993" || doc["n"].(*godynamo.BigNumber).String() != "9007199254740993" {
+				t.Fatalf("%s failed: precision lost %v / %v", testName+"/"+mode, n, doc)
+			}
+		} else {
+			var n int64
+			var doc map[string]interface{}
+			if err := row.Scan(&n, &doc); err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+mode, err)
+			}
+			if n != 9007199254740993 || fmt.Sprintf("%v", doc["n"]) != "9007199254740993" {
+				t.Fatalf("%s failed: precision lost %v / %v", testName+"/"+mode, n, doc)
+			}
+		}
+		_ = db.Close()
+	}
+}
+
+func Test_Query_Select_ScanStruct(t *testing.T) {
+	testName := "Test_Query_Select_ScanStruct"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	_initTest(db)
+
+	type session struct {
+		App      string   `dynamodbav:"app"`
+		User     string   `dynamodbav:"user"`
+		Os       string   `dynamodbav:"os,omitempty"`
+		Duration int64    `dynamodbav:"duration,omitempty"`
+		Tags     []string `dynamodbav:"tags,stringset,omitempty"`
+	}
+	_, err := db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH PK=app:string WITH SK=user:string WITH rcu=5 WITH wcu=5`, tblTestTemp))
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	_, _ = db.Exec(fmt.Sprintf(`INSERT INTO "%s" VALUE {'app': 'app0', 'user': 'user0', 'os': 'linux', 'duration': 9007199254740993}`, tblTestTemp))
+	_, _ = db.Exec(fmt.Sprintf(`INSERT INTO "%s" VALUE {'app': 'app0', 'user': 'user1', 'tags': <<'a', 'b'>>}`, tblTestTemp))
+	expected := []session{
+		{App: "app0", User: "user0", Os: "linux", Duration: 9007199254740993},
+		{App: "app0", User: "user1", Tags: []string{"a", "b"}},
+	}
+
+	dbrows, err := db.Query(fmt.Sprintf(`SELECT * FROM "%s" WHERE app=? WITH RAW_ITEM=true`, tblTestTemp), "app0")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/select", err)
+	}
+	var sessions []session
+	if err = godynamo.ScanAll(dbrows, &sessions); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/ScanAll", err)
+	}
+	if !reflect.DeepEqual(sessions, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName+"/ScanAll", expected, sessions)
+	}
+
+	dbrows, err = db.Query(fmt.Sprintf(`SELECT * FROM "%s" WHERE app=? AND "user"=?`, tblTestTemp), "app0", "user1")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/select", err)
+	}
+	defer func() { _ = dbrows.Close() }()
+	if !dbrows.Next() {
+		t.Fatalf("%s failed: no row returned", testName+"/ScanStruct")
+	}
+	var s session
+	if err = godynamo.ScanStruct(dbrows, &s); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/ScanStruct", err)
+	}
+	if !reflect.DeepEqual(s, expected[1]) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName+"/ScanStruct", expected[1], s)
+	}
+}
+
 func Test_Query_Select_with_columns_selection(t *testing.T) {
 	testName := "Test_Query_Select_with_columns_selection"
 	db := _openDb(t, testName)
@@ -377,6 +656,80 @@ func Test_Exec_Update(t *testing.T) {
 	}
 }
 
+func Test_Exec_Update_Version(t *testing.T) {
+	testName := "Test_Exec_Update_Version"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	_initTest(db)
+
+	_, _ = db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH PK=app:string WITH SK=user:string WITH rcu=5 WITH wcu=5`, tblTestTemp))
+	_, err := db.Exec(fmt.Sprintf(`INSERT INTO "%s" VALUE {'app': ?, 'user': ?, 'os': ?, 'version': ?}`, tblTestTemp), "app0", "user0", "Linux", 1)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/insert", err)
+	}
+
+	sql := fmt.Sprintf(`UPDATE "%s" SET os=? WHERE "app"=? AND "user"=? WITH VERSION=version`, tblTestTemp)
+	result, err := db.Exec(sql, "Ubuntu", "app0", "user0", 1)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/update", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected != 1 {
+		t.Fatalf("%s failed: expected 1 row affected but received %#v/%s", testName+"/rows_affected", rowsAffected, err)
+	}
+
+	// stale version
+	if _, err = db.Exec(sql, "Windows", "app0", "user0", 1); !errors.Is(err, godynamo.ErrVersionConflict) {
+		t.Fatalf("%s failed: expected ErrVersionConflict but received %v", testName+"/update", err)
+	}
+	// item does not exist
+	if _, err = db.Exec(sql, "Windows", "app0", "user1", 1); !errors.Is(err, godynamo.ErrVersionConflict) {
+		t.Fatalf("%s failed: expected ErrVersionConflict but received %v", testName+"/update", err)
+	}
+
+	var os string
+	var version float64
+	row := db.QueryRow(fmt.Sprintf(`SELECT os, version FROM "%s" WHERE "app"=? AND "user"=?`, tblTestTemp), "app0", "user0")
+	if err = row.Scan(&os, &version); err != nil || os != "Ubuntu" || version != 2 {
+		t.Fatalf("%s failed: unexpected item %#v/%#v/%s", testName+"/select", os, version, err)
+	}
+}
+
+func Test_Exec_UpdateDelete_Strict(t *testing.T) {
+	testName := "Test_Exec_UpdateDelete_Strict"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	_initTest(db)
+
+	_, _ = db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH PK=app:string WITH SK=user:string WITH rcu=5 WITH wcu=5`, tblTestTemp))
+	_, err := db.Exec(fmt.Sprintf(`INSERT INTO "%s" VALUE {'app': ?, 'user': ?, 'os': ?}`, tblTestTemp), "app0", "user0", "Linux")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/insert", err)
+	}
+
+	var condErr *godynamo.ConditionFailedError
+	_, err = db.Exec(fmt.Sprintf(`UPDATE "%s" SET os=? WHERE "app"=? AND "user"=? AND os=? WITH STRICT=true`, tblTestTemp), "Ubuntu", "app0", "user0", "Windows")
+	if !errors.As(err, &condErr) || !errors.Is(err, godynamo.ErrConditionFailed) {
+		t.Fatalf("%s failed: expected ConditionFailedError but received %v", testName+"/update", err)
+	}
+	if os, ok := condErr.Item["os"].(*types.AttributeValueMemberS); !ok || os.Value != "Linux" {
+		t.Fatalf("%s failed: expected current item but received %#v", testName+"/update", condErr.Item)
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`DELETE FROM "%s" WHERE "app"=? AND "user"=? AND os=? WITH STRICT=true`, tblTestTemp), "app0", "user0", "Windows")
+	if !errors.As(err, &condErr) || condErr.Item == nil {
+		t.Fatalf("%s failed: expected ConditionFailedError but received %v", testName+"/delete", err)
+	}
+
+	// without STRICT, failed condition check results in 0 affected row
+	result, err := db.Exec(fmt.Sprintf(`DELETE FROM "%s" WHERE "app"=? AND "user"=? AND os=?`, tblTestTemp), "app0", "user0", "Windows")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/delete", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected != 0 {
+		t.Fatalf("%s failed: expected 0 row affected but received %#v/%s", testName+"/delete", rowsAffected, err)
+	}
+}
+
 func Test_Query_Update(t *testing.T) {
 	testName := "Test_Query_Update"
 	db := _openDb(t, testName)
@@ -0,0 +1,91 @@
+package godynamo_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btnguyen2k/godynamo"
+)
+
+func Test_Export(t *testing.T) {
+	testName := "Test_Export"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	_initTest(db)
+
+	if _, err := db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH pk=id:string WITH rcu=5 WITH wcu=5`, tblTestTemp)); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/create_table", err)
+	}
+	numItems := 25
+	for i := 0; i < numItems; i++ {
+		_, err := db.Exec(fmt.Sprintf(`INSERT INTO "%s" VALUE {'id': ?, 'num': ?, 'tags': <<'a', 'b'>>}`, tblTestTemp), fmt.Sprintf("%03d", i), i)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/insert", err)
+		}
+	}
+	_, _ = db.Exec(fmt.Sprintf(`INSERT INTO "%s" VALUE {'id': 'big', 'num': 12345678901234567890}`, tblTestTemp))
+
+	testCases := []struct {
+		format   string
+		limit    int
+		expected int
+		check    func(t *testing.T, output string)
+	}{
+		{format: godynamo.ExportFormatJsonl, expected: numItems + 1, check: func(t *testing.T, output string) {
+			if !strings.Contains(output, `"num":12345678901234567890`) {
+				t.Fatalf("%s failed: number precision lost\n%s", testName+"/jsonl", output)
+			}
+		}},
+		{format: godynamo.ExportFormatDDBJson, limit: 10, expected: 10, check: func(t *testing.T, output string) {
+			for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+				var item map[string]map[string]map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &item); err != nil {
+					t.Fatalf("%s failed: %s", testName+"/ddbjson", err)
+				}
+				if _, ok := item["Item"]["tags"]["SS"]; !ok {
+					t.Fatalf("%s failed: expected typed string set, received %s", testName+"/ddbjson", line)
+				}
+			}
+		}},
+		{format: godynamo.ExportFormatCsv, expected: numItems + 1, check: func(t *testing.T, output string) {
+			if header := strings.SplitN(output, "\n", 2)[0]; header != "id,num,tags" {
+				t.Fatalf("%s failed: unexpected header %s", testName+"/csv", header)
+			}
+		}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.format, func(t *testing.T) {
+			query := fmt.Sprintf(`SELECT * FROM "%s"`, tblTestTemp)
+			if testCase.limit > 0 {
+				query += fmt.Sprintf(" LIMIT %d", testCase.limit)
+			}
+			buf := &bytes.Buffer{}
+			n, err := godynamo.Export(context.Background(), db, query, buf, testCase.format)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.format, err)
+			}
+			if n != int64(testCase.expected) {
+				t.Fatalf("%s failed: expected %d items but received %d", testName+"/"+testCase.format, testCase.expected, n)
+			}
+			testCase.check(t, buf.String())
+
+			// EXPORT statement must produce the same output
+			fileName := filepath.Join(t.TempDir(), "export."+strings.ToLower(testCase.format))
+			result, err := db.Exec(fmt.Sprintf(`EXPORT (%s) TO '%s' FORMAT %s`, query, fileName, testCase.format))
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.format+"/stmt", err)
+			}
+			if n, _ := result.RowsAffected(); n != int64(testCase.expected) {
+				t.Fatalf("%s failed: expected %d items but received %d", testName+"/"+testCase.format+"/stmt", testCase.expected, n)
+			}
+			content, _ := os.ReadFile(fileName)
+			testCase.check(t, string(content))
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package godynamo_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/btnguyen2k/godynamo"
+)
+
+func Test_Import(t *testing.T) {
+	testName := "Test_Import"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	_initTest(db)
+
+	if _, err := db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH pk=id:string WITH rcu=5 WITH wcu=5`, tblTestTemp)); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/create_table", err)
+	}
+
+	numItems := 60
+	lines := make([]string, 0, numItems+2)
+	for i := 0; i < numItems; i++ {
+		lines = append(lines, fmt.Sprintf(`{"id":"%03d","num":%d,"tags":["a","b"]}`, i, i))
+	}
+	lines = append(lines, `{"num":1}`, `not a json`)
+	var numProgress int64
+	result, err := godynamo.Import(context.Background(), db, tblTestTemp, strings.NewReader(strings.Join(lines, "\n")), godynamo.ImportFormatJsonl, &godynamo.ImportOptions{
+		Workers:   3,
+		TypeHints: map[string]string{"tags": "SS"},
+		Progress:  func(_ godynamo.ImportProgress) { atomic.AddInt64(&numProgress, 1) },
+	})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/jsonl", err)
+	}
+	if result.Read != int64(numItems+2) || result.Imported != int64(numItems) || len(result.Rejected) != 2 {
+		t.Fatalf("%s failed: unexpected result %#v", testName+"/jsonl", result)
+	}
+	if numProgress == 0 {
+		t.Fatalf("%s failed: progress callback was not called", testName+"/jsonl")
+	}
+	var tagsType string
+	row := db.QueryRow(fmt.Sprintf(`SELECT tags FROM "%s" WHERE id=?`, tblTestTemp), "001")
+	var tags interface{}
+	if err := row.Scan(&tags); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/select", err)
+	}
+	if tagsType = fmt.Sprintf("%T", tags); tagsType != "[]interface {}" && tagsType != "[]string" {
+		t.Fatalf("%s failed: unexpected tags value %#v", testName+"/select", tags)
+	}
+
+	// IMPORT statement
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "import.csv")
+	rejectedFile := filepath.Join(dir, "rejected.jsonl")
+	_ = os.WriteFile(fileName, []byte("id,num\nx1,1\nx2,2\n,3\nx4,abc\n"), 0600)
+	execResult, err := db.Exec(fmt.Sprintf(`IMPORT INTO %s FROM '%s' FORMAT CSV WITH TYPE=num:N WITH WORKERS=2 WITH REJECTED_FILE=%s`, tblTestTemp, fileName, rejectedFile))
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/import_stmt", err)
+	}
+	if affectedRows, err := execResult.RowsAffected(); err != nil || affectedRows != 2 {
+		t.Fatalf("%s failed: expected 2 affected rows but received %d (%s)", testName+"/import_stmt", affectedRows, err)
+	}
+	report, _ := os.ReadFile(rejectedFile)
+	if numLines := len(strings.Split(strings.TrimSpace(string(report)), "\n")); numLines != 2 {
+		t.Fatalf("%s failed: expected 2 rejected rows but received:\n%s", testName+"/import_stmt", report)
+	}
+}
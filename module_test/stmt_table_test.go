@@ -1,11 +1,18 @@
 package godynamo_test
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/btnguyen2k/godynamo"
 )
 
 func Test_Query_CreateTable(t *testing.T) {
@@ -121,6 +128,86 @@ func Test_Query_ListTables(t *testing.T) {
 	}
 }
 
+func Test_TablePrefix(t *testing.T) {
+	testName := "Test_TablePrefix"
+	db := _openDb(t, testName)
+	_initTest(db)
+	defer func() { _ = db.Close() }()
+
+	url := strings.ReplaceAll(os.Getenv("AWS_DYNAMODB_URL"), `"`, "")
+	prefix := tblTestTemp + "_"
+	dbPrefix, err := sql.Open("godynamo", url+";TablePrefix="+prefix)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/sql.Open", err)
+	}
+	defer func() { _ = dbPrefix.Close() }()
+	_, _ = dbPrefix.Exec(`DROP TABLE IF EXISTS tbl`)
+	_ = godynamo.WaitForTableStatus(nil, dbPrefix, "tbl", []string{""}, 100*time.Millisecond)
+	defer func() { _, _ = dbPrefix.Exec(`DROP TABLE IF EXISTS tbl`) }()
+
+	if _, err = dbPrefix.Exec(`CREATE TABLE tbl WITH PK=id:string WITH rcu=1 WITH wcu=1`); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/create_table", err)
+	}
+	if _, err = dbPrefix.Exec(`INSERT INTO tbl VALUE {'id': 'a', 'note': 'INSERT INTO tbl'}`); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/insert", err)
+	}
+	if _, err = dbPrefix.Exec(`UPDATE "tbl" SET note=? WHERE id=?`, "updated", "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/update", err)
+	}
+
+	// the physical table has the prefix
+	dbrows, err := db.Query(`SELECT * FROM "`+prefix+`tbl" WHERE id=?`, "a")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/select_physical", err)
+	}
+	if rows, _ := _fetchAllRows(dbrows); len(rows) != 1 || rows[0]["note"] != "updated" {
+		t.Fatalf("%s failed: unexpected rows %#v", testName+"/select_physical", rows)
+	}
+
+	// the application sees the logical table name
+	dbrows, err = dbPrefix.Query(`SELECT * FROM tbl WHERE id=?`, "a")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/select", err)
+	}
+	if rows, _ := _fetchAllRows(dbrows); len(rows) != 1 {
+		t.Fatalf("%s failed: unexpected rows %#v", testName+"/select", rows)
+	}
+	dbrows, err = dbPrefix.Query(`LIST TABLES`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/list_tables", err)
+	}
+	if rows, _ := _fetchAllRows(dbrows); len(rows) != 1 || rows[0]["$1"] != "tbl" {
+		t.Fatalf("%s failed: expected only table tbl but received %#v", testName+"/list_tables", rows)
+	}
+	dbrows, err = dbPrefix.Query(`DESCRIBE TABLE tbl`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/describe_table", err)
+	}
+	if rows, _ := _fetchAllRows(dbrows); len(rows) != 1 || rows[0]["TableName"] != "tbl" {
+		t.Fatalf("%s failed: expected logical table name but received %#v", testName+"/describe_table", rows)
+	}
+
+	if _, err = dbPrefix.Exec(`DELETE FROM tbl WHERE id=?`, "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/delete", err)
+	}
+}
+
+func Test_Query_ListTables_Timeout(t *testing.T) {
+	testName := "Test_Query_ListTables_Timeout"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.Query(`LIST TABLES WITH TIMEOUT=5000`); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/timeout_5000", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.QueryContext(ctx, `LIST TABLES WITH TIMEOUT=5000`); !errors.Is(err, context.Canceled) {
+		t.Fatalf("%s failed: expected context.Canceled but received %v", testName+"/canceled", err)
+	}
+}
+
 func Test_Query_AlterTable(t *testing.T) {
 	testName := "Test_Query_AlterTable"
 	db := _openDb(t, testName)
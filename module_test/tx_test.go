@@ -3,14 +3,18 @@ package godynamo_test
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"github.com/btnguyen2k/godynamo"
+	"os"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 func TestTx_Empty(t *testing.T) {
@@ -29,6 +33,47 @@ func TestTx_Empty(t *testing.T) {
 	}
 }
 
+func TestTx_ReadOnly(t *testing.T) {
+	testName := "TestTx_ReadOnly"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	if err := _txPrepareData(db, tblTestTemp); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/prepare", err)
+	}
+
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/BeginTx", err)
+	}
+	if _, err = tx.Exec(fmt.Sprintf(`UPDATE "%s" SET grade=? WHERE id=?`, tblTestTemp), 0, "1"); !errors.Is(err, godynamo.ErrReadOnly) {
+		t.Fatalf("%s failed: expected ErrReadOnly but received %v", testName+"/update", err)
+	}
+	if _, err = tx.Prepare(fmt.Sprintf(`SELECT * FROM "%s" WHERE id=?`, tblTestTemp)); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/select", err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Rollback", err)
+	}
+
+	// the connection is writable again once the read-only transaction ends
+	if _, err = db.Exec(fmt.Sprintf(`UPDATE "%s" SET grade=? WHERE id=?`, tblTestTemp), 0, "1"); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/update_after_tx", err)
+	}
+
+	url := strings.ReplaceAll(os.Getenv("AWS_DYNAMODB_URL"), `"`, "")
+	dbReadOnly, err := sql.Open("godynamo", url+";ReadOnly=true")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/sql.Open", err)
+	}
+	defer func() { _ = dbReadOnly.Close() }()
+	if _, err = dbReadOnly.Exec(fmt.Sprintf(`DROP TABLE %s`, tblTestTemp)); !errors.Is(err, godynamo.ErrReadOnly) {
+		t.Fatalf("%s failed: expected ErrReadOnly but received %v", testName+"/drop_table", err)
+	}
+	if _, err = dbReadOnly.Query(`LIST TABLES`); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/list_tables", err)
+	}
+}
+
 func _txPrepareData(db *sql.DB, tableName string) error {
 	_initTest(db)
 	if _, err := db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH pk=id:string WITH rcu=7 WITH wcu=7`, tableName)); err != nil {
@@ -290,6 +335,42 @@ func TestTx_Commit_Insert(t *testing.T) {
 	}
 }
 
+func TestTx_Commit_InsertStruct(t *testing.T) {
+	testName := "TestTx_Commit_InsertStruct"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+	_initTest(db)
+
+	_, _ = db.Exec(fmt.Sprintf(`CREATE TABLE %s WITH pk=id:string WITH rcu=3 WITH wcu=3`, tblTestTemp))
+
+	type item struct {
+		Id     string `dynamodbav:"id"`
+		Grade  int    `dynamodbav:"grade,omitempty"`
+		Active bool   `dynamodbav:"active,omitempty"`
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/tx-begin", err)
+	}
+	if _, err = tx.Exec(fmt.Sprintf(`INSERT INTO "%s" VALUE ?`, tblTestTemp), item{Id: "1", Active: true}); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/tx-exec", err)
+	}
+	if _, err = tx.Exec(fmt.Sprintf(`INSERT INTO "%s" VALUE ?`, tblTestTemp), &item{Id: "2", Grade: 2}); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/tx-exec", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/tx-commit", err)
+	}
+
+	expected := []map[string]interface{}{
+		{"id": "1", "grade": nil, "active": true},
+		{"id": "2", "grade": 2.0, "active": nil},
+	}
+	if err = _txVerifyData(db, tblTestTemp, expected); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/verify", err)
+	}
+}
+
 func TestTx_Commit_UpdateDelete(t *testing.T) {
 	testName := "TestTx_Commit_UpdateDelete"
 	db := _openDb(t, testName)
@@ -339,3 +420,104 @@ func TestTx_Commit_UpdateDelete(t *testing.T) {
 		t.Fatalf("%s failed: %s", testName+"/verify", err)
 	}
 }
+
+func TestTx_Query_Returning(t *testing.T) {
+	testName := "TestTx_Query_Returning"
+	db := _openDb(t, testName)
+	defer func() { _ = db.Close() }()
+
+	if err := _txPrepareData(db, tblTestTemp); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/prepare", err)
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/conn", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// driver level: rows are read from driver.Rows once the transaction is committed
+	err = conn.Raw(func(driverConn interface{}) error {
+		c := driverConn.(*godynamo.Conn)
+		tx, err := c.BeginTx(ctx, driver.TxOptions{})
+		if err != nil {
+			return err
+		}
+		query := func(sql string, values ...interface{}) (driver.Rows, error) {
+			stmt, err := c.PrepareContext(ctx, fmt.Sprintf(sql, tblTestTemp))
+			if err != nil {
+				return nil, err
+			}
+			namedValues := make([]driver.NamedValue, len(values))
+			for i, v := range values {
+				namedValues[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+			}
+			return stmt.(driver.StmtQueryContext).QueryContext(ctx, namedValues)
+		}
+		rowsList := make([]driver.Rows, 3)
+		if rowsList[0], err = query(`UPDATE "%s" SET duration=? WHERE "id"=?`, 1.2, "2"); err != nil {
+			return err
+		}
+		if rowsList[1], err = query(`DELETE FROM "%s" WHERE "id"=? RETURNING ALL OLD *`, "1"); err != nil {
+			return err
+		}
+		if rowsList[2], err = query(`INSERT INTO "%s" VALUE {'id': ?, 'grade': ?} RETURNING ALL NEW *`, "7", 14); err != nil {
+			return err
+		}
+		if err = rowsList[0].Next(make([]driver.Value, 0)); !errors.Is(err, godynamo.ErrInTx) {
+			return fmt.Errorf("expected ErrInTx before commit but received %v", err)
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+
+		expected := []map[string]interface{}{
+			{"id": "2", "grade": 4.0},
+			{"id": "1", "grade": 2.0},
+			{"id": "7", "grade": 14.0},
+		}
+		for i, rows := range rowsList {
+			row := make(map[string]interface{})
+			dest := make([]driver.Value, len(rows.Columns()))
+			if err = rows.Next(dest); err != nil {
+				return err
+			}
+			for j, col := range rows.Columns() {
+				row[col] = dest[j]
+			}
+			if !reflect.DeepEqual(row, expected[i]) {
+				return fmt.Errorf("statement %d: expected %#v but received %#v", i, expected[i], row)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	// database/sql: rows are received via sql.Out
+	var returning godynamo.TxReturning
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/tx-begin", err)
+	}
+	if _, err = tx.Exec(fmt.Sprintf(`DELETE FROM "%s" WHERE "id"=?`, tblTestTemp), "7", sql.Out{Dest: &returning}); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/sql.Out", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/sql.Out", err)
+	}
+	if len(returning.Items) != 1 || !reflect.DeepEqual(returning.Items[0]["grade"], &types.AttributeValueMemberN{Value: "14"}) {
+		t.Fatalf("%s failed: expected the deleted item but received %#v", testName+"/sql.Out", returning.Items)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/tx-begin", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err = tx.Query(fmt.Sprintf(`UPDATE "%s" SET duration=? WHERE "id"=? RETURNING ALL NEW *`, tblTestTemp), 1.2, "2"); err == nil {
+		t.Fatalf("%s failed: RETURNING ALL NEW must not be supported inside a transaction", testName+"/all_new")
+	}
+}
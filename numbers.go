@@ -0,0 +1,155 @@
+package godynamo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// NumberModeFloat returns N attributes as float64 (the default mode). Numbers beyond 2^53 lose precision.
+	//
+	// @Available since v1.4.0
+	NumberModeFloat = "float"
+
+	// NumberModeJsonNumber returns N attributes as json.Number, which can be scanned into int64, float64 or string.
+	//
+	// @Available since v1.4.0
+	NumberModeJsonNumber = "json.Number"
+
+	// NumberModeString returns N attributes as string, which can be scanned into int64, float64 or string, or parsed
+	// by a decimal library.
+	//
+	// @Available since v1.4.0
+	NumberModeString = "string"
+
+	// NumberModeBig returns N attributes as *BigNumber, with 128 bits of precision (DynamoDB numbers have up to 38
+	// significant digits), which can be scanned into *big.Float, int64 (integral values only) or float64.
+	//
+	// @Available since v1.4.0
+	NumberModeBig = "big"
+)
+
+// bigFloatPrecision is the precision (in bits) of *BigNumber values returned with NumberModeBig.
+const bigFloatPrecision = 128
+
+// BigNumber is the type of N attributes returned with NumberModeBig. Its underlying type is big.Float, hence a
+// *BigNumber can be scanned into a *big.Float or converted with BigNumber.Float.
+//
+// database/sql converts values to int64 or float64 destinations via their string representation, which loses
+// precision with *big.Float (e.g. "1.234567890123456789e+18"); BigNumber.String returns all significant digits, and
+// integral values are formatted without exponent.
+//
+// @Available since v1.4.0
+type BigNumber big.Float
+
+// Float returns the number as a *big.Float.
+//
+// @Available since v1.4.0
+func (n *BigNumber) Float() *big.Float {
+	return (*big.Float)(n)
+}
+
+// String implements fmt.Stringer.
+//
+// @Available since v1.4.0
+func (n *BigNumber) String() string {
+	if n == nil {
+		return "<nil>"
+	}
+	if n.Float().IsInt() {
+		return n.Float().Text('f', 0)
+	}
+	return n.Float().Text('g', -1)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+//
+// @Available since v1.4.0
+func (n *BigNumber) MarshalText() ([]byte, error) {
+	if n == nil {
+		return []byte("<nil>"), nil
+	}
+	return []byte(n.String()), nil
+}
+
+// normalizeNumberMode returns the canonical form of a number mode, or an error if the mode is not supported.
+func normalizeNumberMode(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "float", "float64":
+		return NumberModeFloat, nil
+	case "json.number", "jsonnumber", "json":
+		return NumberModeJsonNumber, nil
+	case "string":
+		return NumberModeString, nil
+	case "big", "big.float", "bigfloat":
+		return NumberModeBig, nil
+	}
+	return "", fmt.Errorf("invalid number mode <%s>, supported modes are float, json.Number, string and big", mode)
+}
+
+// convertNumber converts the string representation of a DynamoDB number according to the number mode.
+func convertNumber(n, mode string) (interface{}, error) {
+	switch mode {
+	case NumberModeJsonNumber:
+		return json.Number(n), nil
+	case NumberModeString:
+		return n, nil
+	case NumberModeBig:
+		f, _, err := big.ParseFloat(n, 10, bigFloatPrecision, big.ToNearestEven)
+		if err != nil {
+			return nil, err
+		}
+		return (*BigNumber)(f), nil
+	}
+	var f float64
+	err := attributevalue.Unmarshal(&types.AttributeValueMemberN{Value: n}, &f)
+	return f, err
+}
+
+// attributeValueToInterface converts an AttributeValue to a Go value, numbers (including those nested inside M and L
+// attributes) are converted according to the number mode.
+func attributeValueToInterface(av types.AttributeValue, mode string) (interface{}, error) {
+	var value interface{}
+	if mode == NumberModeFloat || mode == "" {
+		err := attributevalue.Unmarshal(av, &value)
+		return value, err
+	}
+	switch v := av.(type) {
+	case *types.AttributeValueMemberN:
+		return convertNumber(v.Value, mode)
+	case *types.AttributeValueMemberNS:
+		result := make([]interface{}, len(v.Value))
+		for i, n := range v.Value {
+			var err error
+			if result[i], err = convertNumber(n, mode); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case *types.AttributeValueMemberL:
+		result := make([]interface{}, len(v.Value))
+		for i, e := range v.Value {
+			var err error
+			if result[i], err = attributeValueToInterface(e, mode); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case *types.AttributeValueMemberM:
+		result := make(map[string]interface{}, len(v.Value))
+		for k, e := range v.Value {
+			var err error
+			if result[k], err = attributeValueToInterface(e, mode); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	err := attributevalue.Unmarshal(av, &value)
+	return value, err
+}
@@ -0,0 +1,169 @@
+package godynamo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func _bigNumber(n string) *BigNumber {
+	value, _ := convertNumber(n, NumberModeBig)
+	return value.(*BigNumber)
+}
+
+func Test_normalizeNumberMode(t *testing.T) {
+	testName := "Test_normalizeNumberMode"
+	testData := map[string]string{"": NumberModeFloat, "FLOAT": NumberModeFloat, "json.number": NumberModeJsonNumber, "String": NumberModeString, "big": NumberModeBig}
+	for input, expected := range testData {
+		if mode, err := normalizeNumberMode(input); err != nil || mode != expected {
+			t.Fatalf("%s failed: expected %#v for input %#v but received %#v (%v)", testName, expected, input, mode, err)
+		}
+	}
+	if _, err := normalizeNumberMode("decimal"); err == nil {
+		t.Fatalf("%s failed: invalid mode must be rejected", testName)
+	}
+	if _, err := (&Driver{}).Open("Region=us-east-1;NumberMode=decimal"); err == nil {
+		t.Fatalf("%s failed: invalid NumberMode in DSN must be rejected", testName)
+	}
+}
+
+func Test_attributeValueToInterface(t *testing.T) {
+	testName := "Test_attributeValueToInterface"
+	const bigNum = "12345678901234567891"
+	av := &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+		"n":    &types.AttributeValueMemberN{Value: bigNum},
+		"ns":   &types.AttributeValueMemberNS{Value: []string{"1", bigNum}},
+		"list": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberN{Value: bigNum}, &types.AttributeValueMemberS{Value: "s"}}},
+		"null": &types.AttributeValueMemberNULL{Value: true},
+	}}
+	bigFloat, _, _ := big.ParseFloat(bigNum, 10, bigFloatPrecision, big.ToNearestEven)
+	bigOneFloat, _, _ := big.ParseFloat("1", 10, bigFloatPrecision, big.ToNearestEven)
+	bigValue, bigOne := (*BigNumber)(bigFloat), (*BigNumber)(bigOneFloat)
+	testData := []struct {
+		mode     string
+		expected interface{}
+	}{
+		{mode: NumberModeJsonNumber, expected: map[string]interface{}{
+			"n": json.Number(bigNum), "ns": []interface{}{json.Number("1"), json.Number(bigNum)}, "list": []interface{}{json.Number(bigNum), "s"}, "null": nil}},
+		{mode: NumberModeString, expected: map[string]interface{}{
+			"n": bigNum, "ns": []interface{}{"1", bigNum}, "list": []interface{}{bigNum, "s"}, "null": nil}},
+		{mode: NumberModeBig, expected: map[string]interface{}{
+			"n": bigValue, "ns": []interface{}{bigOne, bigValue}, "list": []interface{}{bigValue, "s"}, "null": nil}},
+	}
+	for _, testCase := range testData {
+		value, err := attributeValueToInterface(av, testCase.mode)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+testCase.mode, err)
+		}
+		if !reflect.DeepEqual(value, testCase.expected) {
+			t.Fatalf("%s failed: expected %#v but received %#v", testName+"/"+testCase.mode, testCase.expected, value)
+		}
+	}
+	if value, _ := attributeValueToInterface(av.Value["n"], NumberModeBig); value.(*BigNumber).String() != bigNum {
+		t.Fatalf("%s failed: precision lost %v", testName+"/big", value)
+	}
+
+	value, err := attributeValueToInterface(av.Value["n"], NumberModeFloat)
+	if err != nil || value != 12345678901234567891.0 {
+		t.Fatalf("%s failed: expected float64 but received %#v (%v)", testName+"/float", value, err)
+	}
+}
+
+func TestResultResultSet_numberMode(t *testing.T) {
+	testName := "TestResultResultSet_numberMode"
+	item := map[string]types.AttributeValue{"id": &types.AttributeValueMemberN{Value: "9007199254740993"}}
+	testData := []struct {
+		mode     string
+		scanType reflect.Type
+		expected interface{}
+	}{
+		{mode: "", scanType: reflect.TypeOf(float64(0)), expected: float64(9007199254740992)},
+		{mode: NumberModeJsonNumber, scanType: reflect.TypeOf(json.Number("")), expected: json.Number("9007199254740993")},
+		{mode: NumberModeString, scanType: reflect.TypeOf(""), expected: "9007199254740993"},
+		{mode: NumberModeBig, scanType: reflect.TypeOf(&BigNumber{})},
+	}
+	for _, testCase := range testData {
+		rs := (&ResultResultSet{stmtOutput: &dynamodb.ExecuteStatementOutput{Items: []map[string]types.AttributeValue{item}}, numberMode: testCase.mode}).init()
+		if scanType := rs.ColumnTypeScanType(0); scanType != testCase.scanType {
+			t.Fatalf("%s failed: expected scan type %s but received %s", testName+"/"+testCase.mode, testCase.scanType, scanType)
+		}
+		dest := make([]driver.Value, 1)
+		if err := rs.Next(dest); err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+testCase.mode, err)
+		}
+		if testCase.mode == NumberModeBig {
+			if dest[0].(*BigNumber).Float().Text('f', 0) != "9007199254740993" {
+				t.Fatalf("%s failed: precision lost %v", testName+"/"+testCase.mode, dest[0])
+			}
+		} else if dest[0] != testCase.expected {
+			t.Fatalf("%s failed: expected %#v but received %#v", testName+"/"+testCase.mode, testCase.expected, dest[0])
+		}
+	}
+}
+
+func TestBigNumber_scan(t *testing.T) {
+	testName := "TestBigNumber_scan"
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_, _ = w.Write([]byte(`{"Items":[{"id":{"S":"1"},"n":{"N":"1234567890123456789"},"f":{"N":"1.5"}}]}`))
+	}))
+	defer stub.Close()
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;NumberMode=big;Endpoint="+stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+
+	// values beyond 2^53 are scanned losslessly into int64
+	var n int64
+	var nullN sql.NullInt64
+	var f float64
+	if err = db.QueryRow(`SELECT n, n, f FROM tbl WHERE id='1'`).Scan(&n, &nullN, &f); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if n != 1234567890123456789 || nullN.Int64 != 1234567890123456789 || f != 1.5 {
+		t.Fatalf("%s failed: precision lost %d / %#v / %v", testName, n, nullN, f)
+	}
+
+	// values can still be scanned into *big.Float
+	var bigN *big.Float
+	if err = db.QueryRow(`SELECT n FROM tbl WHERE id='1'`).Scan(&bigN); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if bigN.Text('f', 0) != "1234567890123456789" {
+		t.Fatalf("%s failed: precision lost %v", testName, bigN)
+	}
+
+	// non-integral values can not be scanned into int64
+	if err = db.QueryRow(`SELECT f FROM tbl WHERE id='1'`).Scan(&n); err == nil {
+		t.Fatalf("%s failed: non-integral value must not be scanned into int64", testName)
+	}
+}
+
+func TestBigNumber_String(t *testing.T) {
+	testName := "TestBigNumber_String"
+	testData := map[string]string{"9007199254740993": "9007199254740993", "-12345678901234567890123": "-12345678901234567890123", "1.25": "1.25", "1e-10": "1e-10", "0": "0"}
+	for input, expected := range testData {
+		value, err := convertNumber(input, NumberModeBig)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+input, err)
+		}
+		if str := value.(*BigNumber).String(); str != expected {
+			t.Fatalf("%s failed: expected %#v but received %#v", testName+"/"+input, expected, str)
+		}
+		if js, _ := json.Marshal(value); string(js) != `"`+expected+`"` {
+			t.Fatalf("%s failed: expected JSON %#v but received %s", testName+"/"+input, expected, js)
+		}
+	}
+	if _, err := convertNumber("abc", NumberModeBig); err == nil {
+		t.Fatalf("%s failed: invalid number must be rejected", testName)
+	}
+}
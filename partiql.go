@@ -0,0 +1,836 @@
+package godynamo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// This file implements a light-weight analyzer for PartiQL SELECT statements. It understands the subset of PartiQL that
+// can be translated to DynamoDB's low-level API (key conditions, filter and projection expressions):
+//
+//	SELECT *|<path>[, <path>...] FROM <table>[.<index>] [WHERE <condition>] [ORDER BY <path> [ASC|DESC]]
+//
+// where <condition> is a combination (AND, OR, NOT, parentheses) of:
+//   - <operand> =|<>|!=|<|<=|>|>= <operand>
+//   - <operand> BETWEEN <operand> AND <operand>
+//   - <operand> IN (<operand>[, <operand>...]) (square brackets are also accepted)
+//   - <path> IS [NOT] MISSING
+//   - begins_with(<path>, <operand>), contains(<path>, <operand>), attribute_exists(<path>),
+//     attribute_not_exists(<path>), attribute_type(<path>, <operand>)
+//
+// and <operand> is an attribute path, a placeholder (?) or a literal (string, number, TRUE, FALSE or NULL).
+
+// partiqlPath is an attribute path; each element is an attribute name, or a list index in the form "[n]".
+type partiqlPath []string
+
+func (p partiqlPath) String() string {
+	sb := strings.Builder{}
+	for i, seg := range p {
+		if i > 0 && !strings.HasPrefix(seg, "[") {
+			sb.WriteString(".")
+		}
+		sb.WriteString(seg)
+	}
+	return sb.String()
+}
+
+// partiqlOperand is an attribute path, a placeholder or a literal value.
+type partiqlOperand struct {
+	path    partiqlPath          // attribute path, nil if the operand is not a path
+	param   int                  // 0-based index of the placeholder, -1 if the operand is not a placeholder
+	literal types.AttributeValue // literal value, nil if the operand is not a literal
+}
+
+func (o partiqlOperand) String() string {
+	switch {
+	case o.path != nil:
+		return o.path.String()
+	case o.param >= 0:
+		return "?"
+	}
+	switch v := o.literal.(type) {
+	case *types.AttributeValueMemberS:
+		return "'" + strings.ReplaceAll(v.Value, "'", "''") + "'"
+	case *types.AttributeValueMemberN:
+		return v.Value
+	case *types.AttributeValueMemberBOOL:
+		return strings.ToUpper(strconv.FormatBool(v.Value))
+	}
+	return "NULL"
+}
+
+// partiqlCondition is a node of a WHERE clause.
+type partiqlCondition struct {
+	// op is "AND", "OR", "NOT", a comparison operator (=, <>, <, <=, >, >=), "BETWEEN", "IN", "IS MISSING",
+	// "IS NOT MISSING" or a function name in lower case (e.g. "begins_with").
+	op       string
+	children []*partiqlCondition // operands of AND, OR and NOT
+	operands []partiqlOperand    // operands of other operators
+}
+
+// conjuncts returns the top-level AND-ed terms of the condition.
+func (c *partiqlCondition) conjuncts() []*partiqlCondition {
+	if c == nil {
+		return nil
+	}
+	if c.op == "AND" {
+		return append(c.children[0].conjuncts(), c.children[1].conjuncts()...)
+	}
+	return []*partiqlCondition{c}
+}
+
+func (c *partiqlCondition) String() string {
+	switch c.op {
+	case "AND", "OR":
+		left, right := c.children[0].String(), c.children[1].String()
+		if c.op == "AND" {
+			if c.children[0].op == "OR" {
+				left = "(" + left + ")"
+			}
+			if c.children[1].op == "OR" {
+				right = "(" + right + ")"
+			}
+		}
+		return left + " " + c.op + " " + right
+	case "NOT":
+		return "NOT (" + c.children[0].String() + ")"
+	case "BETWEEN":
+		return c.operands[0].String() + " BETWEEN " + c.operands[1].String() + " AND " + c.operands[2].String()
+	case "IN":
+		values := make([]string, len(c.operands)-1)
+		for i, o := range c.operands[1:] {
+			values[i] = o.String()
+		}
+		return c.operands[0].String() + " IN (" + strings.Join(values, ", ") + ")"
+	case "IS MISSING", "IS NOT MISSING":
+		return c.operands[0].String() + " " + c.op
+	case "=", "<>", "<", "<=", ">", ">=":
+		return c.operands[0].String() + " " + c.op + " " + c.operands[1].String()
+	}
+	args := make([]string, len(c.operands))
+	for i, o := range c.operands {
+		args[i] = o.String()
+	}
+	return c.op + "(" + strings.Join(args, ", ") + ")"
+}
+
+// partiqlSelect is the result of analyzing a PartiQL SELECT statement.
+type partiqlSelect struct {
+	projection []partiqlPath // empty if all attributes are selected
+	tableName  string
+	indexName  string
+	where      *partiqlCondition // nil if there is no WHERE clause
+	orderBy    partiqlPath       // nil if there is no ORDER BY clause
+	orderDesc  bool
+	numParams  int
+}
+
+var partiqlFunctions = map[string]int{
+	"begins_with":          2,
+	"contains":             2,
+	"attribute_exists":     1,
+	"attribute_not_exists": 1,
+	"attribute_type":       2,
+}
+
+/*----------------------------------------------------------------------*/
+
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenParam
+	tokenSymbol
+)
+
+type partiqlToken struct {
+	kind  int
+	value string
+	pos   int // position (in runes) of the token in the statement (@Available since v1.4.0)
+}
+
+func tokenizePartiql(query string) ([]partiqlToken, error) {
+	tokens := make([]partiqlToken, 0)
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			sb := strings.Builder{}
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == r {
+					if j+1 < len(runes) && runes[j+1] == r {
+						// escaped quote
+						sb.WriteRune(r)
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, &ParseError{Statement: query, Position: i, Err: fmt.Errorf("unterminated quoted string at position %d", i)}
+			}
+			kind := tokenString
+			if r == '"' {
+				kind = tokenQuotedIdent
+			}
+			tokens = append(tokens, partiqlToken{kind: kind, value: sb.String(), pos: i})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == 'e' || runes[j] == 'E' ||
+				((runes[j] == '-' || runes[j] == '+') && (runes[j-1] == 'e' || runes[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, partiqlToken{kind: tokenNumber, value: string(runes[i:j]), pos: i})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '-') {
+				j++
+			}
+			tokens = append(tokens, partiqlToken{kind: tokenIdent, value: string(runes[i:j]), pos: i})
+			i = j
+		case r == '?':
+			tokens = append(tokens, partiqlToken{kind: tokenParam, value: "?", pos: i})
+			i++
+		default:
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "<>", "!=", "<=", ">=":
+					if two == "!=" {
+						two = "<>"
+					}
+					tokens = append(tokens, partiqlToken{kind: tokenSymbol, value: two, pos: i})
+					i += 2
+					continue
+				case "<<", ">>":
+					// set literal delimiters, only used in INSERT documents
+					tokens = append(tokens, partiqlToken{kind: tokenSymbol, value: two, pos: i})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("()[],.=<>*{}:", r) {
+				return nil, &ParseError{Statement: query, Position: i, Err: fmt.Errorf("unexpected character '%c' at position %d", r, i)}
+			}
+			tokens = append(tokens, partiqlToken{kind: tokenSymbol, value: string(r), pos: i})
+			i++
+		}
+	}
+	return append(tokens, partiqlToken{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+type partiqlParser struct {
+	query     string // the statement being parsed (@Available since v1.4.0)
+	tokens    []partiqlToken
+	pos       int
+	numParams int
+}
+
+// parseError wraps err into a *ParseError at the position of the current token, nil is returned as-is.
+//
+// @Available since v1.4.0
+func (p *partiqlParser) parseError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return newParseError(p.query, p.peek().pos, err)
+}
+
+func (p *partiqlParser) peek() partiqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *partiqlParser) next() partiqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// isKeyword checks if the current token is the keyword kw (case-insensitive).
+func (p *partiqlParser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.value, kw)
+}
+
+func (p *partiqlParser) isSymbol(sym string) bool {
+	t := p.peek()
+	return t.kind == tokenSymbol && t.value == sym
+}
+
+func (p *partiqlParser) expectKeyword(kw string) error {
+	if !p.isKeyword(kw) {
+		return fmt.Errorf("expected %s but found %s", kw, p.describe())
+	}
+	p.next()
+	return nil
+}
+
+func (p *partiqlParser) expectSymbol(sym string) error {
+	if !p.isSymbol(sym) {
+		return fmt.Errorf("expected '%s' but found %s", sym, p.describe())
+	}
+	p.next()
+	return nil
+}
+
+func (p *partiqlParser) describe() string {
+	t := p.peek()
+	switch t.kind {
+	case tokenEOF:
+		return "end of statement"
+	case tokenString:
+		return "'" + t.value + "'"
+	case tokenQuotedIdent:
+		return `"` + t.value + `"`
+	}
+	return "<" + t.value + ">"
+}
+
+func (p *partiqlParser) parseName() (string, error) {
+	t := p.peek()
+	if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+		return "", fmt.Errorf("expected a name but found %s", p.describe())
+	}
+	p.next()
+	return t.value, nil
+}
+
+func (p *partiqlParser) parsePath() (partiqlPath, error) {
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	path := partiqlPath{name}
+	for {
+		switch {
+		case p.isSymbol("."):
+			p.next()
+			if name, err = p.parseName(); err != nil {
+				return nil, err
+			}
+			path = append(path, name)
+		case p.isSymbol("["):
+			p.next()
+			t := p.next()
+			if _, err := strconv.Atoi(t.value); t.kind != tokenNumber || err != nil {
+				return nil, fmt.Errorf("invalid list index <%s>", t.value)
+			}
+			if err := p.expectSymbol("]"); err != nil {
+				return nil, err
+			}
+			path = append(path, "["+t.value+"]")
+		default:
+			return path, nil
+		}
+	}
+}
+
+func (p *partiqlParser) parseOperand() (partiqlOperand, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenParam:
+		p.next()
+		p.numParams++
+		return partiqlOperand{param: p.numParams - 1}, nil
+	case tokenString:
+		p.next()
+		return partiqlOperand{param: -1, literal: &types.AttributeValueMemberS{Value: t.value}}, nil
+	case tokenNumber:
+		p.next()
+		return partiqlOperand{param: -1, literal: &types.AttributeValueMemberN{Value: t.value}}, nil
+	case tokenIdent:
+		switch strings.ToUpper(t.value) {
+		case "TRUE", "FALSE":
+			p.next()
+			return partiqlOperand{param: -1, literal: &types.AttributeValueMemberBOOL{Value: strings.EqualFold(t.value, "TRUE")}}, nil
+		case "NULL":
+			p.next()
+			return partiqlOperand{param: -1, literal: &types.AttributeValueMemberNULL{Value: true}}, nil
+		}
+	}
+	path, err := p.parsePath()
+	return partiqlOperand{path: path, param: -1}, err
+}
+
+func (p *partiqlParser) parseOr() (*partiqlCondition, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.isKeyword("OR") {
+		p.next()
+		var right *partiqlCondition
+		if right, err = p.parseAnd(); err == nil {
+			left = &partiqlCondition{op: "OR", children: []*partiqlCondition{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *partiqlParser) parseAnd() (*partiqlCondition, error) {
+	left, err := p.parseNot()
+	for err == nil && p.isKeyword("AND") {
+		p.next()
+		var right *partiqlCondition
+		if right, err = p.parseNot(); err == nil {
+			left = &partiqlCondition{op: "AND", children: []*partiqlCondition{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *partiqlParser) parseNot() (*partiqlCondition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		cond, err := p.parseNot()
+		return &partiqlCondition{op: "NOT", children: []*partiqlCondition{cond}}, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *partiqlParser) parsePrimary() (*partiqlCondition, error) {
+	if p.isSymbol("(") {
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return cond, p.expectSymbol(")")
+	}
+	if t := p.peek(); t.kind == tokenIdent && p.tokens[p.pos+1].kind == tokenSymbol && p.tokens[p.pos+1].value == "(" {
+		// function call
+		name := strings.ToLower(t.value)
+		numArgs, ok := partiqlFunctions[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported function <%s>", t.value)
+		}
+		p.next()
+		p.next()
+		cond := &partiqlCondition{op: name}
+		for i := 0; i < numArgs; i++ {
+			if i > 0 {
+				if err := p.expectSymbol(","); err != nil {
+					return nil, err
+				}
+			}
+			operand, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			cond.operands = append(cond.operands, operand)
+		}
+		if cond.operands[0].path == nil {
+			return nil, fmt.Errorf("first argument of function <%s> must be an attribute path", t.value)
+		}
+		return cond, p.expectSymbol(")")
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch {
+	case t.kind == tokenSymbol && (t.value == "=" || t.value == "<>" || t.value == "<" || t.value == "<=" || t.value == ">" || t.value == ">="):
+		p.next()
+		right, err := p.parseOperand()
+		return &partiqlCondition{op: t.value, operands: []partiqlOperand{left, right}}, err
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseOperand()
+		return &partiqlCondition{op: "BETWEEN", operands: []partiqlOperand{left, low, high}}, err
+	case p.isKeyword("IN"):
+		p.next()
+		closing := ")"
+		if p.isSymbol("[") {
+			closing = "]"
+			p.next()
+		} else if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		cond := &partiqlCondition{op: "IN", operands: []partiqlOperand{left}}
+		for {
+			operand, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			cond.operands = append(cond.operands, operand)
+			if !p.isSymbol(",") {
+				break
+			}
+			p.next()
+		}
+		return cond, p.expectSymbol(closing)
+	case p.isKeyword("IS"):
+		p.next()
+		op := "IS MISSING"
+		if p.isKeyword("NOT") {
+			p.next()
+			op = "IS NOT MISSING"
+		}
+		if left.path == nil {
+			return nil, fmt.Errorf("%s can only be applied to an attribute path", op)
+		}
+		return &partiqlCondition{op: op, operands: []partiqlOperand{left}}, p.expectKeyword("MISSING")
+	}
+	return nil, fmt.Errorf("expected a comparison but found %s", p.describe())
+}
+
+// parsePartiqlSelect analyzes a PartiQL SELECT statement (without LIMIT and WITH clauses).
+//
+// @Since v1.4.0 errors are returned as *ParseError, carrying the position of the offending token.
+func parsePartiqlSelect(query string) (_ *partiqlSelect, err error) {
+	tokens, err := tokenizePartiql(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &partiqlParser{query: query, tokens: tokens}
+	defer func() { err = p.parseError(err) }()
+	result := &partiqlSelect{}
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	if p.isSymbol("*") {
+		p.next()
+	} else {
+		for {
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			result.projection = append(result.projection, path)
+			if !p.isSymbol(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	if result.tableName, err = p.parseName(); err != nil {
+		return nil, err
+	}
+	if p.isSymbol(".") {
+		p.next()
+		if result.indexName, err = p.parseName(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isKeyword("WHERE") {
+		p.next()
+		if result.where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isKeyword("ORDER") {
+		p.next()
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		if result.orderBy, err = p.parsePath(); err != nil {
+			return nil, err
+		}
+		if p.isKeyword("DESC") || p.isKeyword("ASC") {
+			result.orderDesc = strings.EqualFold(p.next().value, "DESC")
+		}
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s", p.describe())
+	}
+	result.numParams = p.numParams
+	return result, nil
+}
+
+/*----------------------------------------------------------------------*/
+
+// evalPartiqlInsertItem evaluates the document of a PartiQL INSERT statement (without RETURNING clause) to the item being
+// inserted; placeholders are substituted by params, in order.
+//
+//	INSERT INTO <table> VALUE <document>
+//
+// where values of the document are placeholders, literals (string, number, TRUE, FALSE or NULL), documents, lists
+// ([...]) or sets (<<...>>).
+func evalPartiqlInsertItem(query string, params []types.AttributeValue) (map[string]types.AttributeValue, error) {
+	tokens, err := tokenizePartiql(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &partiqlParser{query: query, tokens: tokens}
+	if err := p.expectKeyword("INSERT"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("INTO"); err != nil {
+		return nil, err
+	}
+	if _, err := p.parseName(); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("VALUE"); err != nil {
+		return nil, err
+	}
+	if !p.isSymbol("{") {
+		return nil, fmt.Errorf("expected a document but found %s", p.describe())
+	}
+	value, err := p.evalValue(params)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s", p.describe())
+	}
+	return value.(*types.AttributeValueMemberM).Value, nil
+}
+
+// evalValue evaluates a value of an INSERT document.
+func (p *partiqlParser) evalValue(params []types.AttributeValue) (types.AttributeValue, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenParam:
+		p.next()
+		p.numParams++
+		if p.numParams > len(params) {
+			return nil, fmt.Errorf("expected at least %d parameters but received %d", p.numParams, len(params))
+		}
+		return params[p.numParams-1], nil
+	case t.kind == tokenSymbol && t.value == "{":
+		p.next()
+		item := make(map[string]types.AttributeValue)
+		for !p.isSymbol("}") {
+			if len(item) > 0 {
+				if err := p.expectSymbol(","); err != nil {
+					return nil, err
+				}
+			}
+			key := p.peek()
+			if key.kind != tokenString && key.kind != tokenQuotedIdent {
+				return nil, fmt.Errorf("expected an attribute name but found %s", p.describe())
+			}
+			p.next()
+			if err := p.expectSymbol(":"); err != nil {
+				return nil, err
+			}
+			value, err := p.evalValue(params)
+			if err != nil {
+				return nil, err
+			}
+			item[key.value] = value
+		}
+		p.next()
+		return &types.AttributeValueMemberM{Value: item}, nil
+	case t.kind == tokenSymbol && (t.value == "[" || t.value == "<<"):
+		p.next()
+		closing := map[string]string{"[": "]", "<<": ">>"}[t.value]
+		values := make([]types.AttributeValue, 0)
+		for !p.isSymbol(closing) {
+			if len(values) > 0 {
+				if err := p.expectSymbol(","); err != nil {
+					return nil, err
+				}
+			}
+			value, err := p.evalValue(params)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		p.next()
+		if t.value == "[" {
+			return &types.AttributeValueMemberL{Value: values}, nil
+		}
+		return toSetAttributeValue(values)
+	}
+	operand, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if operand.literal == nil {
+		return nil, fmt.Errorf("expected a value but found attribute path %s", operand.path)
+	}
+	return operand.literal, nil
+}
+
+// toSetAttributeValue builds a set (SS, NS or BS) from its elements, which must be of the same type.
+func toSetAttributeValue(values []types.AttributeValue) (types.AttributeValue, error) {
+	var ss, ns []string
+	var bs [][]byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			ss = append(ss, v.Value)
+		case *types.AttributeValueMemberN:
+			ns = append(ns, v.Value)
+		case *types.AttributeValueMemberB:
+			bs = append(bs, v.Value)
+		default:
+			return nil, fmt.Errorf("set elements must be strings, numbers or binaries, found %T", value)
+		}
+	}
+	switch {
+	case len(ss) == len(values) && len(ss) > 0:
+		return &types.AttributeValueMemberSS{Value: ss}, nil
+	case len(ns) == len(values) && len(ns) > 0:
+		return &types.AttributeValueMemberNS{Value: ns}, nil
+	case len(bs) == len(values) && len(bs) > 0:
+		return &types.AttributeValueMemberBS{Value: bs}, nil
+	}
+	return nil, fmt.Errorf("set must be non-empty and its elements must be of the same type")
+}
+
+/*----------------------------------------------------------------------*/
+
+// expressionBuilder translates PartiQL conditions and paths to DynamoDB expressions, collecting attribute names and values.
+type expressionBuilder struct {
+	params []types.AttributeValue
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func newExpressionBuilder(params []types.AttributeValue) *expressionBuilder {
+	return &expressionBuilder{params: params, names: make(map[string]string), values: make(map[string]types.AttributeValue)}
+}
+
+// attributeNames returns the ExpressionAttributeNames map, or nil if empty.
+func (b *expressionBuilder) attributeNames() map[string]string {
+	if len(b.names) == 0 {
+		return nil
+	}
+	return b.names
+}
+
+// attributeValues returns the ExpressionAttributeValues map, or nil if empty.
+func (b *expressionBuilder) attributeValues() map[string]types.AttributeValue {
+	if len(b.values) == 0 {
+		return nil
+	}
+	return b.values
+}
+
+func (b *expressionBuilder) path(path partiqlPath) string {
+	sb := strings.Builder{}
+	for i, seg := range path {
+		if strings.HasPrefix(seg, "[") {
+			sb.WriteString(seg)
+			continue
+		}
+		placeholder := ""
+		for k, v := range b.names {
+			if v == seg {
+				placeholder = k
+				break
+			}
+		}
+		if placeholder == "" {
+			placeholder = "#n" + strconv.Itoa(len(b.names))
+			b.names[placeholder] = seg
+		}
+		if i > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(placeholder)
+	}
+	return sb.String()
+}
+
+func (b *expressionBuilder) operand(o partiqlOperand) (string, error) {
+	if o.path != nil {
+		return b.path(o.path), nil
+	}
+	value := o.literal
+	if o.param >= 0 {
+		if o.param >= len(b.params) {
+			return "", fmt.Errorf("missing value for placeholder #%d", o.param+1)
+		}
+		value = b.params[o.param]
+	}
+	placeholder := ":v" + strconv.Itoa(len(b.values))
+	b.values[placeholder] = value
+	return placeholder, nil
+}
+
+func (b *expressionBuilder) operands(operands []partiqlOperand) ([]string, error) {
+	result := make([]string, len(operands))
+	for i, o := range operands {
+		var err error
+		if result[i], err = b.operand(o); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// condition translates a PartiQL condition to a DynamoDB condition expression.
+func (b *expressionBuilder) condition(c *partiqlCondition) (string, error) {
+	switch c.op {
+	case "AND", "OR":
+		left, err := b.condition(c.children[0])
+		if err != nil {
+			return "", err
+		}
+		right, err := b.condition(c.children[1])
+		return "(" + left + ") " + c.op + " (" + right + ")", err
+	case "NOT":
+		expr, err := b.condition(c.children[0])
+		return "NOT (" + expr + ")", err
+	}
+	operands, err := b.operands(c.operands)
+	if err != nil {
+		return "", err
+	}
+	switch c.op {
+	case "BETWEEN":
+		return operands[0] + " BETWEEN " + operands[1] + " AND " + operands[2], nil
+	case "IN":
+		return operands[0] + " IN (" + strings.Join(operands[1:], ", ") + ")", nil
+	case "IS MISSING":
+		return "attribute_not_exists(" + operands[0] + ")", nil
+	case "IS NOT MISSING":
+		return "attribute_exists(" + operands[0] + ")", nil
+	case "=", "<>", "<", "<=", ">", ">=":
+		return operands[0] + " " + c.op + " " + operands[1], nil
+	}
+	return c.op + "(" + strings.Join(operands, ", ") + ")", nil
+}
+
+// conditions translates a list of AND-ed PartiQL conditions to a DynamoDB condition expression.
+func (b *expressionBuilder) conditions(conds []*partiqlCondition) (string, error) {
+	exprs := make([]string, len(conds))
+	for i, c := range conds {
+		var err error
+		if exprs[i], err = b.condition(c); err != nil {
+			return "", err
+		}
+		if len(conds) > 1 && c.op == "OR" {
+			exprs[i] = "(" + exprs[i] + ")"
+		}
+	}
+	return strings.Join(exprs, " AND "), nil
+}
+
+// projection translates a list of attribute paths to a DynamoDB projection expression.
+func (b *expressionBuilder) projection(paths []partiqlPath) string {
+	exprs := make([]string, len(paths))
+	for i, path := range paths {
+		exprs[i] = b.path(path)
+	}
+	return strings.Join(exprs, ", ")
+}
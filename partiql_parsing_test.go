@@ -0,0 +1,193 @@
+package godynamo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func Test_parsePartiqlSelect(t *testing.T) {
+	testName := "Test_parsePartiqlSelect"
+	testData := []struct {
+		name       string
+		sql        string
+		tableName  string
+		indexName  string
+		projection string
+		where      string
+		numParams  int
+		mustError  bool
+	}{
+		{name: "star", sql: `SELECT * FROM tbl`, tableName: "tbl"},
+		{name: "quoted", sql: `SELECT "a", b.c, d[1] FROM "tbl-1"."idx_1"`, tableName: "tbl-1", indexName: "idx_1", projection: "a,b.c,d[1]"},
+		{name: "where", sql: `select * from "tbl" where a=? and (b>=10 or c<>'x''y') and not d between ? and 5`, tableName: "tbl", where: "a = ? AND (b >= 10 OR c <> 'x''y') AND NOT (d BETWEEN ? AND 5)", numParams: 2},
+		{name: "functions", sql: `SELECT * FROM tbl WHERE begins_with("a", ?) AND contains(b, 'x') AND attribute_exists(c) AND e IS MISSING AND f IS NOT MISSING`, tableName: "tbl", where: "begins_with(a, ?) AND contains(b, 'x') AND attribute_exists(c) AND e IS MISSING AND f IS NOT MISSING", numParams: 1},
+		{name: "in", sql: `SELECT * FROM tbl WHERE a IN [1, 2, ?] AND b IN ('x') AND c=TRUE AND d!=NULL`, tableName: "tbl", where: "a IN (1, 2, ?) AND b IN ('x') AND c = TRUE AND d <> NULL", numParams: 1},
+		{name: "order_by", sql: `SELECT * FROM tbl WHERE a=? ORDER BY b DESC`, tableName: "tbl", where: "a = ?", numParams: 1},
+
+		{name: "no_from", sql: `SELECT *`, mustError: true},
+		{name: "unterminated_string", sql: `SELECT * FROM tbl WHERE a='x`, mustError: true},
+		{name: "unsupported_function", sql: `SELECT * FROM tbl WHERE size(a) > 1`, mustError: true},
+		{name: "set_literal", sql: `SELECT * FROM tbl WHERE a = <<'x'>>`, mustError: true},
+		{name: "trailing_tokens", sql: `SELECT * FROM tbl WHERE a=1 b`, mustError: true},
+		{name: "incomplete_condition", sql: `SELECT * FROM tbl WHERE a`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			query, err := parsePartiqlSelect(testCase.sql)
+			if testCase.mustError && err == nil {
+				t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+			}
+			if testCase.mustError {
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if query.tableName != testCase.tableName || query.indexName != testCase.indexName || query.numParams != testCase.numParams {
+				t.Fatalf("%s failed: unexpected parsing result %#v", testName+"/"+testCase.name, query)
+			}
+			projection := ""
+			for i, path := range query.projection {
+				if i > 0 {
+					projection += ","
+				}
+				projection += path.String()
+			}
+			if projection != testCase.projection {
+				t.Fatalf("%s failed: expected projection %#v but received %#v", testName+"/"+testCase.name, testCase.projection, projection)
+			}
+			where := ""
+			if query.where != nil {
+				where = query.where.String()
+			}
+			if where != testCase.where {
+				t.Fatalf("%s failed: expected where %#v but received %#v", testName+"/"+testCase.name, testCase.where, where)
+			}
+		})
+	}
+}
+
+func Test_evalPartiqlInsertItem(t *testing.T) {
+	testName := "Test_evalPartiqlInsertItem"
+	params := []types.AttributeValue{&types.AttributeValueMemberS{Value: "app0"}, &types.AttributeValueMemberN{Value: "1"}}
+	testData := []struct {
+		name      string
+		sql       string
+		expected  map[string]types.AttributeValue
+		mustError bool
+	}{
+		{name: "placeholders", sql: `INSERT INTO "tbl" VALUE {'app': ?, 'count': ?}`, expected: map[string]types.AttributeValue{
+			"app": params[0], "count": params[1]}},
+		{name: "literals", sql: `insert into tbl value {'s': 'it''s', 'n': -1.5, 'b': TRUE, 'null': NULL, 'l': [1, 'x', ?], 'm': {'a': {}}, 'ss': <<'a', 'b'>>, 'ns': <<1, ?>>}`,
+			expected: map[string]types.AttributeValue{
+				"s":    &types.AttributeValueMemberS{Value: "it's"},
+				"n":    &types.AttributeValueMemberN{Value: "-1.5"},
+				"b":    &types.AttributeValueMemberBOOL{Value: true},
+				"null": &types.AttributeValueMemberNULL{Value: true},
+				"l":    &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberN{Value: "1"}, &types.AttributeValueMemberS{Value: "x"}, params[0]}},
+				"m":    &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"a": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}}}},
+				"ss":   &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+				"ns":   &types.AttributeValueMemberNS{Value: []string{"1", "1"}},
+			}},
+
+		{name: "not_document", sql: `INSERT INTO tbl VALUE ?`, mustError: true},
+		{name: "missing_params", sql: `INSERT INTO tbl VALUE {'a': ?, 'b': ?, 'c': ?}`, mustError: true},
+		{name: "mixed_set", sql: `INSERT INTO tbl VALUE {'a': <<'x', 1>>}`, mustError: true},
+		{name: "path_value", sql: `INSERT INTO tbl VALUE {'a': b}`, mustError: true},
+		{name: "missing_comma", sql: `INSERT INTO tbl VALUE {'a': 1 'b': 2}`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			item, err := evalPartiqlInsertItem(testCase.sql, params)
+			if testCase.mustError {
+				if err == nil {
+					t.Fatalf("%s failed: evaluation must fail", testName+"/"+testCase.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if !reflect.DeepEqual(item, testCase.expected) {
+				t.Fatalf("%s failed: expected %#v but received %#v", testName+"/"+testCase.name, testCase.expected, item)
+			}
+		})
+	}
+}
+
+func Test_buildScanInput(t *testing.T) {
+	testName := "Test_buildScanInput"
+	query, err := parsePartiqlSelect(`SELECT a, b.c FROM "tbl"."idx" WHERE a=? AND (b.c>10 OR a IS MISSING)`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	input, err := buildScanInput(query, []types.AttributeValue{&types.AttributeValueMemberS{Value: "x"}})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if aws.ToString(input.TableName) != "tbl" || aws.ToString(input.IndexName) != "idx" {
+		t.Fatalf("%s failed: unexpected table/index %#v", testName, input)
+	}
+	if expected := "#n0 = :v0 AND ((#n1.#n2 > :v1) OR (attribute_not_exists(#n0)))"; aws.ToString(input.FilterExpression) != expected {
+		t.Fatalf("%s failed: expected filter %#v but received %#v", testName, expected, aws.ToString(input.FilterExpression))
+	}
+	if expected := "#n0, #n1.#n2"; aws.ToString(input.ProjectionExpression) != expected {
+		t.Fatalf("%s failed: expected projection %#v but received %#v", testName, expected, aws.ToString(input.ProjectionExpression))
+	}
+	if expected := map[string]string{"#n0": "a", "#n1": "b", "#n2": "c"}; !reflect.DeepEqual(input.ExpressionAttributeNames, expected) {
+		t.Fatalf("%s failed: expected names %#v but received %#v", testName, expected, input.ExpressionAttributeNames)
+	}
+	expectedValues := map[string]types.AttributeValue{":v0": &types.AttributeValueMemberS{Value: "x"}, ":v1": &types.AttributeValueMemberN{Value: "10"}}
+	if !reflect.DeepEqual(input.ExpressionAttributeValues, expectedValues) {
+		t.Fatalf("%s failed: expected values %#v but received %#v", testName, expectedValues, input.ExpressionAttributeValues)
+	}
+
+	query, _ = parsePartiqlSelect(`SELECT * FROM tbl ORDER BY a`)
+	if _, err := buildScanInput(query, nil); err == nil {
+		t.Fatalf("%s failed: ORDER BY must be rejected", testName)
+	}
+}
+
+func Test_Stmt_Select_parallel(t *testing.T) {
+	testName := "Test_Stmt_Select_parallel"
+	testData := []struct {
+		name      string
+		sql       string
+		parallel  int
+		numInput  int
+		mustError bool
+	}{
+		{name: "parallel", sql: `SELECT * FROM "tbl" WHERE a=? LIMIT 10 WITH PARALLEL=4`, parallel: 4, numInput: 1},
+		{name: "parallel_consistent_read", sql: `SELECT * FROM "tbl" WITH ConsistentRead=true, WITH parallel=2`, parallel: 2},
+		{name: "no_parallel", sql: `SELECT * FROM "tbl" WHERE size(a) > 1`},
+
+		{name: "invalid_value", sql: `SELECT * FROM "tbl" WITH PARALLEL=abc`, mustError: true},
+		{name: "zero", sql: `SELECT * FROM "tbl" WITH PARALLEL=0`, mustError: true},
+		{name: "untranslatable_function", sql: `SELECT * FROM "tbl" WHERE size(a) > 1 WITH PARALLEL=2`, mustError: true},
+		{name: "order_by", sql: `SELECT * FROM "tbl" WHERE a=? ORDER BY b WITH PARALLEL=2`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(nil, testCase.sql)
+			if testCase.mustError && err == nil {
+				t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+			}
+			if testCase.mustError {
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			stmt, ok := s.(*StmtSelect)
+			if !ok {
+				t.Fatalf("%s failed: expected StmtSelect but received %T", testName+"/"+testCase.name, s)
+			}
+			if stmt.parallel != testCase.parallel || stmt.numInput != testCase.numInput || (stmt.parallel > 0 && stmt.selectQuery == nil) {
+				t.Fatalf("%s failed: unexpected parsing result %#v", testName+"/"+testCase.name, stmt)
+			}
+		})
+	}
+}
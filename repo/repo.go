@@ -0,0 +1,391 @@
+// Package repo provides a generic, struct-based repository on top of the godynamo driver.
+//
+// A Repository[T] learns the key attributes of the table from the `godynamo` struct tags of T, builds PartiQL
+// statements for Get/Put/Update/Delete/Query and decodes result items into T via `dynamodbav` struct tags:
+//
+//	type Session struct {
+//		App     string `dynamodbav:"app" godynamo:"pk"`
+//		User    string `dynamodbav:"user" godynamo:"sk"`
+//		Os      string `dynamodbav:"os,omitempty"`
+//		Version int64  `dynamodbav:"version" godynamo:"version"`
+//	}
+//
+// Supported `godynamo` tags are "pk" (partition key, required), "sk" (sort key) and "version" (an integer attribute used
+// for optimistic locking). The attribute name is taken from the `dynamodbav` tag, or the field name if not specified.
+//
+// @Available since v1.4.0
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/btnguyen2k/godynamo"
+)
+
+const (
+	tagName         = "godynamo"
+	tagPartitionKey = "pk"
+	tagSortKey      = "sk"
+	tagVersion      = "version"
+)
+
+// reLineBreaks matches line breaks, which are removed from conditions so that the WITH clause appended to SELECT
+// statements is parsed by the driver.
+var reLineBreaks = regexp.MustCompile(`\r?\n`)
+
+var (
+	// ErrNotFound is returned when the item does not exist.
+	//
+	// @Available since v1.4.0
+	ErrNotFound = errors.New("item not found")
+
+	// ErrVersionConflict is returned when an item is updated or deleted with optimistic locking, but the item does not
+	// exist or its version has been changed by another writer. It is the same as godynamo.ErrVersionConflict.
+	//
+	// @Available since v1.4.0
+	ErrVersionConflict = godynamo.ErrVersionConflict
+)
+
+// Executor executes statements, it is satisfied by *sql.DB, *sql.Conn and *sql.Tx.
+//
+// @Available since v1.4.0
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// attrField maps a struct field to an attribute.
+type attrField struct {
+	index []int  // index of the field, see reflect.Value.FieldByIndex
+	name  string // name of the attribute
+}
+
+// structMeta holds the key attributes and the attribute names learned from a struct type.
+type structMeta struct {
+	pk         *attrField
+	sk         *attrField // nil if the table has no sort key
+	version    *attrField // nil if optimistic locking is not used
+	attributes []string   // names of all attributes, in field order
+}
+
+// parseStructMeta learns the key attributes and attribute names from the struct tags of type t.
+func parseStructMeta(t reflect.Type) (*structMeta, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("type <%s> is not a struct", t)
+	}
+	meta := &structMeta{}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || (f.Anonymous && f.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("dynamodbav"); ok {
+			tagValue := strings.Split(tag, ",")[0]
+			if tagValue == "-" {
+				continue
+			}
+			if tagValue != "" {
+				name = tagValue
+			}
+		}
+		field := &attrField{index: f.Index, name: name}
+		meta.attributes = append(meta.attributes, name)
+		for _, opt := range strings.Split(f.Tag.Get(tagName), ",") {
+			switch strings.TrimSpace(strings.ToLower(opt)) {
+			case "":
+			case tagPartitionKey:
+				if meta.pk != nil {
+					return nil, fmt.Errorf("type <%s> has more than one partition key field", t)
+				}
+				meta.pk = field
+			case tagSortKey:
+				if meta.sk != nil {
+					return nil, fmt.Errorf("type <%s> has more than one sort key field", t)
+				}
+				meta.sk = field
+			case tagVersion:
+				if meta.version != nil {
+					return nil, fmt.Errorf("type <%s> has more than one version field", t)
+				}
+				switch f.Type.Kind() {
+				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				default:
+					return nil, fmt.Errorf("version field <%s> of type <%s> must be a signed integer", f.Name, t)
+				}
+				meta.version = field
+			default:
+				return nil, fmt.Errorf("invalid tag %s:%q of field <%s>", tagName, opt, f.Name)
+			}
+		}
+	}
+	if meta.pk == nil {
+		return nil, fmt.Errorf("type <%s> has no partition key field (tag %s:%q)", t, tagName, tagPartitionKey)
+	}
+	return meta, nil
+}
+
+// quote returns the quoted form of an identifier to be used in PartiQL statements.
+func quote(name string) string {
+	return `"` + name + `"`
+}
+
+/*----------------------------------------------------------------------*/
+
+// Repository provides CRUD operations on a DynamoDB table whose items are mapped to the struct type T.
+//
+// @Available since v1.4.0
+type Repository[T any] struct {
+	db        Executor
+	tableName string
+	meta      *structMeta
+	inTx      bool
+}
+
+// New creates a new Repository for the table tableName. The key attributes are learned from the struct tags of T.
+//
+// @Available since v1.4.0
+func New[T any](db Executor, tableName string) (*Repository[T], error) {
+	meta, err := parseStructMeta(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	_, inTx := db.(*sql.Tx)
+	return &Repository[T]{db: db, tableName: tableName, meta: meta, inTx: inTx}, nil
+}
+
+// WithTx returns a copy of the repository whose write operations are added to the transaction tx.
+//
+// Note: results of write operations are not available until the transaction is committed, hence ErrNotFound and
+// ErrVersionConflict are not returned; instead, the transaction fails to commit if a version check fails. Get and
+// Query are not supported inside a transaction.
+//
+// @Available since v1.4.0
+func (r *Repository[T]) WithTx(tx *sql.Tx) *Repository[T] {
+	return &Repository[T]{db: tx, tableName: r.tableName, meta: r.meta, inTx: true}
+}
+
+// TableName returns the name of the table the repository operates on.
+func (r *Repository[T]) TableName() string {
+	return r.tableName
+}
+
+// keyCondition builds the WHERE condition and parameters locating the item with the given keys.
+func (r *Repository[T]) keyCondition(pk interface{}, sk []interface{}) (string, []interface{}, error) {
+	if r.meta.sk == nil && len(sk) > 0 {
+		return "", nil, fmt.Errorf("table <%s> has no sort key", r.tableName)
+	}
+	if r.meta.sk != nil && len(sk) != 1 {
+		return "", nil, fmt.Errorf("sort key value of table <%s> is required", r.tableName)
+	}
+	where := quote(r.meta.pk.name) + "=?"
+	params := []interface{}{pk}
+	if r.meta.sk != nil {
+		where += " AND " + quote(r.meta.sk.name) + "=?"
+		params = append(params, sk[0])
+	}
+	for i, p := range params {
+		av, err := godynamo.ToAttributeValue(p)
+		if err != nil {
+			return "", nil, fmt.Errorf("error marshalling key value: %s", err)
+		}
+		params[i] = av
+	}
+	return where, params, nil
+}
+
+// itemKeys returns the key values of the item.
+func (r *Repository[T]) itemKeys(item *T) (pk interface{}, sk []interface{}) {
+	v := reflect.ValueOf(item).Elem()
+	pk = v.FieldByIndex(r.meta.pk.index).Interface()
+	if r.meta.sk != nil {
+		sk = []interface{}{v.FieldByIndex(r.meta.sk.index).Interface()}
+	}
+	return pk, sk
+}
+
+// versionField returns the version field of the item, or an invalid reflect.Value if optimistic locking is not used.
+func (r *Repository[T]) versionField(item *T) reflect.Value {
+	if r.meta.version == nil {
+		return reflect.Value{}
+	}
+	return reflect.ValueOf(item).Elem().FieldByIndex(r.meta.version.index)
+}
+
+// query executes a SELECT statement and decodes the returned items.
+func (r *Repository[T]) query(ctx context.Context, query string, params ...interface{}) ([]T, error) {
+	if r.inTx {
+		return nil, errors.New("reading items is not supported inside a transaction")
+	}
+	dbrows, err := r.db.QueryContext(ctx, query+" WITH RAW_ITEM=true", params...)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]T, 0)
+	err = godynamo.ScanAll(dbrows, &result)
+	return result, err
+}
+
+// Get fetches the item with the given keys; sk must be supplied if (and only if) the table has a sort key.
+// ErrNotFound is returned if the item does not exist.
+//
+// @Available since v1.4.0
+func (r *Repository[T]) Get(ctx context.Context, pk interface{}, sk ...interface{}) (*T, error) {
+	where, params, err := r.keyCondition(pk, sk)
+	if err != nil {
+		return nil, err
+	}
+	items, err := r.query(ctx, fmt.Sprintf(`SELECT * FROM %s WHERE %s`, quote(r.tableName), where), params...)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrNotFound
+	}
+	return &items[0], nil
+}
+
+// Query fetches the items matching the condition where (in PartiQL syntax, e.g. `"app"=? AND "active"=?`).
+//
+// @Available since v1.4.0
+func (r *Repository[T]) Query(ctx context.Context, where string, args ...interface{}) ([]T, error) {
+	return r.QueryIndex(ctx, "", where, args...)
+}
+
+// QueryIndex fetches the items of the index indexName matching the condition where. If indexName is empty, the
+// table is queried. Line breaks in where are replaced by spaces.
+//
+// @Available since v1.4.0
+func (r *Repository[T]) QueryIndex(ctx context.Context, indexName, where string, args ...interface{}) ([]T, error) {
+	query := `SELECT * FROM ` + quote(r.tableName)
+	if indexName != "" {
+		query += "." + quote(indexName)
+	}
+	if where = strings.TrimSpace(reLineBreaks.ReplaceAllString(where, " ")); where != "" {
+		query += " WHERE " + where
+	}
+	return r.query(ctx, query, args...)
+}
+
+// Put inserts a new item. The statement fails if an item with the same keys already exists.
+// If optimistic locking is used and the version of the item is 0, the version is set to 1.
+//
+// @Available since v1.4.0
+func (r *Repository[T]) Put(ctx context.Context, item *T) error {
+	version := r.versionField(item)
+	if version.IsValid() && version.Int() == 0 {
+		version.SetInt(1)
+	}
+	_, err := r.db.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s VALUE ?`, quote(r.tableName)), item)
+	return err
+}
+
+// Update replaces the non-key attributes of an existing item: attributes present in the marshalled item are set,
+// attributes omitted (e.g. zero values of omitempty fields) are removed.
+// ErrNotFound is returned if the item does not exist.
+//
+// If optimistic locking is used, the item is updated only if its stored version equals the version of item, and
+// the version is incremented (see clause WITH VERSION of UPDATE statement); ErrVersionConflict is returned if the
+// check fails.
+//
+// @Available since v1.4.0
+func (r *Repository[T]) Update(ctx context.Context, item *T) error {
+	attrs, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return err
+	}
+	version := r.versionField(item)
+	clauses := make([]string, 0, len(r.meta.attributes))
+	params := make([]interface{}, 0, len(r.meta.attributes)+3)
+	for _, name := range r.meta.attributes {
+		if name == r.meta.pk.name || (r.meta.sk != nil && name == r.meta.sk.name) || (version.IsValid() && name == r.meta.version.name) {
+			continue
+		}
+		if av, ok := attrs[name]; ok {
+			clauses = append(clauses, "SET "+quote(name)+"=?")
+			params = append(params, av)
+		} else {
+			clauses = append(clauses, "REMOVE "+quote(name))
+		}
+	}
+	if len(clauses) == 0 && !version.IsValid() {
+		return fmt.Errorf("type of item has no non-key attribute to update")
+	}
+	pk, sk := r.itemKeys(item)
+	where, keyParams, err := r.keyCondition(pk, sk)
+	if err != nil {
+		return err
+	}
+	params = append(params, keyParams...)
+	query := fmt.Sprintf(`UPDATE %s %s WHERE %s`, quote(r.tableName), strings.Join(clauses, " "), where)
+	if version.IsValid() {
+		// the driver increments the version and checks the expected version, supplied as the last argument
+		query += " WITH VERSION=" + r.meta.version.name
+		params = append(params, version.Int())
+	}
+	if err = r.execOne(ctx, version.IsValid(), query, params...); err != nil {
+		return err
+	}
+	if version.IsValid() {
+		version.SetInt(version.Int() + 1)
+	}
+	return nil
+}
+
+// Delete deletes the item with the keys of item. ErrNotFound is returned if the item does not exist.
+//
+// If optimistic locking is used, the item is deleted only if its stored version equals the version of item;
+// ErrVersionConflict is returned if the check fails.
+//
+// @Available since v1.4.0
+func (r *Repository[T]) Delete(ctx context.Context, item *T) error {
+	pk, sk := r.itemKeys(item)
+	where, params, err := r.keyCondition(pk, sk)
+	if err != nil {
+		return err
+	}
+	version := r.versionField(item)
+	if version.IsValid() {
+		where += " AND " + quote(r.meta.version.name) + "=?"
+		params = append(params, version.Int())
+	}
+	return r.execOne(ctx, version.IsValid(), fmt.Sprintf(`DELETE FROM %s WHERE %s`, quote(r.tableName), where), params...)
+}
+
+// DeleteByKey deletes the item with the given keys, without version check. ErrNotFound is returned if the item does
+// not exist.
+//
+// @Available since v1.4.0
+func (r *Repository[T]) DeleteByKey(ctx context.Context, pk interface{}, sk ...interface{}) error {
+	where, params, err := r.keyCondition(pk, sk)
+	if err != nil {
+		return err
+	}
+	return r.execOne(ctx, false, fmt.Sprintf(`DELETE FROM %s WHERE %s`, quote(r.tableName), where), params...)
+}
+
+// execOne executes a write statement that is expected to affect exactly one item. If no item is affected,
+// ErrVersionConflict is returned if the statement has a version check, ErrNotFound otherwise.
+func (r *Repository[T]) execOne(ctx context.Context, versionChecked bool, query string, params ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, params...)
+	if err != nil || r.inTx {
+		return err
+	}
+	numRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numRows == 0 {
+		if versionChecked {
+			return ErrVersionConflict
+		}
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type testSession struct {
+	App     string `dynamodbav:"app" godynamo:"pk"`
+	User    string `dynamodbav:"user" godynamo:"sk"`
+	Os      string `dynamodbav:"os,omitempty"`
+	Active  bool
+	Ignored string `dynamodbav:"-"`
+	Version int64  `dynamodbav:"ver" godynamo:"version"`
+}
+
+type testUser struct {
+	Id   string `godynamo:"pk"`
+	Name string `dynamodbav:"name,omitempty"`
+}
+
+func TestParseStructMeta(t *testing.T) {
+	testName := "TestParseStructMeta"
+	meta, err := parseStructMeta(reflect.TypeOf(testSession{}))
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if meta.pk.name != "app" || meta.sk.name != "user" || meta.version.name != "ver" {
+		t.Fatalf("%s failed: unexpected key attributes %#v", testName, meta)
+	}
+	if expected := []string{"app", "user", "os", "Active", "ver"}; !reflect.DeepEqual(meta.attributes, expected) {
+		t.Fatalf("%s failed: expected attributes %#v but received %#v", testName, expected, meta.attributes)
+	}
+
+	testCases := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "not_struct", value: "string"},
+		{name: "no_pk", value: struct{ Id string }{}},
+		{name: "two_pk", value: struct {
+			Id1 string `godynamo:"pk"`
+			Id2 string `godynamo:"pk"`
+		}{}},
+		{name: "invalid_tag", value: struct {
+			Id string `godynamo:"primary"`
+		}{}},
+		{name: "version_not_int", value: struct {
+			Id      string `godynamo:"pk"`
+			Version string `godynamo:"version"`
+		}{}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := parseStructMeta(reflect.TypeOf(testCase.value)); err == nil {
+				t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+			}
+		})
+	}
+}
+
+type testResult int64
+
+func (r testResult) LastInsertId() (int64, error) { return 0, errors.New("not supported") }
+func (r testResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+// testExecutor records the executed statements.
+type testExecutor struct {
+	queries      []string
+	args         [][]interface{}
+	rowsAffected int64
+}
+
+func (e *testExecutor) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	e.queries = append(e.queries, query)
+	e.args = append(e.args, args)
+	return testResult(e.rowsAffected), nil
+}
+
+func (e *testExecutor) QueryContext(_ context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	e.queries = append(e.queries, query)
+	e.args = append(e.args, args)
+	return nil, errors.New("not supported")
+}
+
+func TestRepository_Write(t *testing.T) {
+	testName := "TestRepository_Write"
+	executor := &testExecutor{rowsAffected: 1}
+	r, err := New[testSession](executor, "sessions")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	ctx := context.Background()
+
+	item := &testSession{App: "app0", User: "user0", Active: true}
+	if err = r.Put(ctx, item); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Put", err)
+	}
+	if expected := `INSERT INTO "sessions" VALUE ?`; executor.queries[0] != expected || item.Version != 1 || executor.args[0][0] != item {
+		t.Fatalf("%s failed: unexpected statement %#v / %#v / version %d", testName+"/Put", executor.queries[0], executor.args[0], item.Version)
+	}
+
+	if err = r.Update(ctx, item); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Update", err)
+	}
+	if expected := `UPDATE "sessions" REMOVE "os" SET "Active"=? WHERE "app"=? AND "user"=? WITH VERSION=ver`; executor.queries[1] != expected {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName+"/Update", expected, executor.queries[1])
+	}
+	expectedArgs := []interface{}{
+		&types.AttributeValueMemberBOOL{Value: true},
+		&types.AttributeValueMemberS{Value: "app0"}, &types.AttributeValueMemberS{Value: "user0"}, int64(1),
+	}
+	if !reflect.DeepEqual(executor.args[1], expectedArgs) || item.Version != 2 {
+		t.Fatalf("%s failed: expected %#v but received %#v / version %d", testName+"/Update", expectedArgs, executor.args[1], item.Version)
+	}
+
+	if err = r.Delete(ctx, item); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Delete", err)
+	}
+	if expected := `DELETE FROM "sessions" WHERE "app"=? AND "user"=? AND "ver"=?`; executor.queries[2] != expected {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName+"/Delete", expected, executor.queries[2])
+	}
+
+	executor.rowsAffected = 0
+	if err = r.Update(ctx, item); !errors.Is(err, ErrVersionConflict) || item.Version != 2 {
+		t.Fatalf("%s failed: expected ErrVersionConflict but received %v / version %d", testName+"/Update", err, item.Version)
+	}
+	if err = r.Delete(ctx, item); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("%s failed: expected ErrVersionConflict but received %v", testName+"/Delete", err)
+	}
+	if err = r.DeleteByKey(ctx, "app0", "user0"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("%s failed: expected ErrNotFound but received %v", testName+"/DeleteByKey", err)
+	}
+	if err = r.DeleteByKey(ctx, "app0"); err == nil {
+		t.Fatalf("%s failed: sort key value must be required", testName+"/DeleteByKey")
+	}
+}
+
+func TestRepository_NoVersion(t *testing.T) {
+	testName := "TestRepository_NoVersion"
+	executor := &testExecutor{}
+	r, err := New[testUser](executor, "users")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	ctx := context.Background()
+	if err = r.Update(ctx, &testUser{Id: "1", Name: "User 1"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("%s failed: expected ErrNotFound but received %v", testName+"/Update", err)
+	}
+	if expected := `UPDATE "users" SET "name"=? WHERE "Id"=?`; executor.queries[0] != expected {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName+"/Update", expected, executor.queries[0])
+	}
+	if _, err = r.Get(ctx, "1", "extra"); err == nil {
+		t.Fatalf("%s failed: table has no sort key", testName+"/Get")
+	}
+	_, _ = r.QueryIndex(ctx, "idx_name", "\"name\"=?\nAND begins_with(\"Id\", ?)", "User 1", "1")
+	if expected := `SELECT * FROM "users"."idx_name" WHERE "name"=? AND begins_with("Id", ?) WITH RAW_ITEM=true`; executor.queries[1] != expected {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName+"/QueryIndex", expected, executor.queries[1])
+	}
+
+	txRepo := r.WithTx(nil)
+	if _, err = txRepo.Query(ctx, ""); err == nil {
+		t.Fatalf("%s failed: reading must fail inside a transaction", testName+"/Query")
+	}
+}
@@ -0,0 +1,125 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxTotalSegments is the maximum number of segments of a parallel scan, as allowed by DynamoDB.
+const maxTotalSegments = 1000000
+
+// buildScanInput translates an analyzed SELECT statement to the input of a low-level Scan.
+func buildScanInput(query *partiqlSelect, params []types.AttributeValue) (*dynamodb.ScanInput, error) {
+	if query.orderBy != nil {
+		return nil, errors.New("ORDER BY is not supported by scan")
+	}
+	b := newExpressionBuilder(params)
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String(query.tableName),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	if query.indexName != "" {
+		input.IndexName = aws.String(query.indexName)
+	}
+	if query.where != nil {
+		filter, err := b.conditions(query.where.conjuncts())
+		if err != nil {
+			return nil, err
+		}
+		input.FilterExpression = aws.String(filter)
+	}
+	if len(query.projection) > 0 {
+		input.ProjectionExpression = aws.String(b.projection(query.projection))
+	}
+	input.ExpressionAttributeNames = b.attributeNames()
+	input.ExpressionAttributeValues = b.attributeValues()
+	return input, nil
+}
+
+// executeParallelScanContext executes a SELECT statement as a parallel scan of totalSegments segments, each segment is
+// scanned by a separate goroutine. Items of all segments are merged into a single output, in no particular order.
+func (c *Conn) executeParallelScanContext(ctx context.Context, stmt *StmtSelect, values []driver.NamedValue) (*dynamodb.ExecuteStatementOutput, error) {
+	if c.txMode != txNone {
+		return nil, errors.New("parallel scan is not supported in transaction")
+	}
+	params, err := stmt.marshalParameters(values)
+	if err != nil {
+		return nil, err
+	}
+	input, err := buildScanInput(stmt.selectQuery, params)
+	if err != nil {
+		return nil, err
+	}
+	if consistentRead, ok := stmt.withOpts["CONSISTENT_READ"]; ok {
+		input.ConsistentRead = aws.Bool(consistentRead.FirstBool())
+	} else if consistentRead, ok = stmt.withOpts["CONSISTENTREAD"]; ok {
+		input.ConsistentRead = aws.Bool(consistentRead.FirstBool())
+	}
+	limit := 0
+	if stmt.limit != nil {
+		limit = int(*stmt.limit)
+	}
+
+	parentCtx := ctx
+	ctx, cancel := context.WithCancel(parentCtx)
+	defer cancel()
+	var lock sync.Mutex
+	var scanErr error
+	items := make([]map[string]types.AttributeValue, 0)
+	consumed, pages := 0.0, 0
+	wg := sync.WaitGroup{}
+	for segment := 0; segment < stmt.parallel; segment++ {
+		segmentInput := *input
+		segmentInput.Segment = aws.Int32(int32(segment))
+		segmentInput.TotalSegments = aws.Int32(int32(stmt.parallel))
+		wg.Add(1)
+		go func(input *dynamodb.ScanInput) {
+			defer wg.Done()
+			for {
+				output, err := c.client.Scan(ctx, input)
+				lock.Lock()
+				if err != nil {
+					if scanErr == nil && ctx.Err() == nil {
+						scanErr = wrapAwsError(err, nil)
+					}
+					lock.Unlock()
+					cancel()
+					return
+				}
+				consumed += capacityUnits(output.ConsumedCapacity)
+				pages++
+				items = append(items, output.Items...)
+				done := limit > 0 && len(items) >= limit
+				lock.Unlock()
+				if done {
+					// LIMIT reached, stop other segments
+					cancel()
+					return
+				}
+				if len(output.LastEvaluatedKey) == 0 {
+					return
+				}
+				input.ExclusiveStartKey = output.LastEvaluatedKey
+			}
+		}(&segmentInput)
+	}
+	wg.Wait()
+	c.lastConsumedCapacity = consumed
+	c.lastPageCount = pages
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	if err := parentCtx.Err(); err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return &dynamodb.ExecuteStatementOutput{Items: items}, nil
+}
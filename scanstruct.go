@@ -0,0 +1,148 @@
+package godynamo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// RawItemColumn is the name of the column that carries the whole item as map[string]types.AttributeValue.
+// The column is appended to the result set of a SELECT statement with clause WITH RAW_ITEM=true.
+//
+// @Available since v1.4.0
+const RawItemColumn = "*"
+
+var rawItemType = reflect.TypeOf(map[string]types.AttributeValue{})
+
+// ScanStruct decodes the current row of rows into dest, which must be a pointer to a struct (or a map).
+// Attributes are mapped to struct fields via `dynamodbav` struct tags, using the SDK attributevalue decoder.
+//
+// If the result set has the column RawItemColumn (SELECT statement with clause WITH RAW_ITEM=true), the raw item is
+// decoded in one pass without loss. Otherwise, the item is rebuilt from the column values; columns with nil values
+// (attributes missing from a sparse item) are skipped.
+//
+// As with rows.Scan, rows.Next must be called before ScanStruct.
+//
+// @Available since v1.4.0
+func ScanStruct(rows *sql.Rows, dest interface{}) error {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	columns := make([]string, len(columnTypes))
+	dbTypes := make([]string, len(columnTypes))
+	for i, ct := range columnTypes {
+		columns[i], dbTypes[i] = ct.Name(), ct.DatabaseTypeName()
+	}
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return err
+	}
+	item, err := rowToItem(columns, dbTypes, values)
+	if err != nil {
+		return err
+	}
+	return attributevalue.UnmarshalMap(item, dest)
+}
+
+// ScanAll decodes all remaining rows into dest, which must be a pointer to a slice of structs (or pointers to structs,
+// or maps), then closes rows. See ScanStruct for how rows are decoded.
+//
+// @Available since v1.4.0
+func ScanAll(rows *sql.Rows, dest interface{}) error {
+	defer func() { _ = rows.Close() }()
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.IsNil() || destValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("dest must be a non-nil pointer to a slice, received %T", dest)
+	}
+	sliceValue := destValue.Elem()
+	elemType := sliceValue.Type().Elem()
+	for rows.Next() {
+		elem := reflect.New(elemType)
+		if err := ScanStruct(rows, elem.Interface()); err != nil {
+			return err
+		}
+		sliceValue.Set(reflect.Append(sliceValue, elem.Elem()))
+	}
+	return rows.Err()
+}
+
+// rowToItem builds the item from the column values of a row. If the row has the column RawItemColumn, the raw item is
+// returned as-is.
+func rowToItem(columns, dbTypes []string, values []interface{}) (map[string]types.AttributeValue, error) {
+	for i, col := range columns {
+		if col == RawItemColumn {
+			if item, ok := values[i].(map[string]types.AttributeValue); ok {
+				return item, nil
+			}
+		}
+	}
+	item := make(map[string]types.AttributeValue, len(columns))
+	for i, col := range columns {
+		if values[i] == nil {
+			continue
+		}
+		av, err := interfaceToAttributeValue(values[i], dbTypes[i])
+		if err != nil {
+			return nil, fmt.Errorf("error converting value of column <%s>: %s", col, err)
+		}
+		item[col] = av
+	}
+	return item, nil
+}
+
+// interfaceToAttributeValue converts a column value back to an AttributeValue. Numbers returned as json.Number,
+// *BigNumber, *big.Float or string (see NumberMode) are converted back to N attributes.
+func interfaceToAttributeValue(value interface{}, dbType string) (types.AttributeValue, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return &types.AttributeValueMemberN{Value: v.String()}, nil
+	case *BigNumber:
+		if v == nil {
+			return nil, errors.New("nil *BigNumber")
+		}
+		return &types.AttributeValueMemberN{Value: v.String()}, nil
+	case *big.Float:
+		if v == nil {
+			return nil, errors.New("nil *big.Float")
+		}
+		return &types.AttributeValueMemberN{Value: v.Text('g', -1)}, nil
+	case string:
+		if dbType == "N" {
+			return &types.AttributeValueMemberN{Value: v}, nil
+		}
+	case []interface{}:
+		elemDbType := ""
+		if dbType == "NS" {
+			elemDbType = "N"
+		}
+		result := make([]types.AttributeValue, len(v))
+		for i, e := range v {
+			var err error
+			if result[i], err = interfaceToAttributeValue(e, elemDbType); err != nil {
+				return nil, err
+			}
+		}
+		return &types.AttributeValueMemberL{Value: result}, nil
+	case map[string]interface{}:
+		result := make(map[string]types.AttributeValue, len(v))
+		for k, e := range v {
+			var err error
+			if result[k], err = interfaceToAttributeValue(e, ""); err != nil {
+				return nil, err
+			}
+		}
+		return &types.AttributeValueMemberM{Value: result}, nil
+	}
+	return attributevalue.Marshal(value)
+}
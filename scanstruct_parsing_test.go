@@ -0,0 +1,126 @@
+package godynamo
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type _testScanStructItem struct {
+	App    string   `dynamodbav:"app"`
+	User   string   `dynamodbav:"user"`
+	Count  int64    `dynamodbav:"count"`
+	Tags   []string `dynamodbav:"tags"`
+	Active bool     `dynamodbav:"active"`
+}
+
+func Test_rowToItem(t *testing.T) {
+	testName := "Test_rowToItem"
+	expected := _testScanStructItem{App: "app0", User: "user0", Count: 9007199254740993, Tags: []string{"a", "b"}, Active: true}
+	testData := []struct {
+		name     string
+		columns  []string
+		dbTypes  []string
+		values   []interface{}
+		expected _testScanStructItem
+	}{
+		{name: "float", columns: []string{"active", "app", "count", "tags", "user"}, dbTypes: []string{"BOOL", "S", "N", "SS", "S"},
+			values: []interface{}{true, "app0", float64(123), []string{"a", "b"}, "user0"}, expected: _testScanStructItem{App: "app0", User: "user0", Count: 123, Tags: []string{"a", "b"}, Active: true}},
+		{name: "json_number", columns: []string{"app", "count"}, dbTypes: []string{"S", "N"},
+			values: []interface{}{"app0", json.Number("9007199254740993")}, expected: _testScanStructItem{App: "app0", Count: 9007199254740993}},
+		{name: "string", columns: []string{"app", "count"}, dbTypes: []string{"S", "N"},
+			values: []interface{}{"app0", "9007199254740993"}, expected: _testScanStructItem{App: "app0", Count: 9007199254740993}},
+		{name: "big", columns: []string{"app", "count"}, dbTypes: []string{"S", "N"},
+			values: []interface{}{"app0", big.NewFloat(123)}, expected: _testScanStructItem{App: "app0", Count: 123}},
+		{name: "big_number", columns: []string{"app", "count"}, dbTypes: []string{"S", "N"},
+			values: []interface{}{"app0", _bigNumber("9007199254740993")}, expected: _testScanStructItem{App: "app0", Count: 9007199254740993}},
+		{name: "sparse", columns: []string{"active", "app", "count", "user"}, dbTypes: []string{"BOOL", "S", "N", "S"},
+			values: []interface{}{nil, "app1", nil, "user1"}, expected: _testScanStructItem{App: "app1", User: "user1"}},
+		{name: "raw_item", columns: []string{"app", RawItemColumn}, dbTypes: []string{"S", "M"},
+			values: []interface{}{"ignored", map[string]types.AttributeValue{
+				"app":    &types.AttributeValueMemberS{Value: "app0"},
+				"user":   &types.AttributeValueMemberS{Value: "user0"},
+				"count":  &types.AttributeValueMemberN{Value: "9007199254740993"},
+				"tags":   &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+				"active": &types.AttributeValueMemberBOOL{Value: true},
+			}}, expected: expected},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			item, err := rowToItem(testCase.columns, testCase.dbTypes, testCase.values)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			var dest _testScanStructItem
+			if err = attributevalue.UnmarshalMap(item, &dest); err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if !reflect.DeepEqual(dest, testCase.expected) {
+				t.Fatalf("%s failed: expected %#v but received %#v", testName+"/"+testCase.name, testCase.expected, dest)
+			}
+		})
+	}
+}
+
+func Test_ResultResultSet_rawItem(t *testing.T) {
+	testName := "Test_ResultResultSet_rawItem"
+	items := []map[string]types.AttributeValue{
+		{"app": &types.AttributeValueMemberS{Value: "app0"}, "count": &types.AttributeValueMemberN{Value: "1"}},
+		{"app": &types.AttributeValueMemberS{Value: "app1"}, "os": &types.AttributeValueMemberS{Value: "linux"}},
+	}
+	rs := (&ResultResultSet{stmtOutput: &dynamodb.ExecuteStatementOutput{Items: items}, rawItem: true}).init()
+	if expected := []string{"app", "count", "os", RawItemColumn}; !reflect.DeepEqual(rs.Columns(), expected) {
+		t.Fatalf("%s failed: expected columns %#v but received %#v", testName, expected, rs.Columns())
+	}
+	if rs.ColumnTypeScanType(3) != rawItemType || rs.ColumnTypeDatabaseTypeName(3) != "M" {
+		t.Fatalf("%s failed: unexpected type of raw item column %s/%s", testName, rs.ColumnTypeScanType(3), rs.ColumnTypeDatabaseTypeName(3))
+	}
+	for i, item := range items {
+		dest := make([]driver.Value, len(rs.Columns()))
+		if err := rs.Next(dest); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		if !reflect.DeepEqual(dest[3], item) {
+			t.Fatalf("%s failed: expected raw item %#v but received %#v", testName, item, dest[3])
+		}
+		if dest[0] != items[i]["app"].(*types.AttributeValueMemberS).Value {
+			t.Fatalf("%s failed: unexpected value of column app %#v", testName, dest[0])
+		}
+	}
+
+	rs = (&ResultResultSet{stmtOutput: &dynamodb.ExecuteStatementOutput{Items: items}, columnList: []string{"os", "app"}, rawItem: true}).init()
+	if expected := []string{"os", "app", RawItemColumn}; !reflect.DeepEqual(rs.Columns(), expected) {
+		t.Fatalf("%s failed: expected columns %#v but received %#v", testName, expected, rs.Columns())
+	}
+}
+
+func Test_Stmt_Select_rawItem(t *testing.T) {
+	testName := "Test_Stmt_Select_rawItem"
+	testData := []struct {
+		name    string
+		sql     string
+		rawItem bool
+	}{
+		{name: "no_raw_item", sql: `SELECT * FROM "tbl" WHERE app=?`},
+		{name: "raw_item", sql: `SELECT * FROM "tbl" WHERE app=? WITH RAW_ITEM=true`, rawItem: true},
+		{name: "raw_item_with_limit", sql: `SELECT * FROM "tbl" LIMIT 10 WITH ConsistentRead=true, WITH RawItem=true`, rawItem: true},
+		{name: "raw_item_false", sql: `SELECT * FROM "tbl" WITH RAW_ITEM=false`},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(nil, testCase.sql)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if stmt := s.(*StmtSelect); stmt.rawItem != testCase.rawItem {
+				t.Fatalf("%s failed: expected rawItem %#v but received %#v", testName+"/"+testCase.name, testCase.rawItem, stmt.rawItem)
+			}
+		})
+	}
+}
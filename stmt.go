@@ -1,16 +1,20 @@
 package godynamo
 
 import (
+	"context"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/btnguyen2k/consu/reddo"
 )
 
@@ -38,16 +42,92 @@ var (
 	reSelect = regexp.MustCompile(`(?im)^SELECT\s+.*?` + with + `$`)
 	reUpdate = regexp.MustCompile(`(?im)^UPDATE\s+`)
 	reDelete = regexp.MustCompile(`(?im)^DELETE\s+FROM\s+`)
+
+	reExport  = regexp.MustCompile(`(?is)^EXPORT\s*\(\s*(SELECT\s.*)\)\s*TO\s+'([^']*)'\s+FORMAT\s+(\w+)$`)
+	reExplain = regexp.MustCompile(`(?is)^EXPLAIN\s+(SELECT\s.*)$`)
+	reImport  = regexp.MustCompile(`(?is)^IMPORT\s+INTO\s+"?([\w\-\.]+)"?\s+FROM\s+'([^']*)'\s+FORMAT\s+(\w+)` + with + `$`)
 )
 
+// reTimeoutOpt matches the clause WITH TIMEOUT=<ms>, which is supported by all statements.
+var reTimeoutOpt = regexp.MustCompile(`(?i)(\s*,)?\s+WITH\s+TIMEOUT\s*=\s*([^\s,]*)(\s*,)?`)
+
+// splitTimeoutOpt removes the clause WITH TIMEOUT=<ms> (outside string literals) from a statement, and returns the
+// specified timeout (0 if not specified).
+func splitTimeoutOpt(query string) (string, time.Duration, error) {
+	locs := reTimeoutOpt.FindAllStringSubmatchIndex(maskStringLiterals(query), -1)
+	var timeout time.Duration
+	for i := len(locs) - 1; i >= 0; i-- {
+		loc := locs[i]
+		value := query[loc[4]:loc[5]]
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || ms <= 0 {
+			return query, 0, fmt.Errorf("invalid TIMEOUT value: %s", value)
+		}
+		if i == len(locs)-1 {
+			timeout = time.Duration(ms) * time.Millisecond
+		}
+		query = query[:loc[0]] + query[loc[1]:]
+	}
+	return strings.TrimSpace(query), timeout, nil
+}
+
+// errInvalidQuery is the parsing error of statements not recognized by the driver.
+var errInvalidQuery = errors.New("invalid query")
+
+// parseQuery parses a statement.
+//
+// @Since v1.4.0 all statements support clause WITH TIMEOUT=<ms>, which bounds the statement's execution instead of the
+// connection's timeout (DSN option TimeoutMs).
+//
+// @Since v1.4.0 statements that may modify tables or data are rejected with ErrReadOnly if the connection is read-only
+// (DSN option ReadOnly) or has an ongoing read-only transaction.
+//
+// @Since v1.4.0 parsing errors are returned as *ParseError.
+//
+// @Since v1.4.0 parsed statements are cached per DSN, see DSN option StatementCacheSize.
 func parseQuery(c *Conn, query string) (driver.Stmt, error) {
-	query = strings.TrimSpace(query)
+	query, timeout, err := splitTimeoutOpt(strings.TrimSpace(query))
+	if err != nil {
+		return nil, newParseError(query, -1, err)
+	}
+	stmt, err := parseCachedStatement(c, query)
+	if err != nil {
+		position := -1
+		if stmt == nil && errors.Is(err, errInvalidQuery) {
+			position = 0
+		}
+		err = newParseError(query, position, err)
+	}
+	if err == nil && !isReadOnlyStmt(stmt) {
+		if err = c.checkWritable(query); err != nil {
+			return nil, err
+		}
+	}
+	if stmt != nil && timeout > 0 {
+		stmt.(interface{ setTimeout(time.Duration) }).setTimeout(timeout)
+	}
+	return stmt, err
+}
+
+// isReadOnlyStmt returns true if the statement only reads tables and data. Statements not listed here, including future
+// ones, are considered write statements.
+//
+// @Available since v1.4.0
+func isReadOnlyStmt(stmt driver.Stmt) bool {
+	switch stmt.(type) {
+	case *StmtListTables, *StmtDescribeTable, *StmtDescribeLSI, *StmtDescribeGSI, *StmtSelect, *StmtExplain, *StmtExport:
+		return true
+	}
+	return false
+}
+
+func parseStatement(c *Conn, query string) (driver.Stmt, error) {
 	if re := reCreateTable; re.MatchString(query) {
 		groups := re.FindAllStringSubmatch(query, -1)
 		stmt := &StmtCreateTable{
 			Stmt:        &Stmt{query: query, conn: c, numInput: 0},
 			ifNotExists: strings.TrimSpace(groups[0][1]) != "",
-			tableName:   strings.TrimSpace(groups[0][2]),
+			tableName:   c.physicalTableName(strings.TrimSpace(groups[0][2])),
 			withOptsStr: " " + strings.TrimSpace(groups[0][3]),
 		}
 		if err := stmt.parse(); err != nil {
@@ -65,7 +145,7 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		groups := re.FindAllStringSubmatch(query, -1)
 		stmt := &StmtDescribeTable{
 			Stmt:      &Stmt{query: query, conn: c, numInput: 0},
-			tableName: strings.TrimSpace(groups[0][1]),
+			tableName: c.physicalTableName(strings.TrimSpace(groups[0][1])),
 		}
 		return stmt, stmt.validate()
 	}
@@ -73,7 +153,7 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		groups := re.FindAllStringSubmatch(query, -1)
 		stmt := &StmtAlterTable{
 			Stmt:        &Stmt{query: query, conn: c, numInput: 0},
-			tableName:   strings.TrimSpace(groups[0][1]),
+			tableName:   c.physicalTableName(strings.TrimSpace(groups[0][1])),
 			withOptsStr: " " + strings.TrimSpace(groups[0][2]),
 		}
 		if err := stmt.parse(); err != nil {
@@ -85,7 +165,7 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		groups := re.FindAllStringSubmatch(query, -1)
 		stmt := &StmtDropTable{
 			Stmt:      &Stmt{query: query, conn: c, numInput: 0},
-			tableName: strings.TrimSpace(groups[0][3]),
+			tableName: c.physicalTableName(strings.TrimSpace(groups[0][3])),
 			ifExists:  strings.TrimSpace(groups[0][2]) != "",
 		}
 		return stmt, stmt.validate()
@@ -95,7 +175,7 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		groups := re.FindAllStringSubmatch(query, -1)
 		stmt := &StmtDescribeLSI{
 			Stmt:      &Stmt{query: query, conn: c, numInput: 0},
-			tableName: strings.TrimSpace(groups[0][2]),
+			tableName: c.physicalTableName(strings.TrimSpace(groups[0][2])),
 			indexName: strings.TrimSpace(groups[0][1]),
 		}
 		return stmt, stmt.validate()
@@ -107,7 +187,7 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 			Stmt:        &Stmt{query: query, conn: c, numInput: 0},
 			ifNotExists: strings.TrimSpace(groups[0][1]) != "",
 			indexName:   strings.TrimSpace(groups[0][2]),
-			tableName:   strings.TrimSpace(groups[0][3]),
+			tableName:   c.physicalTableName(strings.TrimSpace(groups[0][3])),
 			withOptsStr: " " + strings.TrimSpace(groups[0][4]),
 		}
 		if err := stmt.parse(); err != nil {
@@ -119,7 +199,7 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		groups := re.FindAllStringSubmatch(query, -1)
 		stmt := &StmtDescribeGSI{
 			Stmt:      &Stmt{query: query, conn: c, numInput: 0},
-			tableName: strings.TrimSpace(groups[0][2]),
+			tableName: c.physicalTableName(strings.TrimSpace(groups[0][2])),
 			indexName: strings.TrimSpace(groups[0][1]),
 		}
 		return stmt, stmt.validate()
@@ -129,7 +209,7 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		stmt := &StmtAlterGSI{
 			Stmt:        &Stmt{query: query, conn: c, numInput: 0},
 			indexName:   strings.TrimSpace(groups[0][1]),
-			tableName:   strings.TrimSpace(groups[0][2]),
+			tableName:   c.physicalTableName(strings.TrimSpace(groups[0][2])),
 			withOptsStr: " " + strings.TrimSpace(groups[0][3]),
 		}
 		if err := stmt.parse(); err != nil {
@@ -141,14 +221,65 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		groups := re.FindAllStringSubmatch(query, -1)
 		stmt := &StmtDropGSI{
 			Stmt:      &Stmt{query: query, conn: c, numInput: 0},
-			tableName: strings.TrimSpace(groups[0][4]),
+			tableName: c.physicalTableName(strings.TrimSpace(groups[0][4])),
 			indexName: strings.TrimSpace(groups[0][3]),
 			ifExists:  strings.TrimSpace(groups[0][2]) != "",
 		}
 		return stmt, stmt.validate()
 	}
 
+	if re := reExport; re.MatchString(query) {
+		groups := re.FindAllStringSubmatch(query, -1)
+		selectStmt, err := parseQuery(c, groups[0][1])
+		if err != nil {
+			return nil, err
+		}
+		stmtSelect, ok := selectStmt.(*StmtSelect)
+		if !ok {
+			return nil, fmt.Errorf("invalid SELECT statement in EXPORT: %s", groups[0][1])
+		}
+		stmt := &StmtExport{
+			Stmt:       &Stmt{query: query, conn: c, numInput: stmtSelect.numInput},
+			selectStmt: stmtSelect,
+			fileName:   strings.TrimSpace(groups[0][2]),
+			format:     strings.ToUpper(strings.TrimSpace(groups[0][3])),
+		}
+		return stmt, stmt.validate()
+	}
+
+	if re := reExplain; re.MatchString(query) {
+		groups := re.FindAllStringSubmatch(query, -1)
+		selectStmt, err := parseQuery(c, groups[0][1])
+		if err != nil {
+			return nil, err
+		}
+		stmtSelect, ok := selectStmt.(*StmtSelect)
+		if !ok {
+			return nil, fmt.Errorf("invalid SELECT statement in EXPLAIN: %s", groups[0][1])
+		}
+		stmt := &StmtExplain{
+			Stmt:       &Stmt{query: query, conn: c, numInput: stmtSelect.numInput},
+			selectStmt: stmtSelect,
+		}
+		return stmt, stmt.validate()
+	}
+	if re := reImport; re.MatchString(query) {
+		groups := re.FindAllStringSubmatch(query, -1)
+		stmt := &StmtImport{
+			Stmt:        &Stmt{query: query, conn: c, numInput: 0},
+			tableName:   c.physicalTableName(strings.TrimSpace(groups[0][1])),
+			fileName:    strings.TrimSpace(groups[0][2]),
+			format:      strings.ToUpper(strings.TrimSpace(groups[0][3])),
+			withOptsStr: " " + strings.TrimSpace(groups[0][4]),
+		}
+		if err := stmt.parse(); err != nil {
+			return nil, err
+		}
+		return stmt, stmt.validate()
+	}
+
 	if re := reInsert; re.MatchString(query) {
+		query = c.prefixTargetTable(query, re)
 		stmt := &StmtInsert{
 			StmtExecutable: &StmtExecutable{Stmt: &Stmt{query: query, conn: c, numInput: 0}},
 		}
@@ -160,7 +291,7 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 	if re := reSelect; re.MatchString(query) {
 		groups := re.FindAllStringSubmatch(query, -1)
 		withOptsStr := groups[0][1]
-		query = query[0 : len(query)-len(withOptsStr)]
+		query = c.prefixTargetTable(query[0:len(query)-len(withOptsStr)], reSelectFrom)
 		stmt := &StmtSelect{
 			StmtExecutable: &StmtExecutable{Stmt: &Stmt{query: query, conn: c, numInput: 0}},
 			withOptsStr:    " " + strings.TrimSpace(withOptsStr),
@@ -171,8 +302,10 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		return stmt, stmt.validate()
 	}
 	if re := reUpdate; re.MatchString(query) {
+		query, withOptsStr := splitTrailingWithOpts(c.prefixTargetTable(query, re))
 		stmt := &StmtUpdate{
 			StmtExecutable: &StmtExecutable{Stmt: &Stmt{query: query, conn: c, numInput: 0}},
+			withOptsStr:    withOptsStr,
 		}
 		if err := stmt.parse(); err != nil {
 			return nil, err
@@ -180,8 +313,10 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		return stmt, stmt.validate()
 	}
 	if re := reDelete; re.MatchString(query) {
+		query, withOptsStr := splitTrailingWithOpts(c.prefixTargetTable(query, re))
 		stmt := &StmtDelete{
 			StmtExecutable: &StmtExecutable{Stmt: &Stmt{query: query, conn: c, numInput: 0}},
+			withOptsStr:    withOptsStr,
 		}
 		if err := stmt.parse(); err != nil {
 			return nil, err
@@ -189,7 +324,52 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		return stmt, stmt.validate()
 	}
 
-	return nil, fmt.Errorf("invalid query: %s", query)
+	return nil, fmt.Errorf("%w: %s", errInvalidQuery, query)
+}
+
+var (
+	reSelectFrom        = regexp.MustCompile(`(?is)\sFROM\s+`)
+	reUnquotedTableName = regexp.MustCompile(`^\w+`)
+)
+
+// prefixTargetTable prefixes the target table of a PartiQL statement, which follows the keyword(s) matched by reTarget
+// (e.g. INSERT INTO), with DSN option TablePrefix. The table name is quoted, forms "table"."index" are supported.
+//
+// @Available since v1.4.0
+func (c *Conn) prefixTargetTable(query string, reTarget *regexp.Regexp) string {
+	if c == nil || c.tablePrefix == "" {
+		return query
+	}
+	tableName, start, end := findTargetTable(query, reTarget)
+	if start < 0 {
+		return query
+	}
+	return query[:start] + `"` + c.physicalTableName(tableName) + `"` + query[end:]
+}
+
+// findTargetTable finds the target table of a PartiQL statement, which follows the keyword(s) matched by reTarget. It
+// returns the (unquoted) table name and its position [start, end) in the query, quotes included. start is -1 if the
+// table name is not found.
+//
+// @Available since v1.4.0
+func findTargetTable(query string, reTarget *regexp.Regexp) (string, int, int) {
+	loc := reTarget.FindStringIndex(maskStringLiterals(query))
+	if loc == nil {
+		return "", -1, -1
+	}
+	start, rest := loc[1], query[loc[1]:]
+	if strings.HasPrefix(rest, `"`) {
+		end := strings.IndexByte(rest[1:], '"')
+		if end < 0 {
+			return "", -1, -1
+		}
+		return rest[1 : end+1], start, start + end + 2
+	}
+	tableName := reUnquotedTableName.FindString(rest)
+	if tableName == "" {
+		return "", -1, -1
+	}
+	return tableName, start, start + len(tableName)
 }
 
 type OptStrings []string
@@ -221,6 +401,53 @@ type Stmt struct {
 	numInput int    // number of placeholder parameters
 	limit    *int32 // limit for SELECT statement
 	withOpts map[string]OptStrings
+	// 1-based index of the parameter that is marshalled as a whole item (INSERT ... VALUE ?), 0 if none (@Available since v1.4.0)
+	itemParam int
+	// if true, failed condition checks are reported as ConditionFailedError carrying the current item (@Available since v1.4.0)
+	strict bool
+	// timeout specified by clause WITH TIMEOUT, 0 if not specified (@Available since v1.4.0)
+	timeout time.Duration
+}
+
+// checkWritable returns an error wrapping ErrReadOnly if the statement's connection is read-only, see Conn.checkWritable.
+func (s *Stmt) checkWritable() error {
+	return s.conn.checkWritable(s.query)
+}
+
+func (s *Stmt) setTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// newContext derives the context of the statement's execution from the caller's ctx, bounded by clause WITH TIMEOUT or
+// the connection's timeout, see Conn.newContext.
+func (s *Stmt) newContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return s.conn.newContext(ctx, s.timeout)
+}
+
+// newLongRunningContext derives the context of a long-running statement (IMPORT, EXPORT) from the caller's ctx, bounded
+// by clause WITH TIMEOUT only: the connection's timeout (DSN option TimeoutMs) still bounds each request sent to
+// DynamoDB, but not the whole statement.
+func (s *Stmt) newLongRunningContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if s.timeout > 0 {
+		return context.WithTimeout(ctx, s.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// reTrailingWithOpts matches the "WITH..." clause at the end of a statement.
+var reTrailingWithOpts = regexp.MustCompile(`(?is)` + with + `\s*$`)
+
+// splitTrailingWithOpts splits the "WITH..." clause at the end of a statement from the statement. "WITH" inside string
+// literals is not a clause.
+func splitTrailingWithOpts(query string) (string, string) {
+	loc := reTrailingWithOpts.FindStringIndex(maskStringLiterals(query))
+	if loc == nil || strings.TrimSpace(query[loc[0]:]) == "" {
+		return query, ""
+	}
+	return query[:loc[0]], " " + strings.TrimSpace(query[loc[0]:])
 }
 
 var reWithOpts = regexp.MustCompile(`(?im)^(\s+|\s*,\s+|\s+,\s*)WITH\s+` + field + `\s*=\s*([\w/\.\*,;:'"-]+)`)
@@ -241,6 +468,23 @@ func (s *Stmt) parseWithOpts(withOptsStr string) error {
 	return nil
 }
 
+// marshalParameters marshals the parameters of the statement to AttributeValues.
+func (s *Stmt) marshalParameters(values []driver.NamedValue) ([]types.AttributeValue, error) {
+	params := make([]types.AttributeValue, len(values))
+	for i, v := range values {
+		var err error
+		if i+1 == s.itemParam {
+			params[i], err = toItemAttributeValue(v.Value)
+		} else {
+			params[i], err = ToAttributeValue(v.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling parameter %d-th: %s", i+1, err)
+		}
+	}
+	return params, nil
+}
+
 // Close implements driver.Stmt/Close.
 func (s *Stmt) Close() error {
 	return nil
@@ -276,6 +520,8 @@ type ResultResultSet struct {
 	columnList        []string
 	columnTypes       map[string]reflect.Type
 	columnSourceTypes map[string]string
+	numberMode        string // how N attributes are converted, see NumberModeFloat (@Available since v1.4.0)
+	rawItem           bool   // if true, column RawItemColumn carries the whole item (@Available since v1.4.0)
 }
 
 func (r *ResultResultSet) init() *ResultResultSet {
@@ -298,25 +544,28 @@ func (r *ResultResultSet) init() *ResultResultSet {
 		for col, av := range item {
 			colMap[col] = true
 			if r.columnTypes[col] == nil {
-				var value interface{}
-				_ = attributevalue.Unmarshal(av, &value)
+				value, _ := attributeValueToInterface(av, r.numberMode)
 				r.columnTypes[col] = reflect.TypeOf(value)
 				r.columnSourceTypes[col] = nameFromAttributeValue(av)
 			}
 		}
 	}
 
-	if len(r.columnList) > 0 {
-		// #146: if column list was provided in the SELECT statement, keep the order as specified
-		return r
+	// #146: if column list was provided in the SELECT statement, keep the order as specified
+	if len(r.columnList) == 0 {
+		// save column names, sorted
+		r.columnList = make([]string, 0, len(colMap))
+		for col := range colMap {
+			r.columnList = append(r.columnList, col)
+		}
+		sort.Strings(r.columnList)
 	}
 
-	// save column names, sorted
-	r.columnList = make([]string, 0, len(colMap))
-	for col := range colMap {
-		r.columnList = append(r.columnList, col)
+	if r.rawItem {
+		r.columnList = append(r.columnList, RawItemColumn)
+		r.columnTypes[RawItemColumn] = rawItemType
+		r.columnSourceTypes[RawItemColumn] = "M"
 	}
-	sort.Strings(r.columnList)
 
 	return r
 }
@@ -327,6 +576,8 @@ func (r *ResultResultSet) Columns() []string {
 }
 
 // ColumnTypeScanType implements driver.RowsColumnTypeScanType/ColumnTypeScanType
+//
+// @since v1.4.0 the scan type of N columns depends on the DSN option NumberMode: float64 (default), json.Number, string or *BigNumber.
 func (r *ResultResultSet) ColumnTypeScanType(index int) reflect.Type {
 	return r.columnTypes[r.columnList[index]]
 }
@@ -354,9 +605,17 @@ func (r *ResultResultSet) Next(dest []driver.Value) error {
 	rowData := r.stmtOutput.Items[r.cursorCount]
 	r.cursorCount++
 	for i, colName := range r.columnList {
-		var value interface{}
-		_ = attributevalue.Unmarshal(rowData[colName], &value)
-		dest[i] = value
+		if r.rawItem && colName == RawItemColumn {
+			dest[i] = rowData
+		} else if av, ok := rowData[colName]; ok {
+			value, err := attributeValueToInterface(av, r.numberMode)
+			if err != nil {
+				return fmt.Errorf("error converting value of column <%s>: %s", colName, err)
+			}
+			dest[i] = value
+		} else {
+			dest[i] = nil
+		}
 	}
 	return nil
 }
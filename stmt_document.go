@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 var (
@@ -17,6 +18,7 @@ var (
 	reReturning    = regexp.MustCompile(`(?im)\s+RETURNING\s+((ALL\s+OLD)|(MODIFIED\s+OLD)|(ALL\s+NEW)|(MODIFIED\s+NEW))\s+\*\s*$`)
 	reLimit        = regexp.MustCompile(`(?im)\s+LIMIT\s+(\S+)\s*`)
 	reSelectedList = regexp.MustCompile(`(?im)SELECT\s+(.*)\s+FROM\s+`)
+	reValueParam   = regexp.MustCompile(`(?is)\s+VALUE\s+\?\s*$`)
 )
 
 /*----------------------------------------------------------------------*/
@@ -24,6 +26,11 @@ var (
 // StmtExecutable is the base implementation for INSERT, SELECT, UPDATE and DELETE statements.
 type StmtExecutable struct {
 	*Stmt
+	// SELECT statement fetching the old image of an UPDATE/DELETE statement inside a transaction, the number of
+	// placeholders before its WHERE clause, and the position right after its WHERE keyword (@Available since v1.4.0)
+	oldImageQuery      string
+	oldImageSkipParams int
+	oldImageWhereEnd   int
 }
 
 var (
@@ -43,38 +50,229 @@ func (s *StmtExecutable) validate() error {
 	return nil
 }
 
+// splitReturning splits the RETURNING clause at the end of a statement from the statement. The returned clause is
+// normalized (e.g. "ALL OLD"), empty if the statement has no RETURNING clause.
+func splitReturning(query string) (string, string) {
+	loc := reReturning.FindStringSubmatchIndex(query)
+	if loc == nil {
+		return query, ""
+	}
+	return query[:loc[0]], strings.ToUpper(strings.Join(strings.Fields(query[loc[2]:loc[3]]), " "))
+}
+
+// parseTxReturning prepares an UPDATE/DELETE statement (matched by reStmt) to be executed inside a transaction, where
+// DynamoDB does not support RETURNING clauses: the clause is removed from the statement, and a SELECT statement is built
+// to fetch the old image of the item right before the transaction is executed.
+func (s *StmtExecutable) parseTxReturning(reStmt *regexp.Regexp) error {
+	query, returning := splitReturning(s.query)
+	if returning != "" && returning != "ALL OLD" {
+		return fmt.Errorf("RETURNING %s * is not supported inside a transaction, only RETURNING ALL OLD * is", returning)
+	}
+	s.query = query
+	masked := maskStringLiterals(query)
+	whereLocs := reWhereKeyword.FindAllStringIndex(masked, -1)
+	stmtLoc := reStmt.FindStringIndex(query)
+	if len(whereLocs) == 0 || stmtLoc == nil || len(strings.Fields(query[stmtLoc[1]:])) == 0 {
+		return nil
+	}
+	whereLoc := whereLocs[len(whereLocs)-1]
+	s.oldImageQuery = fmt.Sprintf("SELECT * FROM %s WHERE %s", strings.Fields(query[stmtLoc[1]:])[0], strings.TrimSpace(query[whereLoc[1]:]))
+	s.oldImageSkipParams = len(rePlaceholder.FindAllString(masked[:whereLoc[0]], -1))
+	s.oldImageWhereEnd = whereLoc[1]
+	return nil
+}
+
+// executeInTxReturningOld adds an UPDATE/DELETE statement to the ongoing transaction, along with the SELECT statement
+// fetching the old image of the item with a strongly consistent read right before the transaction is executed. The
+// statement is then executed only if the item still matches its old image, see conditionOnOldImage.
+func (s *StmtExecutable) executeInTxReturningOld(ctx context.Context, values []driver.NamedValue) (executeStatementOutputWrapper, error) {
+	if s.oldImageQuery == "" {
+		s.conn.txReturning = nil
+		return nil, errors.New("statement must have a WHERE clause to return the old image inside a transaction")
+	}
+	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
+	if !errors.Is(err, ErrInTx) {
+		return nil, err
+	}
+	txStmt := s.conn.lastTxStmt()
+	txStmt.oldImageStmt = &Stmt{query: s.oldImageQuery, conn: s.conn}
+	txStmt.oldImageWhereEnd = s.oldImageWhereEnd
+	for i := s.oldImageSkipParams; i < len(values); i++ {
+		txStmt.oldImageValues = append(txStmt.oldImageValues, driver.NamedValue{Ordinal: len(txStmt.oldImageValues) + 1, Value: values[i].Value})
+	}
+	return outputFn, nil
+}
+
+// parseStrict sets the strict mode of the statement from the DSN option Strict, overridden by clause WITH STRICT.
+func (s *StmtExecutable) parseStrict() {
+	s.strict = s.conn.strict
+	if strict, ok := s.withOpts["STRICT"]; ok {
+		s.strict = strict.FirstBool()
+	}
+}
+
+// conditionalCheckError returns the error to be reported when the condition check of an UPDATE/DELETE statement fails:
+// nil (0 affected row) by default, or a ConditionFailedError in strict mode.
+func (s *StmtExecutable) conditionalCheckError(err error) error {
+	if !s.strict {
+		return nil
+	}
+	return newConditionFailedError(err, err)
+}
+
+// ErrConditionFailed is matched (via errors.Is) by errors returned when the condition check of an UPDATE/DELETE
+// statement fails in strict mode, see ConditionFailedError.
+//
+// @Available since v1.4.0
+var ErrConditionFailed = errors.New("condition check failed")
+
+// ConditionFailedError is returned when the condition check of an UPDATE/DELETE statement fails in strict mode (DSN
+// option Strict=true or clause WITH STRICT=true). It carries the current item, so that callers can resolve conflicts
+// without a second read.
+//
+// errors.Is(err, ErrConditionFailed) reports true for a ConditionFailedError.
+//
+// @Available since v1.4.0
+type ConditionFailedError struct {
+	Item map[string]types.AttributeValue // the current item, nil if the item does not exist
+	Err  error                           // the underlying error
+}
+
+func newConditionFailedError(awsErr, err error) *ConditionFailedError {
+	result := &ConditionFailedError{Err: err}
+	var ccfErr *types.ConditionalCheckFailedException
+	if errors.As(awsErr, &ccfErr) {
+		result.Item = ccfErr.Item
+	}
+	return result
+}
+
+// Error implements error/Error.
+func (e *ConditionFailedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrConditionFailed, e.Err)
+}
+
+// Is reports true if target is ErrConditionFailed.
+func (e *ConditionFailedError) Is(target error) bool {
+	return target == ErrConditionFailed
+}
+
+// Unwrap returns the underlying error.
+func (e *ConditionFailedError) Unwrap() error {
+	return e.Err
+}
+
 /*----------------------------------------------------------------------*/
 
 // StmtInsert implements "INSERT" statement.
 //
 // Syntax: follow "PartiQL insert statements for DynamoDB" https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ql-reference.insert.html
+//
+// @Since v1.4.0 support "INSERT INTO <table> VALUE ?" with a struct or map argument: the argument is marshalled as the
+// whole item via attributevalue.MarshalMap, honoring `dynamodbav` struct tags (including omitempty).
+//
+// @Since v1.4.0 support RETURNING clause with Query, emulated by godynamo since PartiQL INSERT does not support it:
+// "RETURNING ALL NEW *" and "RETURNING MODIFIED NEW *" return the inserted item; "RETURNING ALL OLD *" and
+// "RETURNING MODIFIED OLD *" return no row, as INSERT fails if the item already exists.
 type StmtInsert struct {
 	*StmtExecutable
+	returning string // the RETURNING clause, removed from the statement (@Available since v1.4.0)
+}
+
+func (s *StmtInsert) parse() error {
+	s.query, s.returning = splitReturning(s.query)
+	if err := s.StmtExecutable.parse(); err != nil {
+		return err
+	}
+	if reValueParam.MatchString(s.query) {
+		s.itemParam = s.numInput
+	}
+	return nil
+}
+
+// returnedItems returns the rows of the statement's RETURNING clause: the inserted item for ALL NEW and MODIFIED NEW,
+// none for ALL OLD and MODIFIED OLD.
+func (s *StmtInsert) returnedItems(values []driver.NamedValue) ([]map[string]types.AttributeValue, error) {
+	if strings.HasSuffix(s.returning, "OLD") {
+		return []map[string]types.AttributeValue{}, nil
+	}
+	params, err := s.marshalParameters(values)
+	if err != nil {
+		return nil, err
+	}
+	if s.itemParam > 0 {
+		return []map[string]types.AttributeValue{params[s.itemParam-1].(*types.AttributeValueMemberM).Value}, nil
+	}
+	item, err := evalPartiqlInsertItem(s.query, params)
+	if err != nil {
+		return nil, fmt.Errorf("cannot evaluate the inserted item: %s", err)
+	}
+	return []map[string]types.AttributeValue{item}, nil
 }
 
 // Query implements driver.Stmt/Query.
-// This function is not implemented, use Exec instead.
-func (s *StmtInsert) Query(_ []driver.Value) (driver.Rows, error) {
-	return nil, errors.New("this operation is not supported, please use Exec")
+//
+// Note: since v1.4.0, this function is supported if the statement has a RETURNING clause.
+func (s *StmtInsert) Query(values []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
-// This function is not implemented, use ExecContext instead.
-func (s *StmtInsert) QueryContext(_ context.Context, _ []driver.NamedValue) (driver.Rows, error) {
-	return nil, errors.New("this operation is not supported, please use ExecContext")
+//
+// Note: since v1.4.0, this function is supported if the statement has a RETURNING clause. Inside a transaction, the
+// returned rows are available once the transaction is committed, see TxResultResultSet and TxReturning.
+func (s *StmtInsert) QueryContext(ctx context.Context, values []driver.NamedValue) (driver.Rows, error) {
+	if s.returning == "" {
+		return nil, errors.New("this operation is not supported without RETURNING clause, please use ExecContext")
+	}
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
+	items, err := s.returnedItems(values)
+	if err != nil {
+		return nil, err
+	}
+	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
+	if errors.Is(err, ErrInTx) {
+		s.conn.lastTxStmt().returnItems = items
+		return &TxResultResultSet{outputFn: outputFn, numberMode: s.conn.numberMode}, nil
+	}
+	if err != nil {
+		return (&ResultResultSet{err: err}).init(), err
+	}
+	output := outputFn()
+	output.Items = items
+	return (&ResultResultSet{stmtOutput: output, numberMode: s.conn.numberMode}).init(), nil
 }
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtInsert) Exec(values []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.ExecContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
 //
 // @Available since v0.2.0
+//
+// @Since v1.4.0 inside a transaction, the rows of the RETURNING clause can be received via sql.Out, see TxReturning.
 func (s *StmtInsert) ExecContext(ctx context.Context, values []driver.NamedValue) (driver.Result, error) {
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
+	var items []map[string]types.AttributeValue
+	if s.conn.txReturning != nil {
+		var err error
+		if s.returning == "" {
+			err = errors.New("statement must have a RETURNING clause to return rows via sql.Out")
+		} else {
+			items, err = s.returnedItems(values)
+		}
+		if err != nil {
+			s.conn.txReturning = nil
+			return nil, err
+		}
+	}
 	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
 	if errors.Is(err, ErrInTx) {
+		s.conn.lastTxStmt().returnItems = items
 		return &TxResultNoResultSet{outputFn: outputFn}, nil
 	}
 	affectedRows := int64(0)
@@ -93,9 +291,23 @@ func (s *StmtInsert) ExecContext(ctx context.Context, values []driver.NamedValue
 // @Since v0.3.0 support LIMIT clause
 //
 // @Since v0.4.0 support WITH consistency=strong clause
+//
+// @Since v1.4.0 support WITH PARALLEL=<n> clause: the statement is executed as a parallel scan of n segments using the
+// low-level Scan API. The WHERE clause is translated to a filter expression; the statement is rejected if it cannot be
+// translated (e.g. ORDER BY clause or unsupported functions).
+//
+// @Since v1.4.0 support WITH ALLOW_SCAN=true clause, which allows the statement to run as a full scan when the DSN
+// option AllowScan=false is set.
+//
+// @Since v1.4.0 support WITH RAW_ITEM=true clause: column RawItemColumn ("*") carrying the whole item as
+// map[string]types.AttributeValue is appended to the result set, see ScanStruct.
 type StmtSelect struct {
 	*StmtExecutable
-	withOptsStr string
+	withOptsStr    string
+	parallel       int            // number of segments of parallel scan, 0 if not specified (@Available since v1.4.0)
+	selectQuery    *partiqlSelect // the analyzed statement, nil if the statement cannot be analyzed (@Available since v1.4.0)
+	selectQueryErr error          // the reason why the statement cannot be analyzed (@Available since v1.4.0)
+	rawItem        bool           // if true, column RawItemColumn is appended to the result set (@Available since v1.4.0)
 }
 
 func (s *StmtSelect) parse() error {
@@ -117,6 +329,22 @@ func (s *StmtSelect) parse() error {
 		// Remove LIMIT keyword and value from query
 		s.query = strings.TrimSpace(reLimit.ReplaceAllString(s.query, ""))
 	}
+
+	s.rawItem = s.withOpts["RAW_ITEM"].FirstBool() || s.withOpts["RAWITEM"].FirstBool()
+	s.selectQuery, s.selectQueryErr = parsePartiqlSelect(s.query)
+	if parallel, ok := s.withOpts["PARALLEL"]; ok {
+		n, err := strconv.Atoi(parallel.FirstString())
+		if err != nil || n <= 0 || n > maxTotalSegments {
+			return fmt.Errorf("invalid PARALLEL value: %s", parallel.FirstString())
+		}
+		s.parallel = n
+		if s.selectQueryErr != nil {
+			return fmt.Errorf("cannot execute SELECT as parallel scan: %w", s.selectQueryErr)
+		}
+		if _, err = buildScanInput(s.selectQuery, make([]types.AttributeValue, s.selectQuery.numParams)); err != nil {
+			return fmt.Errorf("cannot execute SELECT as parallel scan: %s", err)
+		}
+	}
 	return s.StmtExecutable.parse()
 }
 
@@ -134,13 +362,27 @@ func (s *StmtSelect) ExecContext(_ context.Context, _ []driver.NamedValue) (driv
 
 // Query implements driver.Stmt/Query.
 func (s *StmtSelect) Query(values []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.QueryContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
 //
 // @Available since v0.2.0
 func (s *StmtSelect) QueryContext(ctx context.Context, values []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
+	if err := s.conn.checkFullScan(ctx, s); err != nil {
+		return (&ResultResultSet{err: err}).init(), err
+	}
+	if s.parallel > 0 {
+		output, err := s.conn.executeParallelScanContext(ctx, s, values)
+		result := (&ResultResultSet{
+			stmtOutput: output,
+			columnList: extractSelectedColumnList(s.query),
+			numberMode: s.conn.numberMode,
+			rawItem:    s.rawItem}).init()
+		return result, err
+	}
 	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
 	// TODO Query is not supported yet in tx mode
 	// if err == ErrInTx {
@@ -148,7 +390,9 @@ func (s *StmtSelect) QueryContext(ctx context.Context, values []driver.NamedValu
 	// }
 	result := (&ResultResultSet{
 		stmtOutput: outputFn(),
-		columnList: extractSelectedColumnList(s.query)}).init()
+		columnList: extractSelectedColumnList(s.query),
+		numberMode: s.conn.numberMode,
+		rawItem:    s.rawItem}).init()
 	return result, err
 }
 
@@ -182,50 +426,164 @@ func extractSelectedColumnList(query string) []string {
 
 /*----------------------------------------------------------------------*/
 
+// ErrVersionConflict is returned when an UPDATE statement with clause WITH VERSION=<attr> fails because the item does
+// not exist or its version attribute does not match the expected value.
+//
+// @Available since v1.4.0
+var ErrVersionConflict = errors.New("version conflict")
+
+var (
+	reWhereKeyword = regexp.MustCompile(`(?i)\sWHERE\s`)
+	reField        = regexp.MustCompile(`^` + field + `$`)
+)
+
+// maskStringLiterals replaces string literals and quoted identifiers in the query with underscores, keeping the
+// positions of the remaining characters unchanged.
+func maskStringLiterals(query string) string {
+	mask := func(s string) string { return strings.Repeat("_", len(s)) }
+	return reStringLiteralDouble.ReplaceAllStringFunc(reStringLiteralSingle.ReplaceAllStringFunc(query, mask), mask)
+}
+
+// rewriteVersionedUpdate rewrites an UPDATE statement to increment the version attribute attr and to update the item
+// only if attr equals the value of an extra placeholder appended to the WHERE clause.
+func rewriteVersionedUpdate(query, attr string) (string, error) {
+	masked := maskStringLiterals(query)
+	whereLocs := reWhereKeyword.FindAllStringIndex(masked, -1)
+	if len(whereLocs) == 0 {
+		return "", errors.New("UPDATE statement with clause WITH VERSION must have a WHERE clause")
+	}
+	whereLoc := whereLocs[len(whereLocs)-1]
+	end := len(query)
+	if loc := reReturning.FindStringIndex(masked); loc != nil {
+		end = loc[0]
+	}
+	cond := strings.TrimSpace(query[whereLoc[1]:end])
+	quoted := `"` + attr + `"`
+	return fmt.Sprintf("%s SET %s=%s+1 WHERE (%s) AND %s=?%s", strings.TrimSpace(query[:whereLoc[0]]), quoted, quoted, cond, quoted, query[end:]), nil
+}
+
 // StmtUpdate implements "UPDATE" statement.
 //
 // Syntax: follow "PartiQL update statements for DynamoDB" https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ql-reference.update.html
 //
 // Note: StmtUpdate returns the updated item by appending "RETURNING ALL OLD *" to the statement.
+//
+// @Since v1.4.0 support WITH VERSION=<attr> clause for optimistic locking: the statement is rewritten to increment the
+// attribute attr and to add condition "AND attr=?" to the WHERE clause; the expected version is supplied as the last
+// argument. If the item does not exist or its version does not match, ErrVersionConflict is returned instead of
+// 0 affected row. Inside a transaction, a version mismatch makes the transaction fail to commit with ErrVersionConflict.
+//
+// @Since v1.4.0 support WITH STRICT=true clause (or DSN option Strict=true): if the item does not exist or the condition
+// is false, a ConditionFailedError carrying the current item is returned instead of 0 affected row.
+//
+// @Since v1.4.0 support returning the old image of the item inside a transaction ("RETURNING ALL OLD *", the only
+// supported clause), received via sql.Out once the transaction is committed (see TxReturning). DynamoDB does not
+// support RETURNING clauses in transactions, so the old image is fetched with a strongly consistent read right before
+// the transaction is executed, and the statement is executed only if the item still matches it.
 type StmtUpdate struct {
 	*StmtExecutable
+	withOptsStr string
+	versionAttr string // the version attribute specified by clause WITH VERSION, empty if not specified (@Available since v1.4.0)
 }
 
 func (s *StmtUpdate) parse() error {
-	if !reReturning.MatchString(s.query) && s.conn.txMode == txNone {
+	if err := s.parseWithOpts(s.withOptsStr); err != nil {
+		return err
+	}
+	if version, ok := s.withOpts["VERSION"]; ok {
+		s.versionAttr = strings.Trim(version.FirstString(), `"'`)
+		if !reField.MatchString(s.versionAttr) {
+			return fmt.Errorf("invalid VERSION attribute: %s", version.FirstString())
+		}
+		query, err := rewriteVersionedUpdate(s.query, s.versionAttr)
+		if err != nil {
+			return err
+		}
+		s.query = query
+	}
+	s.parseStrict()
+	if s.versionAttr != "" {
+		s.strict = true
+	}
+	if s.conn.txMode != txNone {
+		if err := s.parseTxReturning(reUpdate); err != nil {
+			return err
+		}
+	} else if !reReturning.MatchString(s.query) {
 		s.query += " RETURNING ALL OLD *"
 	}
 	return s.StmtExecutable.parse()
 }
 
+// conditionalCheckError returns the error to be reported when the statement's condition check fails: nil (0 affected
+// row) by default, a ConditionFailedError in strict mode, or a ConditionFailedError wrapping ErrVersionConflict if
+// clause WITH VERSION is specified.
+func (s *StmtUpdate) conditionalCheckError(err error) error {
+	if s.versionAttr != "" {
+		return newConditionFailedError(err, versionConflictError(s.versionAttr))
+	}
+	return s.StmtExecutable.conditionalCheckError(err)
+}
+
+// versionConflictError returns the error wrapping ErrVersionConflict reported when the version check of an UPDATE
+// statement with clause WITH VERSION fails.
+func versionConflictError(versionAttr string) error {
+	return fmt.Errorf("%w: item does not exist or its attribute <%s> does not match the expected value", ErrVersionConflict, versionAttr)
+}
+
 // Query implements driver.Stmt/Query.
 func (s *StmtUpdate) Query(values []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.QueryContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
 //
+// Note: since v1.4.0, this function is supported inside a transaction, see StmtUpdate.
+//
 // @Available since v0.2.0
 func (s *StmtUpdate) QueryContext(ctx context.Context, values []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
+	if s.conn.txMode == txStarted {
+		outputFn, err := s.executeInTxReturningOld(ctx, values)
+		if err != nil {
+			return nil, err
+		}
+		s.conn.lastTxStmt().versionAttr = s.versionAttr
+		return &TxResultResultSet{outputFn: outputFn, numberMode: s.conn.numberMode}, nil
+	}
 	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
-	result := (&ResultResultSet{stmtOutput: outputFn()}).init()
-	if err == nil || IsAwsError(err, "ConditionalCheckFailedException") {
-		err = nil
+	if IsAwsError(err, "ConditionalCheckFailedException") {
+		err = s.conditionalCheckError(err)
 	}
+	result := (&ResultResultSet{stmtOutput: outputFn(), numberMode: s.conn.numberMode}).init()
 	return result, err
 }
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtUpdate) Exec(values []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.ExecContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
 //
 // @Available since v0.2.0
+//
+// @Since v1.4.0 inside a transaction, the old image of the item can be received via sql.Out, see TxReturning.
 func (s *StmtUpdate) ExecContext(ctx context.Context, values []driver.NamedValue) (driver.Result, error) {
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
+	if s.conn.txMode == txStarted && s.conn.txReturning != nil {
+		outputFn, err := s.executeInTxReturningOld(ctx, values)
+		if err != nil {
+			return nil, err
+		}
+		s.conn.lastTxStmt().versionAttr = s.versionAttr
+		return &TxResultNoResultSet{outputFn: outputFn}, nil
+	}
 	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
 	if errors.Is(err, ErrInTx) {
+		s.conn.lastTxStmt().versionAttr = s.versionAttr
 		return &TxResultNoResultSet{outputFn: outputFn}, nil
 	}
 	affectedRows := int64(0)
@@ -233,7 +591,7 @@ func (s *StmtUpdate) ExecContext(ctx context.Context, values []driver.NamedValue
 		affectedRows = int64(len(outputFn().Items))
 	}
 	if IsAwsError(err, "ConditionalCheckFailedException") {
-		err = nil
+		err = s.conditionalCheckError(err)
 	}
 	return &ResultNoResultSet{err: err, affectedRows: affectedRows}, err
 }
@@ -245,12 +603,26 @@ func (s *StmtUpdate) ExecContext(ctx context.Context, values []driver.NamedValue
 // Syntax: follow "PartiQL delete statements for DynamoDB" https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ql-reference.delete.html
 //
 // Note: StmtDelete returns the deleted item by appending "RETURNING ALL OLD *" to the statement.
+//
+// @Since v1.4.0 support WITH STRICT=true clause (or DSN option Strict=true): if the item does not exist or the condition
+// is false, a ConditionFailedError carrying the current item is returned instead of 0 affected row.
+//
+// @Since v1.4.0 support returning the old image of the item inside a transaction, see StmtUpdate.
 type StmtDelete struct {
 	*StmtExecutable
+	withOptsStr string
 }
 
 func (s *StmtDelete) parse() error {
-	if !reReturning.MatchString(s.query) && s.conn.txMode == txNone {
+	if err := s.parseWithOpts(s.withOptsStr); err != nil {
+		return err
+	}
+	s.parseStrict()
+	if s.conn.txMode != txNone {
+		if err := s.parseTxReturning(reDelete); err != nil {
+			return err
+		}
+	} else if !reReturning.MatchString(s.query) {
 		s.query += " RETURNING ALL OLD *"
 	}
 	return s.StmtExecutable.parse()
@@ -258,30 +630,52 @@ func (s *StmtDelete) parse() error {
 
 // Query implements driver.Stmt/Query.
 func (s *StmtDelete) Query(values []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.QueryContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
 //
+// Note: since v1.4.0, this function is supported inside a transaction, see StmtDelete.
+//
 // @Available since v0.2.0
 func (s *StmtDelete) QueryContext(ctx context.Context, values []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
+	if s.conn.txMode == txStarted {
+		outputFn, err := s.executeInTxReturningOld(ctx, values)
+		if err != nil {
+			return nil, err
+		}
+		return &TxResultResultSet{outputFn: outputFn, numberMode: s.conn.numberMode}, nil
+	}
 	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
-	result := (&ResultResultSet{stmtOutput: outputFn()}).init()
-	if err == nil || IsAwsError(err, "ConditionalCheckFailedException") {
-		err = nil
+	if IsAwsError(err, "ConditionalCheckFailedException") {
+		err = s.conditionalCheckError(err)
 	}
+	result := (&ResultResultSet{stmtOutput: outputFn(), numberMode: s.conn.numberMode}).init()
 	return result, err
 }
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtDelete) Exec(values []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.ExecContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
 //
 // @Available since v0.2.0
+//
+// @Since v1.4.0 inside a transaction, the old image of the item can be received via sql.Out, see TxReturning.
 func (s *StmtDelete) ExecContext(ctx context.Context, values []driver.NamedValue) (driver.Result, error) {
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
+	if s.conn.txMode == txStarted && s.conn.txReturning != nil {
+		outputFn, err := s.executeInTxReturningOld(ctx, values)
+		if err != nil {
+			return nil, err
+		}
+		return &TxResultNoResultSet{outputFn: outputFn}, nil
+	}
 	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
 	if errors.Is(err, ErrInTx) {
 		return &TxResultNoResultSet{outputFn: outputFn}, nil
@@ -291,7 +685,7 @@ func (s *StmtDelete) ExecContext(ctx context.Context, values []driver.NamedValue
 		affectedRows = int64(len(outputFn().Items))
 	}
 	if IsAwsError(err, "ConditionalCheckFailedException") {
-		err = nil
+		err = s.conditionalCheckError(err)
 	}
 	return &ResultNoResultSet{err: err, affectedRows: affectedRows}, err
 }
@@ -1,11 +1,22 @@
 package godynamo
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 func Test_Stmt_Select_parse(t *testing.T) {
@@ -144,3 +155,571 @@ func Test_Stmt_Select_parse_withopts(t *testing.T) {
 		})
 	}
 }
+
+func Test_Stmt_Insert_parse_itemParam(t *testing.T) {
+	testName := "Test_Stmt_Insert_parse_itemParam"
+	testData := []struct {
+		name      string
+		sql       string
+		numInput  int
+		itemParam int
+	}{
+		{name: "value_param", sql: `INSERT INTO "table" VALUE ?`, numInput: 1, itemParam: 1},
+		{name: "value_param_new_line", sql: `INSERT INTO "table"
+VALUE  ?  `, numInput: 1, itemParam: 1},
+		{name: "value_document", sql: `INSERT INTO "table" VALUE {'id': ?, 'name': ?}`, numInput: 2},
+		{name: "value_document_string_literal", sql: `INSERT INTO "table" VALUE {'id': 'VALUE ?'}`},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(nil, testCase.sql)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			stmt, ok := s.(*StmtInsert)
+			if !ok {
+				t.Fatalf("%s failed: expected StmtInsert but received %T", testName+"/"+testCase.name, s)
+			}
+			if stmt.numInput != testCase.numInput || stmt.itemParam != testCase.itemParam {
+				t.Fatalf("%s failed: expected %#v/%#v but received %#v/%#v", testName+"/"+testCase.name, testCase.numInput, testCase.itemParam, stmt.numInput, stmt.itemParam)
+			}
+		})
+	}
+}
+
+func Test_Stmt_marshalParameters_itemParam(t *testing.T) {
+	testName := "Test_Stmt_marshalParameters_itemParam"
+	type session struct {
+		App      string   `dynamodbav:"app"`
+		User     string   `dynamodbav:"user"`
+		Os       string   `dynamodbav:"os,omitempty"`
+		Duration float64  `dynamodbav:"duration"`
+		Tags     []string `dynamodbav:"tags,stringset,omitempty"`
+		Ignored  string   `dynamodbav:"-"`
+	}
+	expected := &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+		"app":      &types.AttributeValueMemberS{Value: "app0"},
+		"user":     &types.AttributeValueMemberS{Value: "user0"},
+		"duration": &types.AttributeValueMemberN{Value: "1.5"},
+	}}
+	testData := []struct {
+		name      string
+		value     interface{}
+		expected  types.AttributeValue
+		mustError bool
+	}{
+		{name: "struct", value: session{App: "app0", User: "user0", Duration: 1.5, Ignored: "x"}, expected: expected},
+		{name: "struct_pointer", value: &session{App: "app0", User: "user0", Duration: 1.5}, expected: expected},
+		{name: "map", value: map[string]interface{}{"app": "app0", "user": "user0", "duration": 1.5}, expected: expected},
+		{name: "attribute_value_map", value: expected.Value, expected: expected},
+
+		{name: "string", value: `{"app": "app0"}`, mustError: true},
+		{name: "slice", value: []interface{}{"app0"}, mustError: true},
+		{name: "map_int_keys", value: map[int]string{1: "app0"}, mustError: true},
+	}
+	stmt, err := parseQuery(nil, `INSERT INTO "table" VALUE ?`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			params, err := stmt.(*StmtInsert).marshalParameters([]driver.NamedValue{{Ordinal: 1, Value: testCase.value}})
+			if testCase.mustError && err == nil {
+				t.Fatalf("%s failed: marshalling must fail", testName+"/"+testCase.name)
+			}
+			if testCase.mustError {
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if !reflect.DeepEqual(params[0], testCase.expected) {
+				t.Fatalf("%s failed: expected %#v but received %#v", testName+"/"+testCase.name, testCase.expected, params[0])
+			}
+		})
+	}
+}
+
+func Test_Stmt_Update_parse_version(t *testing.T) {
+	testName := "Test_Stmt_Update_parse_version"
+	testData := []struct {
+		name      string
+		sql       string
+		afterSql  string
+		numInput  int
+		version   string
+		mustError bool
+	}{
+		{name: "no_version", sql: `UPDATE "tbl" SET a=? WHERE id=?`, afterSql: `UPDATE "tbl" SET a=? WHERE id=? RETURNING ALL OLD *`, numInput: 2},
+		{name: "version", sql: `UPDATE "tbl" SET a=? WHERE id=? WITH VERSION=ver`, version: "ver", numInput: 3,
+			afterSql: `UPDATE "tbl" SET a=? SET "ver"="ver"+1 WHERE (id=?) AND "ver"=? RETURNING ALL OLD *`},
+		{name: "version_quoted_or", sql: `UPDATE "tbl" SET a='x WHERE y' WHERE id=? OR "where"=1 WITH version="v"`, version: "v", numInput: 2,
+			afterSql: `UPDATE "tbl" SET a='x WHERE y' SET "v"="v"+1 WHERE (id=? OR "where"=1) AND "v"=? RETURNING ALL OLD *`},
+		{name: "version_returning", sql: `UPDATE "tbl" SET a=? WHERE id=? RETURNING ALL NEW * WITH VERSION=ver`, version: "ver", numInput: 3,
+			afterSql: `UPDATE "tbl" SET a=? SET "ver"="ver"+1 WHERE (id=?) AND "ver"=? RETURNING ALL NEW *`},
+
+		{name: "no_where", sql: `UPDATE "tbl" SET a=? WITH VERSION=ver`, mustError: true},
+		{name: "invalid_attr", sql: `UPDATE "tbl" SET a=? WHERE id=? WITH VERSION=a.b`, mustError: true},
+	}
+	conn := &Conn{}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(conn, testCase.sql)
+			if testCase.mustError && err == nil {
+				t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+			}
+			if testCase.mustError {
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			stmt, ok := s.(*StmtUpdate)
+			if !ok {
+				t.Fatalf("%s failed: expected StmtUpdate but received %T", testName+"/"+testCase.name, s)
+			}
+			if stmt.query != testCase.afterSql || stmt.numInput != testCase.numInput || stmt.versionAttr != testCase.version {
+				t.Fatalf("%s failed: unexpected parsing result %#v/%#v/%#v", testName+"/"+testCase.name, stmt.query, stmt.numInput, stmt.versionAttr)
+			}
+		})
+	}
+}
+
+func Test_Stmt_UpdateDelete_parse_strict(t *testing.T) {
+	testName := "Test_Stmt_UpdateDelete_parse_strict"
+	testData := []struct {
+		name       string
+		sql        string
+		connStrict bool
+		strict     bool
+		afterSql   string
+	}{
+		{name: "update", sql: `UPDATE "tbl" SET a=? WHERE id=?`, afterSql: `UPDATE "tbl" SET a=? WHERE id=? RETURNING ALL OLD *`},
+		{name: "update_strict", sql: `UPDATE "tbl" SET a=? WHERE id=? WITH STRICT=true`, strict: true, afterSql: `UPDATE "tbl" SET a=? WHERE id=? RETURNING ALL OLD *`},
+		{name: "update_conn_strict", sql: `UPDATE "tbl" SET a=? WHERE id=?`, connStrict: true, strict: true, afterSql: `UPDATE "tbl" SET a=? WHERE id=? RETURNING ALL OLD *`},
+		{name: "update_not_strict", sql: `UPDATE "tbl" SET a=? WHERE id=? WITH STRICT=false`, connStrict: true, afterSql: `UPDATE "tbl" SET a=? WHERE id=? RETURNING ALL OLD *`},
+		{name: "update_version", sql: `UPDATE "tbl" SET a=? WHERE id=? WITH STRICT=false WITH VERSION=v`, strict: true,
+			afterSql: `UPDATE "tbl" SET a=? SET "v"="v"+1 WHERE (id=?) AND "v"=? RETURNING ALL OLD *`},
+		{name: "delete", sql: `DELETE FROM "tbl" WHERE id=?`, afterSql: `DELETE FROM "tbl" WHERE id=? RETURNING ALL OLD *`},
+		{name: "delete_strict", sql: `DELETE FROM "tbl" WHERE id=? AND a=? WITH strict=true`, strict: true, afterSql: `DELETE FROM "tbl" WHERE id=? AND a=? RETURNING ALL OLD *`},
+		{name: "delete_conn_strict", sql: `DELETE FROM "tbl" WHERE id=?`, connStrict: true, strict: true, afterSql: `DELETE FROM "tbl" WHERE id=? RETURNING ALL OLD *`},
+		{name: "delete_with_in_literal", sql: `DELETE FROM "t" WHERE note='x WITH a=b'`, afterSql: `DELETE FROM "t" WHERE note='x WITH a=b' RETURNING ALL OLD *`},
+		{name: "update_with_in_literal", sql: `UPDATE "t" SET a=? WHERE note='x WITH strict=false' WITH strict=true`, strict: true,
+			afterSql: `UPDATE "t" SET a=? WHERE note='x WITH strict=false' RETURNING ALL OLD *`},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			conn := &Conn{strict: testCase.connStrict}
+			s, err := parseQuery(conn, testCase.sql)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			var stmt *Stmt
+			switch v := s.(type) {
+			case *StmtUpdate:
+				stmt = v.Stmt
+			case *StmtDelete:
+				stmt = v.Stmt
+			default:
+				t.Fatalf("%s failed: unexpected statement type %T", testName+"/"+testCase.name, s)
+			}
+			if stmt.strict != testCase.strict || stmt.query != testCase.afterSql {
+				t.Fatalf("%s failed: unexpected parsing result %#v/%#v", testName+"/"+testCase.name, stmt.strict, stmt.query)
+			}
+			input, _ := buildExecuteStatementInput(stmt, nil)
+			if expected := testCase.strict; (input.ReturnValuesOnConditionCheckFailure == types.ReturnValuesOnConditionCheckFailureAllOld) != expected {
+				t.Fatalf("%s failed: unexpected ReturnValuesOnConditionCheckFailure %#v", testName+"/"+testCase.name, input.ReturnValuesOnConditionCheckFailure)
+			}
+		})
+	}
+}
+
+func Test_ConditionFailedError(t *testing.T) {
+	testName := "Test_ConditionFailedError"
+	item := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "1"}}
+	awsErr := fmt.Errorf("operation error: %w", &types.ConditionalCheckFailedException{Item: item})
+
+	s, _ := parseQuery(&Conn{}, `UPDATE "tbl" SET a=? WHERE id=? WITH STRICT=true`)
+	err := s.(*StmtUpdate).conditionalCheckError(awsErr)
+	var condErr *ConditionFailedError
+	if !errors.Is(err, ErrConditionFailed) || !errors.As(err, &condErr) || !reflect.DeepEqual(condErr.Item, item) || errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("%s failed: unexpected error %#v", testName+"/strict", err)
+	}
+
+	s, _ = parseQuery(&Conn{}, `UPDATE "tbl" SET a=? WHERE id=? WITH VERSION=v`)
+	err = s.(*StmtUpdate).conditionalCheckError(awsErr)
+	if !errors.Is(err, ErrConditionFailed) || !errors.Is(err, ErrVersionConflict) || !errors.As(err, &condErr) || !reflect.DeepEqual(condErr.Item, item) {
+		t.Fatalf("%s failed: unexpected error %#v", testName+"/version", err)
+	}
+
+	s, _ = parseQuery(&Conn{}, `DELETE FROM "tbl" WHERE id=?`)
+	if err = s.(*StmtDelete).conditionalCheckError(awsErr); err != nil {
+		t.Fatalf("%s failed: error must be suppressed in non-strict mode, received %#v", testName+"/non_strict", err)
+	}
+
+	conn := &Conn{txStmtList: []*txStmt{{stmt: &Stmt{}}, {stmt: &Stmt{strict: true}}}}
+	txErr := &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+		{Code: aws.String("None")}, {Code: aws.String("ConditionalCheckFailed"), Item: item},
+	}}
+	if err = conn.transactionError(txErr); !errors.As(err, &condErr) || !reflect.DeepEqual(condErr.Item, item) || errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("%s failed: unexpected error %#v", testName+"/tx", err)
+	}
+	conn.txStmtList[1].versionAttr = "v"
+	if err = conn.transactionError(txErr); !errors.Is(err, ErrVersionConflict) || !errors.As(err, &condErr) || !reflect.DeepEqual(condErr.Item, item) {
+		t.Fatalf("%s failed: unexpected error %#v", testName+"/tx_version", err)
+	}
+	conn.txStmtList[1].stmt.strict = false
+	if err = conn.transactionError(txErr); errors.Is(err, ErrConditionFailed) {
+		t.Fatalf("%s failed: unexpected error %#v", testName+"/tx_non_strict", err)
+	}
+}
+
+func Test_Tx_versionConflict(t *testing.T) {
+	testName := "Test_Tx_versionConflict"
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException","message":"Transaction cancelled",` +
+			`"CancellationReasons":[{"Code":"None"},{"Code":"ConditionalCheckFailed","Item":{"id":{"S":"1"},"v":{"N":"2"}}}]}`))
+	}))
+	defer stub.Close()
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint="+stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+
+	for _, testCase := range []struct {
+		name     string
+		query    string
+		args     []interface{}
+		conflict bool
+	}{
+		{name: "version", query: `UPDATE "tbl" SET a=? WHERE id=? WITH VERSION=v`, args: []interface{}{"a", "1", 1}, conflict: true},
+		{name: "strict", query: `UPDATE "tbl" SET a=? WHERE id=? WITH STRICT=true`, args: []interface{}{"a", "1"}},
+	} {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+		}
+		if _, err = tx.Exec(`DELETE FROM "tbl" WHERE id=?`, "0"); err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+		}
+		if _, err = tx.Exec(testCase.query, testCase.args...); err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+		}
+		err = tx.Commit()
+		var condErr *ConditionFailedError
+		if !errors.As(err, &condErr) || condErr.Item["id"] == nil || errors.Is(err, ErrVersionConflict) != testCase.conflict {
+			t.Fatalf("%s failed: unexpected error %#v", testName+"/"+testCase.name, err)
+		}
+	}
+}
+
+func Test_Stmt_Insert_returning(t *testing.T) {
+	testName := "Test_Stmt_Insert_returning"
+	values := []driver.NamedValue{{Ordinal: 1, Value: "app0"}, {Ordinal: 2, Value: 1}}
+	item := map[string]types.AttributeValue{"app": &types.AttributeValueMemberS{Value: "app0"}, "count": &types.AttributeValueMemberN{Value: "1"}}
+	testData := []struct {
+		name      string
+		sql       string
+		query     string
+		returning string
+		items     []map[string]types.AttributeValue
+	}{
+		{name: "no_returning", sql: `INSERT INTO "tbl" VALUE {'app': ?, 'count': ?}`, query: `INSERT INTO "tbl" VALUE {'app': ?, 'count': ?}`},
+		{name: "all_new", sql: `INSERT INTO "tbl" VALUE {'app': ?, 'count': ?} RETURNING ALL NEW *`, query: `INSERT INTO "tbl" VALUE {'app': ?, 'count': ?}`,
+			returning: "ALL NEW", items: []map[string]types.AttributeValue{item}},
+		{name: "modified_new", sql: `INSERT INTO "tbl" VALUE {'app': ?, 'count': ?}
+returning  modified   new *`, query: `INSERT INTO "tbl" VALUE {'app': ?, 'count': ?}`, returning: "MODIFIED NEW", items: []map[string]types.AttributeValue{item}},
+		{name: "all_old", sql: `INSERT INTO "tbl" VALUE {'app': ?, 'count': ?} RETURNING ALL OLD *`, query: `INSERT INTO "tbl" VALUE {'app': ?, 'count': ?}`,
+			returning: "ALL OLD", items: []map[string]types.AttributeValue{}},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(nil, testCase.sql)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			stmt := s.(*StmtInsert)
+			if stmt.query != testCase.query || stmt.returning != testCase.returning || stmt.numInput != 2 {
+				t.Fatalf("%s failed: unexpected parsing result %#v/%#v/%#v", testName+"/"+testCase.name, stmt.query, stmt.returning, stmt.numInput)
+			}
+			if testCase.returning == "" {
+				if _, err = stmt.QueryContext(context.Background(), values); err == nil {
+					t.Fatalf("%s failed: Query without RETURNING clause must fail", testName+"/"+testCase.name)
+				}
+				return
+			}
+			items, err := stmt.returnedItems(values)
+			if err != nil || !reflect.DeepEqual(items, testCase.items) {
+				t.Fatalf("%s failed: expected %#v but received %#v/%s", testName+"/"+testCase.name, testCase.items, items, err)
+			}
+		})
+	}
+
+	s, _ := parseQuery(nil, `INSERT INTO "tbl" VALUE ? RETURNING ALL NEW *`)
+	items, err := s.(*StmtInsert).returnedItems([]driver.NamedValue{{Ordinal: 1, Value: map[string]interface{}{"app": "app0", "count": 1}}})
+	if err != nil || !reflect.DeepEqual(items, []map[string]types.AttributeValue{item}) {
+		t.Fatalf("%s failed: expected %#v but received %#v/%s", testName+"/item_param", item, items, err)
+	}
+}
+
+func Test_Stmt_UpdateDelete_parse_tx(t *testing.T) {
+	testName := "Test_Stmt_UpdateDelete_parse_tx"
+	testData := []struct {
+		name          string
+		sql           string
+		query         string
+		oldImageQuery string
+		skipParams    int
+		mustError     bool
+	}{
+		{name: "update", sql: `UPDATE "tbl" SET a=? SET b='WHERE ?' WHERE id=? AND c=?`, query: `UPDATE "tbl" SET a=? SET b='WHERE ?' WHERE id=? AND c=?`,
+			oldImageQuery: `SELECT * FROM "tbl" WHERE id=? AND c=?`, skipParams: 1},
+		{name: "update_returning_all_old", sql: `UPDATE tbl REMOVE a WHERE id=? RETURNING ALL OLD *`, query: `UPDATE tbl REMOVE a WHERE id=?`,
+			oldImageQuery: `SELECT * FROM tbl WHERE id=?`},
+		{name: "update_version", sql: `UPDATE "tbl" SET a=? WHERE id=? WITH VERSION=v`, query: `UPDATE "tbl" SET a=? SET "v"="v"+1 WHERE (id=?) AND "v"=?`,
+			oldImageQuery: `SELECT * FROM "tbl" WHERE (id=?) AND "v"=?`, skipParams: 1},
+		{name: "delete", sql: `DELETE FROM "tbl" WHERE id=? RETURNING ALL OLD *`, query: `DELETE FROM "tbl" WHERE id=?`,
+			oldImageQuery: `SELECT * FROM "tbl" WHERE id=?`},
+		{name: "delete_no_where", sql: `DELETE FROM "tbl"`, query: `DELETE FROM "tbl"`},
+		{name: "update_returning_all_new", sql: `UPDATE "tbl" SET a=? WHERE id=? RETURNING ALL NEW *`, mustError: true},
+		{name: "delete_returning_modified_old", sql: `DELETE FROM "tbl" WHERE id=? RETURNING MODIFIED OLD *`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(&Conn{txMode: txStarted}, testCase.sql)
+			if testCase.mustError {
+				if err == nil {
+					t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			var stmt *StmtExecutable
+			switch v := s.(type) {
+			case *StmtUpdate:
+				stmt = v.StmtExecutable
+			case *StmtDelete:
+				stmt = v.StmtExecutable
+			}
+			if stmt.query != testCase.query || stmt.oldImageQuery != testCase.oldImageQuery || stmt.oldImageSkipParams != testCase.skipParams {
+				t.Fatalf("%s failed: unexpected parsing result %#v/%#v/%#v", testName+"/"+testCase.name, stmt.query, stmt.oldImageQuery, stmt.oldImageSkipParams)
+			}
+		})
+	}
+}
+
+func Test_TxResultResultSet(t *testing.T) {
+	testName := "Test_TxResultResultSet"
+	conn := &Conn{txMode: txStarted}
+	s, _ := parseQuery(conn, `UPDATE "tbl" SET a=? WHERE id=?`)
+	rows, err := s.(*StmtUpdate).QueryContext(context.Background(), []driver.NamedValue{{Ordinal: 1, Value: "x"}, {Ordinal: 2, Value: "1"}})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err = rows.Next(make([]driver.Value, 0)); !errors.Is(err, ErrInTx) || len(rows.Columns()) != 0 {
+		t.Fatalf("%s failed: expected ErrInTx before commit but received %#v/%#v", testName, err, rows.Columns())
+	}
+	txStmt := conn.lastTxStmt()
+	expectedValues := []driver.NamedValue{{Ordinal: 1, Value: "1"}}
+	if txStmt.oldImageStmt.query != `SELECT * FROM "tbl" WHERE id=?` || !reflect.DeepEqual(txStmt.oldImageValues, expectedValues) {
+		t.Fatalf("%s failed: unexpected old image statement %#v/%#v", testName, txStmt.oldImageStmt.query, txStmt.oldImageValues)
+	}
+
+	// simulate a committed transaction
+	item := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "1"}, "a": &types.AttributeValueMemberS{Value: "y"}}
+	txStmt.output = &dynamodb.ExecuteStatementOutput{Items: []map[string]types.AttributeValue{item}}
+	if expected := []string{"a", "id"}; !reflect.DeepEqual(rows.Columns(), expected) {
+		t.Fatalf("%s failed: expected columns %#v but received %#v", testName, expected, rows.Columns())
+	}
+	dest := make([]driver.Value, 2)
+	if err = rows.Next(dest); err != nil || dest[0] != "y" || dest[1] != "1" {
+		t.Fatalf("%s failed: unexpected row %#v/%s", testName, dest, err)
+	}
+	if err = rows.Next(dest); err != io.EOF {
+		t.Fatalf("%s failed: expected io.EOF but received %#v", testName, err)
+	}
+}
+
+// Test_TxResultResultSet_contract documents the limitations of RETURNING ALL OLD inside transactions: the old image is
+// read right before, and outside of, the transaction; and database/sql closes the rows when the transaction is
+// committed, so that they can only be read at driver level.
+func Test_TxResultResultSet_contract(t *testing.T) {
+	testName := "Test_TxResultResultSet_contract"
+	var lock sync.Mutex
+	var requests []string
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		target := r.Header.Get("X-Amz-Target")
+		lock.Lock()
+		switch target {
+		case "DynamoDB_20120810.ExecuteStatement":
+			requests = append(requests, fmt.Sprintf("%s/%v/%v", target, input["Statement"], input["ConsistentRead"]))
+		case "DynamoDB_20120810.ExecuteTransaction":
+			for _, stmt := range input["TransactStatements"].([]interface{}) {
+				stmt := stmt.(map[string]interface{})
+				requests = append(requests, fmt.Sprintf("%s/%v/%d", target, stmt["Statement"], len(stmt["Parameters"].([]interface{}))))
+			}
+		}
+		lock.Unlock()
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if target == "DynamoDB_20120810.ExecuteTransaction" {
+			_, _ = w.Write([]byte(`{"Responses":[{}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"Items":[{"id":{"S":"1"},"a":{"S":"old"}}]}`))
+	}))
+	defer stub.Close()
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint="+stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+
+	// database/sql: rows are not readable before commit, and are closed by the commit
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	dbrows, err := tx.Query(`UPDATE "tbl" SET a=? WHERE id=?`, "new", "1")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if dbrows.Next() || !errors.Is(dbrows.Err(), ErrInTx) {
+		t.Fatalf("%s failed: expected ErrInTx before commit but received %v", testName, dbrows.Err())
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if dbrows.Next() {
+		t.Fatalf("%s failed: rows must be closed once the transaction is committed", testName)
+	}
+
+	// driver level: rows are readable after commit, and hold the old image read before the transaction
+	lock.Lock()
+	requests = nil
+	lock.Unlock()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = conn.Close() }()
+	err = conn.Raw(func(driverConn interface{}) error {
+		c := driverConn.(*Conn)
+		tx, err := c.BeginTx(ctx, driver.TxOptions{})
+		if err != nil {
+			return err
+		}
+		stmt, err := c.PrepareContext(ctx, `UPDATE "tbl" SET a=? WHERE id=?`)
+		if err != nil {
+			return err
+		}
+		rows, err := stmt.(driver.StmtQueryContext).QueryContext(ctx, []driver.NamedValue{{Ordinal: 1, Value: "new"}, {Ordinal: 2, Value: "1"}})
+		if err != nil {
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+		dest := make([]driver.Value, len(rows.Columns()))
+		if err = rows.Next(dest); err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(dest, []driver.Value{"old", "1"}) {
+			return fmt.Errorf("expected the old image but received %#v", dest)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	expected := []string{
+		`DynamoDB_20120810.ExecuteStatement/SELECT * FROM "tbl" WHERE id=?/true`,
+		`DynamoDB_20120810.ExecuteTransaction/UPDATE "tbl" SET a=? WHERE (id=?) AND "a"=? AND "id"=?/4`,
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Fatalf("%s failed: the old image must be read with a consistent read before the transaction, which is conditioned on it: %#v", testName, requests)
+	}
+
+	// database/sql: rows are received via sql.Out once the transaction is committed
+	lock.Lock()
+	requests = nil
+	lock.Unlock()
+	tx, err = db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	var returningUpdate, returningInsert TxReturning
+	if _, err = tx.Exec(`UPDATE "tbl" SET a=? WHERE id=?`, "new", "1", sql.Out{Dest: &returningUpdate}); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.Exec(`DELETE FROM "tbl" WHERE id=?`, "2"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.Exec(`INSERT INTO "tbl" VALUE {'id': ?} RETURNING ALL NEW *`, "3", sql.Out{Dest: &returningInsert}); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if returningUpdate.Items != nil || returningInsert.Items != nil {
+		t.Fatalf("%s failed: rows must not be received before commit", testName)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	oldImage := []map[string]types.AttributeValue{{"id": &types.AttributeValueMemberS{Value: "1"}, "a": &types.AttributeValueMemberS{Value: "old"}}}
+	if !reflect.DeepEqual(returningUpdate.Items, oldImage) {
+		t.Fatalf("%s failed: expected the old image but received %#v", testName, returningUpdate.Items)
+	}
+	newImage := []map[string]types.AttributeValue{{"id": &types.AttributeValueMemberS{Value: "3"}}}
+	if !reflect.DeepEqual(returningInsert.Items, newImage) {
+		t.Fatalf("%s failed: expected the inserted item but received %#v", testName, returningInsert.Items)
+	}
+	expected = []string{
+		`DynamoDB_20120810.ExecuteStatement/SELECT * FROM "tbl" WHERE id=?/true`,
+		`DynamoDB_20120810.ExecuteTransaction/UPDATE "tbl" SET a=? WHERE (id=?) AND "a"=? AND "id"=?/4`,
+		`DynamoDB_20120810.ExecuteTransaction/DELETE FROM "tbl" WHERE id=?/1`,
+		`DynamoDB_20120810.ExecuteTransaction/INSERT INTO "tbl" VALUE {'id': ?}/1`,
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Fatalf("%s failed: only the statement receiving rows via sql.Out must read the old image: %#v", testName, requests)
+	}
+}
+
+func Test_TxReturning_invalid(t *testing.T) {
+	testName := "Test_TxReturning_invalid"
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer stub.Close()
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint="+stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+
+	var returning TxReturning
+	if _, err = db.Exec(`DELETE FROM "tbl" WHERE id=?`, "1", sql.Out{Dest: &returning}); err == nil {
+		t.Fatalf("%s failed: sql.Out must be rejected outside of a transaction", testName)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	var items []map[string]types.AttributeValue
+	if _, err = tx.Exec(`DELETE FROM "tbl" WHERE id=?`, "1", sql.Out{Dest: &items}); err == nil {
+		t.Fatalf("%s failed: unsupported sql.Out destination must be rejected", testName)
+	}
+	if _, err = tx.Exec(`INSERT INTO "tbl" VALUE {'id': ?}`, "1", sql.Out{Dest: &returning}); err == nil {
+		t.Fatalf("%s failed: INSERT without RETURNING clause must be rejected", testName)
+	}
+	if _, err = tx.Exec(`DELETE FROM "tbl"`, sql.Out{Dest: &returning}); err == nil {
+		t.Fatalf("%s failed: DELETE without WHERE clause must be rejected", testName)
+	}
+}
@@ -0,0 +1,172 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// explainColumns lists the columns returned by EXPLAIN statement, in order.
+var explainColumns = []string{
+	"AccessPath", "TableName", "IndexName", "IndexType", "PartitionKey", "SortKey", "KeyConditions", "Filter",
+	"Projection", "Parallel", "ConsistentRead", "ItemCount", "SizeBytes", "AvgItemSizeBytes", "EstimatedRCUPerItem",
+}
+
+// readCapacityUnitSize is the item size covered by 1 strongly consistent read capacity unit.
+const readCapacityUnitSize = 4096
+
+// estimateRCUPerItem estimates the read capacity units consumed to read an item of the average size.
+// A strongly consistent read consumes 1 RCU per 4KB, an eventually consistent read consumes half of that.
+func estimateRCUPerItem(avgItemSize float64, consistentRead bool) float64 {
+	rcu := math.Ceil(math.Max(avgItemSize, 1) / readCapacityUnitSize)
+	if !consistentRead {
+		rcu /= 2
+	}
+	return rcu
+}
+
+// StmtExplain implements "EXPLAIN" statement.
+//
+// Syntax:
+//
+//	EXPLAIN <select-statement>
+//
+//	- select-statement: a SELECT statement, can contain placeholders, LIMIT and WITH clauses. Values for placeholders can be supplied but are not used.
+//	- The statement returns one row describing how DynamoDB executes the SELECT statement, with the following columns:
+//	  AccessPath (GetItem, Query or Scan), TableName, IndexName and IndexType (GSI, LSI or empty if the base table is accessed),
+//	  PartitionKey, SortKey, KeyConditions, Filter (conditions applied after items are read), Projection, Parallel (number of segments of
+//	  parallel scan), ConsistentRead, ItemCount and SizeBytes (of the table or index, as reported by DescribeTable), AvgItemSizeBytes and
+//	  EstimatedRCUPerItem.
+//	- The SELECT statement is not executed; only DescribeTable is called (and its result cached).
+//
+// @Available since v1.4.0
+type StmtExplain struct {
+	*Stmt
+	selectStmt *StmtSelect
+}
+
+func (s *StmtExplain) validate() error {
+	if s.selectStmt.selectQuery == nil {
+		return fmt.Errorf("cannot explain statement: %w", s.selectStmt.selectQueryErr)
+	}
+	return nil
+}
+
+// Exec implements driver.Stmt/Exec.
+// This function is not implemented, use Query instead.
+func (s *StmtExplain) Exec(_ []driver.Value) (driver.Result, error) {
+	return nil, errors.New("this operation is not supported, please use Query")
+}
+
+// ExecContext implements driver.StmtExecContext/ExecContext.
+// This function is not implemented, use QueryContext instead.
+func (s *StmtExplain) ExecContext(_ context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	return nil, errors.New("this operation is not supported, please use QueryContext")
+}
+
+// Query implements driver.Stmt/Query.
+func (s *StmtExplain) Query(values []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), ValuesToNamedValues(values))
+}
+
+// QueryContext implements driver.StmtQueryContext/QueryContext.
+func (s *StmtExplain) QueryContext(ctx context.Context, _ []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
+	query := s.selectStmt.selectQuery
+	desc, err := s.conn.describeTable(ctx, query.tableName)
+	if err != nil {
+		return &ResultResultSet{err: err}, err
+	}
+	item, err := s.explain(desc)
+	if err != nil {
+		return &ResultResultSet{err: err}, err
+	}
+	columnList := make([]string, len(explainColumns))
+	copy(columnList, explainColumns)
+	result := (&ResultResultSet{
+		stmtOutput: &dynamodb.ExecuteStatementOutput{Items: []map[string]types.AttributeValue{item}},
+		columnList: columnList,
+		numberMode: s.conn.numberMode,
+	}).init()
+	return result, nil
+}
+
+// explain builds the row describing how the SELECT statement is executed.
+func (s *StmtExplain) explain(desc *types.TableDescription) (map[string]types.AttributeValue, error) {
+	query := s.selectStmt.selectQuery
+	path, err := analyzeAccessPath(query, desc)
+	if err != nil {
+		return nil, err
+	}
+	if s.selectStmt.parallel > 0 {
+		// parallel scan applies all conditions as filter
+		path.method = accessPathScan
+		path.filter = query.where.conjuncts()
+		path.keyConditions = nil
+	}
+
+	itemCount, sizeBytes := aws.ToInt64(desc.ItemCount), aws.ToInt64(desc.TableSizeBytes)
+	for _, gsi := range desc.GlobalSecondaryIndexes {
+		if path.indexType == "GSI" && aws.ToString(gsi.IndexName) == path.indexName {
+			itemCount, sizeBytes = aws.ToInt64(gsi.ItemCount), aws.ToInt64(gsi.IndexSizeBytes)
+		}
+	}
+	for _, lsi := range desc.LocalSecondaryIndexes {
+		if path.indexType == "LSI" && aws.ToString(lsi.IndexName) == path.indexName {
+			itemCount, sizeBytes = aws.ToInt64(lsi.ItemCount), aws.ToInt64(lsi.IndexSizeBytes)
+		}
+	}
+	avgItemSize := 0.0
+	if itemCount > 0 {
+		avgItemSize = float64(sizeBytes) / float64(itemCount)
+	}
+	consistentRead := s.selectStmt.withOpts["CONSISTENT_READ"].FirstBool() || s.selectStmt.withOpts["CONSISTENTREAD"].FirstBool()
+
+	projection := "*"
+	if len(query.projection) > 0 {
+		paths := make([]string, len(query.projection))
+		for i, p := range query.projection {
+			paths[i] = p.String()
+		}
+		projection = strings.Join(paths, ", ")
+	}
+	tableName, _ := s.conn.logicalTableName(path.tableName)
+	return map[string]types.AttributeValue{
+		"AccessPath":          &types.AttributeValueMemberS{Value: path.method},
+		"TableName":           &types.AttributeValueMemberS{Value: tableName},
+		"IndexName":           &types.AttributeValueMemberS{Value: path.indexName},
+		"IndexType":           &types.AttributeValueMemberS{Value: path.indexType},
+		"PartitionKey":        &types.AttributeValueMemberS{Value: path.partitionKey},
+		"SortKey":             &types.AttributeValueMemberS{Value: path.sortKey},
+		"KeyConditions":       &types.AttributeValueMemberS{Value: conditionsString(path.keyConditions)},
+		"Filter":              &types.AttributeValueMemberS{Value: conditionsString(path.filter)},
+		"Projection":          &types.AttributeValueMemberS{Value: projection},
+		"Parallel":            &types.AttributeValueMemberN{Value: strconv.Itoa(s.selectStmt.parallel)},
+		"ConsistentRead":      &types.AttributeValueMemberBOOL{Value: consistentRead},
+		"ItemCount":           &types.AttributeValueMemberN{Value: strconv.FormatInt(itemCount, 10)},
+		"SizeBytes":           &types.AttributeValueMemberN{Value: strconv.FormatInt(sizeBytes, 10)},
+		"AvgItemSizeBytes":    &types.AttributeValueMemberN{Value: strconv.FormatFloat(avgItemSize, 'f', -1, 64)},
+		"EstimatedRCUPerItem": &types.AttributeValueMemberN{Value: strconv.FormatFloat(estimateRCUPerItem(avgItemSize, consistentRead), 'f', -1, 64)},
+	}, nil
+}
+
+// conditionsString returns the textual form of a list of AND-ed conditions.
+func conditionsString(conds []*partiqlCondition) string {
+	result := make([]string, len(conds))
+	for i, c := range conds {
+		result[i] = c.String()
+		if len(conds) > 1 && c.op == "OR" {
+			result[i] = "(" + result[i] + ")"
+		}
+	}
+	return strings.Join(result, " AND ")
+}
@@ -0,0 +1,103 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func Test_Stmt_Explain_parse(t *testing.T) {
+	testName := "Test_Stmt_Explain_parse"
+	testData := []struct {
+		name      string
+		sql       string
+		numInput  int
+		mustError bool
+	}{
+		{name: "basic", sql: `EXPLAIN SELECT * FROM "tbl" WHERE app=?`, numInput: 1},
+		{name: "multi_lines", sql: "EXPLAIN\nSELECT *\nFROM \"tbl\"\nWHERE app=? AND \"user\"=? LIMIT 10", numInput: 2},
+
+		{name: "not_select", sql: `EXPLAIN DELETE FROM "tbl" WHERE app=?`, mustError: true},
+		{name: "cannot_analyze", sql: `EXPLAIN SELECT * FROM "tbl" WHERE size(a) > 1`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(nil, testCase.sql)
+			if testCase.mustError && err == nil {
+				t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+			}
+			if testCase.mustError {
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			stmt, ok := s.(*StmtExplain)
+			if !ok {
+				t.Fatalf("%s failed: expected StmtExplain but received %T", testName+"/"+testCase.name, s)
+			}
+			if stmt.numInput != testCase.numInput {
+				t.Fatalf("%s failed: expected %d inputs but received %d", testName+"/"+testCase.name, testCase.numInput, stmt.numInput)
+			}
+		})
+	}
+}
+
+func Test_Stmt_Explain_Query(t *testing.T) {
+	testName := "Test_Stmt_Explain_Query"
+	conn := &Conn{tableDescriptions: &tableDescriptionCache{entries: make(map[string]tableDescriptionEntry)}}
+	conn.tableDescriptions.put("tbl", _testTableDescription())
+	testData := []struct {
+		name     string
+		sql      string
+		expected map[string]interface{}
+	}{
+		{name: "get_item", sql: `EXPLAIN SELECT a, b.c FROM tbl WHERE app=? AND "user"=? WITH ConsistentRead=true`, expected: map[string]interface{}{
+			"AccessPath": "GetItem", "TableName": "tbl", "IndexName": "", "IndexType": "", "PartitionKey": "app", "SortKey": "user",
+			"KeyConditions": "app = ? AND user = ?", "Filter": "", "Projection": "a, b.c", "Parallel": 0.0, "ConsistentRead": true,
+			"ItemCount": 10.0, "SizeBytes": 10000.0, "AvgItemSizeBytes": 1000.0, "EstimatedRCUPerItem": 1.0,
+		}},
+		{name: "query_gsi", sql: `EXPLAIN SELECT * FROM "tbl"."idx_os" WHERE os=? AND (a=1 OR b=2) AND c>?`, expected: map[string]interface{}{
+			"AccessPath": "Query", "TableName": "tbl", "IndexName": "idx_os", "IndexType": "GSI", "PartitionKey": "os", "SortKey": "",
+			"KeyConditions": "os = ?", "Filter": "(a = 1 OR b = 2) AND c > ?", "Projection": "*", "Parallel": 0.0, "ConsistentRead": false,
+			"ItemCount": 0.0, "SizeBytes": 0.0, "AvgItemSizeBytes": 0.0, "EstimatedRCUPerItem": 0.5,
+		}},
+		{name: "parallel_scan", sql: `EXPLAIN SELECT * FROM tbl WHERE app=? WITH PARALLEL=4`, expected: map[string]interface{}{
+			"AccessPath": "Scan", "TableName": "tbl", "IndexName": "", "IndexType": "", "PartitionKey": "app", "SortKey": "user",
+			"KeyConditions": "", "Filter": "app = ?", "Projection": "*", "Parallel": 4.0, "ConsistentRead": false,
+			"ItemCount": 10.0, "SizeBytes": 10000.0, "AvgItemSizeBytes": 1000.0, "EstimatedRCUPerItem": 0.5,
+		}},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(conn, testCase.sql)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			rows, err := s.(*StmtExplain).QueryContext(context.Background(), nil)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			cols := rows.Columns()
+			if !reflect.DeepEqual(cols, explainColumns) {
+				t.Fatalf("%s failed: unexpected columns %v", testName+"/"+testCase.name, cols)
+			}
+			values := make([]driver.Value, len(cols))
+			if err := rows.Next(values); err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			row := make(map[string]interface{})
+			for i, col := range cols {
+				row[col] = values[i]
+			}
+			if !reflect.DeepEqual(row, testCase.expected) {
+				t.Fatalf("%s failed:\nexpected %#v\nreceived %#v", testName+"/"+testCase.name, testCase.expected, row)
+			}
+			if err := rows.Next(values); err != io.EOF {
+				t.Fatalf("%s failed: expected a single row", testName+"/"+testCase.name)
+			}
+		})
+	}
+}
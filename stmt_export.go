@@ -0,0 +1,303 @@
+package godynamo
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// ExportFormatJsonl exports each item as a "plain" JSON object per line (JSON Lines).
+	// Numbers are written with their original precision; sets are written as JSON arrays.
+	//
+	// @Available since v1.4.0
+	ExportFormatJsonl = "JSONL"
+
+	// ExportFormatCsv exports items as CSV. The header row is the union of attribute names of all exported items
+	// (or the selected column list, if the SELECT statement specifies one).
+	//
+	// @Available since v1.4.0
+	ExportFormatCsv = "CSV"
+
+	// ExportFormatDDBJson exports each item as a line of typed "DynamoDB JSON", e.g. {"Item":{"id":{"S":"1"},"score":{"N":"10"}}},
+	// which is the same format used by DynamoDB's export to S3.
+	//
+	// @Available since v1.4.0
+	ExportFormatDDBJson = "DDBJSON"
+)
+
+var (
+	// ErrInvalidExportFormat is returned when the export format is not one of the supported formats.
+	//
+	// @Available since v1.4.0
+	ErrInvalidExportFormat = errors.New("invalid export format, supported formats are JSONL, CSV and DDBJSON")
+)
+
+func normalizeExportFormat(format string) (string, error) {
+	format = strings.ToUpper(strings.TrimSpace(format))
+	switch format {
+	case ExportFormatJsonl, ExportFormatCsv, ExportFormatDDBJson:
+		return format, nil
+	}
+	return format, ErrInvalidExportFormat
+}
+
+// Export executes a SELECT query and writes the returned items to w in the specified format (ExportFormatJsonl,
+// ExportFormatCsv or ExportFormatDDBJson). The number of exported items is returned.
+//
+//   - args are values for the placeholders in query.
+//   - query must not contain WITH PARALLEL or WITH RAW_ITEM clause.
+//   - The export is reported to Hooks as a statement of kind "EXPORT".
+//   - Items are fetched page by page; with JSONL and DDBJSON formats, each page is written to w as soon as it is fetched.
+//   - With CSV format, all items are buffered in memory to compute the header row before being written to w.
+//   - The export is bounded by ctx and the query's WITH TIMEOUT clause; the connection's timeout (DSN option TimeoutMs)
+//     bounds each request sent to DynamoDB, but not the whole export.
+//
+// Example:
+//
+//	f, _ := os.Create("session.jsonl")
+//	defer f.Close()
+//	numItems, err := godynamo.Export(ctx, db, `SELECT * FROM "session" WHERE app=?`, f, godynamo.ExportFormatJsonl, "frontend")
+//
+// @Available since v1.4.0
+func Export(ctx context.Context, db *sql.DB, query string, w io.Writer, format string, args ...interface{}) (int64, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = conn.Close() }()
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg
+	}
+	var numItems int64
+	err = conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*Conn)
+		if !ok {
+			return fmt.Errorf("expected *godynamo.Conn but received %T", driverConn)
+		}
+		stmt, err := parseQuery(c, query)
+		if err != nil {
+			return err
+		}
+		stmtSelect, ok := stmt.(*StmtSelect)
+		if !ok {
+			return errors.New("only SELECT statement can be exported")
+		}
+		_, table := c.stmtKindAndTable(stmtSelect)
+		info := &StatementInfo{Kind: "EXPORT", Table: table, Query: stmtQuery(stmtSelect), NumParams: len(values)}
+		return c.withStatementHooks(ctx, info, func(ctx context.Context) (int64, error) {
+			ctx, cancel := stmtSelect.newLongRunningContext(ctx)
+			defer cancel()
+			numItems, err = c.exportContext(ctx, stmtSelect, ValuesToNamedValues(values), w, format)
+			return numItems, err
+		})
+	})
+	return numItems, err
+}
+
+// exportContext executes a SELECT statement and writes the returned items to w.
+func (c *Conn) exportContext(ctx context.Context, stmt *StmtSelect, values []driver.NamedValue, w io.Writer, format string) (int64, error) {
+	format, input, err := c.prepareExport(ctx, stmt, values, format)
+	if err != nil {
+		return 0, err
+	}
+	return c.writeExport(ctx, stmt, input, w, format)
+}
+
+// prepareExport validates an export before any item is fetched or written, and returns the normalized format and the
+// input of the SELECT statement.
+func (c *Conn) prepareExport(ctx context.Context, stmt *StmtSelect, values []driver.NamedValue, format string) (string, *dynamodb.ExecuteStatementInput, error) {
+	format, err := normalizeExportFormat(format)
+	if err != nil {
+		return "", nil, err
+	}
+	if c.txMode != txNone {
+		return "", nil, ErrInTx
+	}
+	if stmt.parallel > 0 {
+		return "", nil, errors.New("WITH PARALLEL is not supported by EXPORT")
+	}
+	if stmt.rawItem {
+		return "", nil, errors.New("WITH RAW_ITEM is not supported by EXPORT")
+	}
+	if err := c.checkFullScan(ctx, stmt); err != nil {
+		return "", nil, err
+	}
+	input, err := buildExecuteStatementInput(stmt.Stmt, values)
+	if err != nil {
+		return "", nil, err
+	}
+	return format, input, nil
+}
+
+// writeExport fetches the items returned by a SELECT statement and writes them to w.
+func (c *Conn) writeExport(ctx context.Context, stmt *StmtSelect, input *dynamodb.ExecuteStatementInput, w io.Writer, format string) (int64, error) {
+	var err error
+	c.lastConsumedCapacity = 0
+	c.lastPageCount = 0
+
+	numItems := int64(0)
+	if format == ExportFormatCsv {
+		items := make([]map[string]types.AttributeValue, 0)
+		err = c.fetchPages(ctx, stmt.Stmt, input, func(page *dynamodb.ExecuteStatementOutput) error {
+			items = append(items, page.Items...)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		return int64(len(items)), writeCsv(w, items, extractSelectedColumnList(stmt.query))
+	}
+
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	err = c.fetchPages(ctx, stmt.Stmt, input, func(page *dynamodb.ExecuteStatementOutput) error {
+		for _, item := range page.Items {
+			var err error
+			if format == ExportFormatDDBJson {
+				err = encoder.Encode(map[string]interface{}{"Item": itemToDynamoDBJson(item)})
+			} else {
+				err = encoder.Encode(itemToPlainJson(item))
+			}
+			if err != nil {
+				return err
+			}
+			numItems++
+		}
+		return bw.Flush()
+	})
+	return numItems, err
+}
+
+// writeCsv writes items as CSV; the header row is columnList if not empty, or the union of attribute names of all items.
+func writeCsv(w io.Writer, items []map[string]types.AttributeValue, columnList []string) error {
+	// ResultResultSet.init calculates the union of attribute names
+	rs := (&ResultResultSet{stmtOutput: &dynamodb.ExecuteStatementOutput{Items: items}, columnList: columnList}).init()
+	writer := csv.NewWriter(w)
+	if err := writer.Write(rs.columnList); err != nil {
+		return err
+	}
+	record := make([]string, len(rs.columnList))
+	for _, item := range items {
+		for i, col := range rs.columnList {
+			record[i] = toText(item[col])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+/*----------------------------------------------------------------------*/
+
+// StmtExport implements "EXPORT" statement.
+//
+// Syntax:
+//
+//	EXPORT (<select-statement>) TO '<file-name>' FORMAT JSONL|CSV|DDBJSON
+//
+//	- select-statement: a SELECT statement, can contain placeholders, LIMIT and WITH clauses. WITH PARALLEL and
+//	  WITH RAW_ITEM are not supported.
+//	- file-name: the file to write exported items to. The file is created if it does not exist, or replaced otherwise.
+//	  Items are written to a temporary file in the same directory, which is renamed to file-name once the export
+//	  succeeds; if the statement is rejected or fails, an existing file is left untouched.
+//	- FORMAT: see ExportFormatJsonl, ExportFormatCsv and ExportFormatDDBJson.
+//	- RowsAffected() returns the number of exported items.
+//
+// The statement is not bounded by the connection's timeout (DSN option TimeoutMs), which bounds each request sent to
+// DynamoDB instead; use clause WITH TIMEOUT=<ms> or the caller's context to bound the whole export.
+//
+// @Available since v1.4.0
+type StmtExport struct {
+	*Stmt
+	selectStmt *StmtSelect
+	fileName   string
+	format     string
+}
+
+func (s *StmtExport) validate() error {
+	if s.fileName == "" {
+		return errors.New("file name is missing")
+	}
+	_, err := normalizeExportFormat(s.format)
+	return err
+}
+
+// Query implements driver.Stmt/Query.
+// This function is not implemented, use Exec instead.
+func (s *StmtExport) Query(_ []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("this operation is not supported, please use Exec")
+}
+
+// QueryContext implements driver.StmtQueryContext/QueryContext.
+// This function is not implemented, use ExecContext instead.
+func (s *StmtExport) QueryContext(_ context.Context, _ []driver.NamedValue) (driver.Rows, error) {
+	return nil, errors.New("this operation is not supported, please use ExecContext")
+}
+
+// Exec implements driver.Stmt/Exec.
+func (s *StmtExport) Exec(values []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), ValuesToNamedValues(values))
+}
+
+// ExecContext implements driver.StmtExecContext/ExecContext.
+func (s *StmtExport) ExecContext(ctx context.Context, values []driver.NamedValue) (driver.Result, error) {
+	ctx, cancel := s.newLongRunningContext(ctx)
+	defer cancel()
+	format, input, err := s.conn.prepareExport(ctx, s.selectStmt, values, s.format)
+	if err != nil {
+		return &ResultNoResultSet{err: err}, err
+	}
+	numItems, err := writeFileAtomically(s.fileName, func(w io.Writer) (int64, error) {
+		return s.conn.writeExport(ctx, s.selectStmt, input, w, format)
+	})
+	if err != nil {
+		numItems = 0
+	}
+	return &ResultNoResultSet{err: err, affectedRows: numItems}, err
+}
+
+// writeFileAtomically calls write with a temporary file created in the same directory as fileName, and renames the
+// temporary file to fileName if write succeeds. An existing file is left untouched if write fails; otherwise it is
+// replaced, keeping its permissions.
+func writeFileAtomically(fileName string, write func(w io.Writer) (int64, error)) (int64, error) {
+	f, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	tmpFileName := f.Name()
+	defer func() { _ = os.Remove(tmpFileName) }() // no-op once renamed
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(fileName); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	n, err := write(f)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFileName, fileName)
+	}
+	return n, err
+}
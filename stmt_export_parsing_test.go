@@ -0,0 +1,246 @@
+package godynamo
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func Test_Stmt_Export_parse(t *testing.T) {
+	testName := "Test_Stmt_Export_parse"
+	testData := []struct {
+		name      string
+		sql       string
+		selectSql string
+		fileName  string
+		format    string
+		numInput  int
+		mustError bool
+	}{
+		{name: "basic", sql: `EXPORT (SELECT * FROM "table") TO 'out.jsonl' FORMAT jsonl`, selectSql: `SELECT * FROM "table"`, fileName: "out.jsonl", format: "JSONL"},
+		{name: "csv", sql: `EXPORT(SELECT a, b FROM "table" WHERE id=?) TO '/tmp/out.csv' FORMAT CSV`, selectSql: `SELECT a, b FROM "table" WHERE id=?`, fileName: "/tmp/out.csv", format: "CSV", numInput: 1},
+		{name: "with_parentheses", sql: `EXPORT (SELECT * FROM "table" WHERE (a=? OR b=?) LIMIT 10) TO 'out.json' FORMAT DDBJSON`, selectSql: `SELECT * FROM "table" WHERE (a=? OR b=?)`, fileName: "out.json", format: "DDBJSON", numInput: 2},
+		{name: "multi_lines", sql: "EXPORT (\nSELECT * FROM \"table\"\n)\nTO 'out.json'\nFORMAT DDBJSON", selectSql: `SELECT * FROM "table"`, fileName: "out.json", format: "DDBJSON"},
+
+		{name: "invalid_format", sql: `EXPORT (SELECT * FROM "table") TO 'out.xml' FORMAT XML`, mustError: true},
+		{name: "no_file", sql: `EXPORT (SELECT * FROM "table") TO '' FORMAT CSV`, mustError: true},
+		{name: "not_select", sql: `EXPORT (DELETE FROM "table") TO 'out.csv' FORMAT CSV`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(nil, testCase.sql)
+			if testCase.mustError && err == nil {
+				t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+			}
+			if testCase.mustError {
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			stmt, ok := s.(*StmtExport)
+			if !ok {
+				t.Fatalf("%s failed: expected StmtExport but received %T", testName+"/"+testCase.name, s)
+			}
+			if stmt.selectStmt.query != testCase.selectSql {
+				t.Fatalf("%s failed: expected select %#v but received %#v", testName+"/"+testCase.name, testCase.selectSql, stmt.selectStmt.query)
+			}
+			if stmt.fileName != testCase.fileName || stmt.format != testCase.format || stmt.numInput != testCase.numInput {
+				t.Fatalf("%s failed: unexpected parsing result %#v", testName+"/"+testCase.name, stmt)
+			}
+		})
+	}
+}
+
+func Test_ExportFormats(t *testing.T) {
+	testName := "Test_ExportFormats"
+	item := map[string]types.AttributeValue{
+		"id":    &types.AttributeValueMemberS{Value: "1"},
+		"big":   &types.AttributeValueMemberN{Value: "12345678901234567890"},
+		"tags":  &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"nums":  &types.AttributeValueMemberNS{Value: []string{"1", "2.5"}},
+		"bin":   &types.AttributeValueMemberB{Value: []byte("hi")},
+		"null":  &types.AttributeValueMemberNULL{Value: true},
+		"flag":  &types.AttributeValueMemberBOOL{Value: true},
+		"doc":   &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"n": &types.AttributeValueMemberN{Value: "1"}}},
+		"list":  &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "x"}}},
+		"bins":  &types.AttributeValueMemberBS{Value: [][]byte{[]byte("hi")}},
+		"empty": &types.AttributeValueMemberS{Value: ""},
+	}
+
+	js, _ := json.Marshal(itemToPlainJson(item))
+	expectedPlain := `{"big":12345678901234567890,"bin":"aGk=","bins":["aGk="],"doc":{"n":1},"empty":"","flag":true,"id":"1","list":["x"],"null":null,"nums":[1,2.5],"tags":["a","b"]}`
+	if string(js) != expectedPlain {
+		t.Fatalf("%s failed: expected %s but received %s", testName+"/plain", expectedPlain, js)
+	}
+
+	js, _ = json.Marshal(itemToDynamoDBJson(item))
+	expectedTyped := `{"big":{"N":"12345678901234567890"},"bin":{"B":"aGk="},"bins":{"BS":["aGk="]},"doc":{"M":{"n":{"N":"1"}}},"empty":{"S":""},"flag":{"BOOL":true},"id":{"S":"1"},"list":{"L":[{"S":"x"}]},"null":{"NULL":true},"nums":{"NS":["1","2.5"]},"tags":{"SS":["a","b"]}}`
+	if string(js) != expectedTyped {
+		t.Fatalf("%s failed: expected %s but received %s", testName+"/ddbjson", expectedTyped, js)
+	}
+
+	buf := &bytes.Buffer{}
+	items := []map[string]types.AttributeValue{item, {"id": &types.AttributeValueMemberS{Value: "2"}, "extra": &types.AttributeValueMemberN{Value: "3"}}}
+	if err := writeCsv(buf, items, nil); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/csv", err)
+	}
+	expectedCsv := "big,bin,bins,doc,empty,extra,flag,id,list,null,nums,tags\n" +
+		"12345678901234567890,aGk=,\"[\"\"aGk=\"\"]\",\"{\"\"n\"\":1}\",,,true,1,\"[\"\"x\"\"]\",,\"[1,2.5]\",\"[\"\"a\"\",\"\"b\"\"]\"\n" +
+		",,,,,3,,2,,,,\n"
+	if buf.String() != expectedCsv {
+		t.Fatalf("%s failed: expected\n%s\nreceived\n%s", testName+"/csv", expectedCsv, buf.String())
+	}
+
+	buf.Reset()
+	if err := writeCsv(buf, items, []string{"id", "extra"}); err != nil {
+		t.Fatalf("%s failed: %s", testName+"/csv_columns", err)
+	}
+	if expected := "id,extra\n1,\n2,3\n"; buf.String() != expected {
+		t.Fatalf("%s failed: expected\n%s\nreceived\n%s", testName+"/csv_columns", expected, buf.String())
+	}
+}
+
+func TestStmtExport_keepsFileOnFailure(t *testing.T) {
+	testName := "TestStmtExport_keepsFileOnFailure"
+	stub := _newHooksStub()
+	defer stub.Close()
+	db := _openDbWithHooks(t, stub.URL)
+	fileName := filepath.Join(t.TempDir(), "out.jsonl")
+	if err := os.WriteFile(fileName, []byte("previous export\n"), 0600); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	query := `EXPORT (SELECT * FROM tbl) TO '` + fileName + `' FORMAT JSONL`
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.Exec(query); !errors.Is(err, ErrInTx) {
+		t.Fatalf("%s failed: expected ErrInTx but received %#v", testName, err)
+	}
+	_ = tx.Rollback()
+	if content, _ := os.ReadFile(fileName); string(content) != "previous export\n" {
+		t.Fatalf("%s failed: rejected EXPORT must not modify the existing file, received %q", testName, content)
+	}
+
+	if _, err = writeFileAtomically(fileName, func(w io.Writer) (int64, error) {
+		_, _ = w.Write([]byte("partial"))
+		return 1, errors.New("fetching failed")
+	}); err == nil {
+		t.Fatalf("%s failed: expected error", testName)
+	}
+	if content, _ := os.ReadFile(fileName); string(content) != "previous export\n" {
+		t.Fatalf("%s failed: failed EXPORT must not modify the existing file, received %q", testName, content)
+	}
+
+	result, err := db.Exec(query)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if numItems, _ := result.RowsAffected(); numItems != 2 {
+		t.Fatalf("%s failed: expected 2 exported items but received %d", testName, numItems)
+	}
+	content, _ := os.ReadFile(fileName)
+	if expected := "{\"id\":\"a\"}\n{\"id\":\"b\"}\n"; string(content) != expected {
+		t.Fatalf("%s failed: expected %q but received %q", testName, expected, content)
+	}
+	if fi, _ := os.Stat(fileName); fi.Mode().Perm() != 0600 {
+		t.Fatalf("%s failed: permissions of the replaced file must be kept, received %s", testName, fi.Mode())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(fileName)); len(entries) != 1 {
+		t.Fatalf("%s failed: temporary files must be removed, received %d files", testName, len(entries))
+	}
+}
+
+func TestStmtExport_unsupportedWithOpts(t *testing.T) {
+	testName := "TestStmtExport_unsupportedWithOpts"
+	stub := _newHooksStub()
+	defer stub.Close()
+	db := _openDbWithHooks(t, stub.URL)
+	fileName := filepath.Join(t.TempDir(), "out.jsonl")
+	for _, opts := range []string{"PARALLEL=2", "RAW_ITEM=true"} {
+		query := `SELECT * FROM tbl WITH ` + opts
+		if _, err := db.Exec(`EXPORT (` + query + `) TO '` + fileName + `' FORMAT JSONL`); err == nil || !strings.Contains(err.Error(), "is not supported by EXPORT") {
+			t.Fatalf("%s failed: expected WITH %s to be rejected but received %#v", testName, opts, err)
+		}
+		if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+			t.Fatalf("%s failed: rejected EXPORT must not create the file", testName)
+		}
+		if _, err := Export(context.Background(), db, query, &bytes.Buffer{}, ExportFormatJsonl); err == nil || !strings.Contains(err.Error(), "is not supported by EXPORT") {
+			t.Fatalf("%s failed: expected WITH %s to be rejected but received %#v", testName, opts, err)
+		}
+	}
+}
+
+// _newSlowPagesStub returns a stub DynamoDB endpoint that serves SELECT statements with numPages pages, each one after
+// delay.
+func _newSlowPagesStub(numPages int, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		page := 1
+		if token, ok := input["NextToken"].(string); ok {
+			page = len(token) + 1
+		}
+		output := map[string]interface{}{"Items": []interface{}{map[string]interface{}{"id": map[string]string{"S": strings.Repeat("p", page)}}}}
+		if page < numPages {
+			output["NextToken"] = strings.Repeat("p", page)
+		}
+		_ = json.NewEncoder(w).Encode(output)
+	}))
+}
+
+func TestStmtExport_timeout(t *testing.T) {
+	testName := "TestStmtExport_timeout"
+	stub := _newSlowPagesStub(3, 150*time.Millisecond)
+	defer stub.Close()
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;TimeoutMs=250;Endpoint="+stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+	fileName := filepath.Join(t.TempDir(), "out.jsonl")
+
+	// the connection's timeout bounds each request, not the whole export
+	result, err := db.Exec(`EXPORT (SELECT * FROM tbl) TO '` + fileName + `' FORMAT JSONL`)
+	if err != nil {
+		t.Fatalf("%s failed: export must not be bounded by the connection's timeout: %s", testName, err)
+	}
+	if numItems, _ := result.RowsAffected(); numItems != 3 {
+		t.Fatalf("%s failed: expected 3 exported items but received %d", testName, numItems)
+	}
+
+	// clause WITH TIMEOUT and the caller's context bound the whole export
+	if _, err = db.Exec(`EXPORT (SELECT * FROM tbl) TO '` + fileName + `' FORMAT JSONL WITH TIMEOUT=200`); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("%s failed: expected context.DeadlineExceeded but received %#v", testName, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err = db.ExecContext(ctx, `EXPORT (SELECT * FROM tbl) TO '`+fileName+`' FORMAT JSONL`); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("%s failed: expected context.DeadlineExceeded but received %#v", testName, err)
+	}
+
+	// the same rules apply to function Export
+	buf := &bytes.Buffer{}
+	if numItems, err := Export(context.Background(), db, `SELECT * FROM tbl`, buf, ExportFormatJsonl); err != nil || numItems != 3 {
+		t.Fatalf("%s failed: expected 3 exported items but received %d / %s", testName, numItems, err)
+	}
+	if _, err = Export(context.Background(), db, `SELECT * FROM tbl WITH TIMEOUT=200`, buf, ExportFormatJsonl); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("%s failed: expected context.DeadlineExceeded but received %#v", testName, err)
+	}
+}
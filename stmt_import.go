@@ -0,0 +1,615 @@
+package godynamo
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// ImportFormatJsonl imports each line as a "plain" JSON object (JSON Lines), see ExportFormatJsonl.
+	//
+	// @Available since v1.4.0
+	ImportFormatJsonl = ExportFormatJsonl
+
+	// ImportFormatCsv imports rows of a CSV file; the first row is the header containing attribute names.
+	// Empty cells are skipped, other cells are imported as strings unless a type hint is specified.
+	//
+	// @Available since v1.4.0
+	ImportFormatCsv = ExportFormatCsv
+
+	// ImportFormatDDBJson imports each line as typed "DynamoDB JSON", either wrapped in an "Item" object
+	// (e.g. {"Item":{"id":{"S":"1"}}}, as produced by ExportFormatDDBJson) or not (e.g. {"id":{"S":"1"}}).
+	//
+	// @Available since v1.4.0
+	ImportFormatDDBJson = ExportFormatDDBJson
+
+	// DefaultImportWorkers is the default number of parallel BatchWriteItem workers used by Import.
+	//
+	// @Available since v1.4.0
+	DefaultImportWorkers = 4
+
+	// DefaultImportMaxRetries is the default number of times Import retries unprocessed items.
+	//
+	// @Available since v1.4.0
+	DefaultImportMaxRetries = 10
+
+	// maxBatchWriteItems is the maximum number of items in a BatchWriteItem request.
+	maxBatchWriteItems = 25
+)
+
+// ImportOptions customizes the behavior of Import.
+//
+// @Available since v1.4.0
+type ImportOptions struct {
+	// Workers is the number of parallel BatchWriteItem workers, default value is DefaultImportWorkers.
+	Workers int
+
+	// BatchSize is the number of items per BatchWriteItem request, must not exceed 25 (the default value).
+	BatchSize int
+
+	// MaxRetries is the number of times unprocessed items and throttled batches are retried (with exponential backoff)
+	// before being rejected, default value is DefaultImportMaxRetries.
+	MaxRetries int
+
+	// TypeHints maps attribute names to DynamoDB JSON type descriptors (S, N, B, BOOL, NULL, SS, NS, BS, L or M).
+	// It is used by JSONL and CSV formats to convert attribute values, e.g. {"score": "N"} imports the CSV cell "10" as
+	// a number, and {"tags": "SS"} imports the JSON array ["a","b"] (or the CSV cell `["a","b"]`) as a string set.
+	TypeHints map[string]string
+
+	// Progress, if not nil, is called after each batch is written. Calls are serialized.
+	Progress func(progress ImportProgress)
+}
+
+// ImportProgress reports the progress of an Import.
+//
+// @Available since v1.4.0
+type ImportProgress struct {
+	Read     int64 // number of rows read from the input so far
+	Imported int64 // number of items written to the table so far
+	Rejected int64 // number of rows rejected so far
+}
+
+// ImportRejectedRow describes a row that could not be imported.
+//
+// @Available since v1.4.0
+type ImportRejectedRow struct {
+	Line  int64  // line number of the row in the input (for CSV: record number, header excluded, starting from 1)
+	Data  string // the raw content of the row
+	Error string // the reason why the row was rejected
+}
+
+// ImportResult is returned by Import.
+//
+// @Available since v1.4.0
+type ImportResult struct {
+	Read     int64               // number of rows read from the input
+	Imported int64               // number of items written to the table
+	Rejected []ImportRejectedRow // rows that could not be imported
+}
+
+// WriteRejectedReport writes the rejected rows to w, one JSON object per line.
+func (r *ImportResult) WriteRejectedReport(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, row := range r.Rejected {
+		if err := encoder.Encode(map[string]interface{}{"line": row.Line, "data": row.Data, "error": row.Error}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importRow is a parsed row pending to be written to the table.
+type importRow struct {
+	line int64
+	data string
+	item map[string]types.AttributeValue
+}
+
+// Import reads items from r in the specified format (ImportFormatJsonl, ImportFormatCsv or ImportFormatDDBJson) and
+// writes them to the table using parallel BatchWriteItem requests.
+//
+//   - Rows that cannot be parsed, or that are rejected by DynamoDB (e.g. missing key attributes), do not stop the import;
+//     they are reported in ImportResult.Rejected.
+//   - Unprocessed items returned by BatchWriteItem, and batches throttled by DynamoDB, are retried with exponential
+//     backoff, up to opts.MaxRetries times.
+//   - An error is returned if the input cannot be read or the import is canceled, along with the partial result.
+//   - The table name is prefixed with the TablePrefix of the connection, if any.
+//   - The import is reported to Hooks as a statement of kind "IMPORT".
+//
+// Example:
+//
+//	f, _ := os.Open("session.jsonl")
+//	defer f.Close()
+//	result, err := godynamo.Import(ctx, db, "session", f, godynamo.ImportFormatJsonl, &godynamo.ImportOptions{Workers: 8})
+//
+// @Available since v1.4.0
+func Import(ctx context.Context, db *sql.DB, table string, r io.Reader, format string, opts *ImportOptions) (*ImportResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	var result *ImportResult
+	err = conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*Conn)
+		if !ok {
+			return fmt.Errorf("expected *godynamo.Conn but received %T", driverConn)
+		}
+		return c.withStatementHooks(ctx, &StatementInfo{Kind: "IMPORT", Table: table}, func(ctx context.Context) (int64, error) {
+			var err error
+			result, err = c.importContext(ctx, c.physicalTableName(table), r, format, opts)
+			if result == nil {
+				return 0, err
+			}
+			return result.Imported, err
+		})
+	})
+	return result, err
+}
+
+// importer holds the state of an in-progress import.
+type importer struct {
+	conn     *Conn
+	table    string
+	opts     ImportOptions
+	lock     sync.Mutex
+	result   *ImportResult
+	consumed float64
+}
+
+func (imp *importer) reject(row importRow, err error) {
+	imp.lock.Lock()
+	defer imp.lock.Unlock()
+	imp.result.Rejected = append(imp.result.Rejected, ImportRejectedRow{Line: row.line, Data: row.data, Error: err.Error()})
+}
+
+func (imp *importer) report(read, imported int64, consumed float64) {
+	imp.lock.Lock()
+	defer imp.lock.Unlock()
+	imp.result.Read += read
+	imp.result.Imported += imported
+	imp.consumed += consumed
+	if imp.opts.Progress != nil {
+		imp.opts.Progress(ImportProgress{Read: imp.result.Read, Imported: imp.result.Imported, Rejected: int64(len(imp.result.Rejected))})
+	}
+}
+
+// importContext reads items from r and writes them to the table.
+func (c *Conn) importContext(ctx context.Context, table string, r io.Reader, format string, opts *ImportOptions) (*ImportResult, error) {
+	if err := c.checkWritable(fmt.Sprintf("IMPORT INTO %s", table)); err != nil {
+		return nil, err
+	}
+	format, err := normalizeExportFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if c.txMode != txNone {
+		return nil, ErrInTx
+	}
+	if table == "" {
+		return nil, errors.New("table name is missing")
+	}
+	imp := &importer{conn: c, table: table, result: &ImportResult{Rejected: make([]ImportRejectedRow, 0)}}
+	if opts != nil {
+		imp.opts = *opts
+	}
+	if imp.opts.Workers <= 0 {
+		imp.opts.Workers = DefaultImportWorkers
+	}
+	if imp.opts.BatchSize <= 0 || imp.opts.BatchSize > maxBatchWriteItems {
+		imp.opts.BatchSize = maxBatchWriteItems
+	}
+	if imp.opts.MaxRetries <= 0 {
+		imp.opts.MaxRetries = DefaultImportMaxRetries
+	}
+	for attr, hint := range imp.opts.TypeHints {
+		if _, ok := attributeTypes[strings.ToUpper(hint)]; !ok {
+			return nil, fmt.Errorf("invalid type hint <%s> for attribute <%s>", hint, attr)
+		}
+	}
+	c.lastConsumedCapacity = 0
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	batches := make(chan []importRow)
+	wg := sync.WaitGroup{}
+	for i := 0; i < imp.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				imp.writeBatch(ctx, batch)
+			}
+		}()
+	}
+
+	batch := make([]importRow, 0, imp.opts.BatchSize)
+	numParsed := int64(0)
+	readErr := imp.readRows(r, format, func(row importRow, err error) error {
+		if err != nil {
+			imp.reject(row, err)
+			imp.report(1, 0, 0)
+			return nil
+		}
+		batch = append(batch, row)
+		numParsed++
+		if len(batch) >= imp.opts.BatchSize {
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			batch = make([]importRow, 0, imp.opts.BatchSize)
+		}
+		return nil
+	})
+	if readErr == nil && len(batch) > 0 {
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			readErr = ctx.Err()
+		}
+	}
+	close(batches)
+	wg.Wait()
+	c.lastConsumedCapacity = imp.consumed
+	if readErr == nil {
+		readErr = ctx.Err()
+	}
+	return imp.result, readErr
+}
+
+// readRows parses rows from r and calls f for each of them, with a non-nil error if the row cannot be parsed.
+func (imp *importer) readRows(r io.Reader, format string, f func(row importRow, err error) error) error {
+	if format == ImportFormatCsv {
+		return imp.readCsvRows(r, f)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // DynamoDB items are limited to 400KB, this leaves plenty of room
+	for line := int64(1); scanner.Scan(); line++ {
+		data := strings.TrimSpace(scanner.Text())
+		if data == "" {
+			continue
+		}
+		row := importRow{line: line, data: data}
+		var err error
+		if format == ImportFormatDDBJson {
+			row.item, err = parseDDBJsonRow(data)
+		} else {
+			row.item, err = parseJsonlRow(data, imp.opts.TypeHints)
+		}
+		if err = f(row, err); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (imp *importer) readCsvRows(r io.Reader, f func(row importRow, err error) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for line := int64(1); ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		row := importRow{line: line, data: strings.Join(record, ",")}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return err
+			}
+		} else {
+			row.item, err = parseCsvRow(header, record, imp.opts.TypeHints)
+		}
+		if err = f(row, err); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeJson(data string) (interface{}, error) {
+	var v interface{}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&v)
+	return v, err
+}
+
+func parseJsonlRow(data string, typeHints map[string]string) (map[string]types.AttributeValue, error) {
+	v, err := decodeJson(data)
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("row is not a JSON object")
+	}
+	item := make(map[string]types.AttributeValue, len(doc))
+	for k, v := range doc {
+		if item[k], err = fromPlainJson(v, strings.ToUpper(typeHints[k])); err != nil {
+			return nil, fmt.Errorf("attribute <%s>: %s", k, err)
+		}
+	}
+	return item, nil
+}
+
+func parseDDBJsonRow(data string) (map[string]types.AttributeValue, error) {
+	v, err := decodeJson(data)
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("row is not a JSON object")
+	}
+	if wrapped, ok := doc["Item"].(map[string]interface{}); ok && len(doc) == 1 {
+		// {"Item":{...}} is the wrapped form, unless "Item" is an attribute whose value is a typed value such as {"M":{...}}
+		if _, err := fromDynamoDBJson(wrapped); err != nil {
+			doc = wrapped
+		}
+	}
+	return itemFromDynamoDBJson(doc)
+}
+
+func parseCsvRow(header, record []string, typeHints map[string]string) (map[string]types.AttributeValue, error) {
+	if len(record) > len(header) {
+		return nil, fmt.Errorf("row has %d fields but header has only %d", len(record), len(header))
+	}
+	item := make(map[string]types.AttributeValue, len(record))
+	for i, cell := range record {
+		if cell == "" {
+			continue
+		}
+		var err error
+		if item[header[i]], err = fromPlainJson(cell, strings.ToUpper(typeHints[header[i]])); err != nil {
+			return nil, fmt.Errorf("attribute <%s>: %s", header[i], err)
+		}
+	}
+	return item, nil
+}
+
+// writeBatch writes a batch of rows using BatchWriteItem, retrying unprocessed items and throttled requests with
+// exponential backoff. If the request is rejected as invalid as a whole (ValidationException, e.g. an item misses key
+// attributes), the rows are written one by one to find out which ones are invalid.
+func (imp *importer) writeBatch(ctx context.Context, batch []importRow) {
+	pending := make(map[string][]types.WriteRequest)
+	for _, row := range batch {
+		pending[imp.table] = append(pending[imp.table], types.WriteRequest{PutRequest: &types.PutRequest{Item: row.item}})
+	}
+	consumed := 0.0
+	backoff := 50 * time.Millisecond
+	for retry := 0; ; retry++ {
+		output, err := imp.conn.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems:           pending,
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		})
+		if err != nil {
+			err = wrapAwsError(err, nil)
+			if ctx.Err() != nil {
+				imp.report(int64(len(batch)), int64(len(batch)-len(pending[imp.table])), consumed)
+				return
+			}
+			if retry == 0 && errors.Is(err, ErrValidation) {
+				imp.writeRows(ctx, batch)
+				return
+			}
+			if !errors.Is(err, ErrThrottled) || retry >= imp.opts.MaxRetries {
+				// items written by previous attempts are kept, the remaining ones are rejected
+				imp.rejectUnprocessed(batch, pending[imp.table], err)
+				imp.report(int64(len(batch)), int64(len(batch)-len(pending[imp.table])), consumed)
+				return
+			}
+		} else {
+			for _, cc := range output.ConsumedCapacity {
+				consumed += capacityUnits(&cc)
+			}
+			if len(output.UnprocessedItems[imp.table]) == 0 {
+				imp.report(int64(len(batch)), int64(len(batch)), consumed)
+				return
+			}
+			pending = output.UnprocessedItems
+			if retry >= imp.opts.MaxRetries {
+				imp.rejectUnprocessed(batch, pending[imp.table], fmt.Errorf("item is still unprocessed after %d retries", retry))
+				imp.report(int64(len(batch)), int64(len(batch)-len(pending[imp.table])), consumed)
+				return
+			}
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			imp.report(int64(len(batch)), int64(len(batch)-len(pending[imp.table])), consumed)
+			return
+		}
+		if backoff *= 2; backoff > 5*time.Second {
+			backoff = 5 * time.Second
+		}
+	}
+}
+
+// writeRows writes rows one by one using PutItem, rejecting the ones that fail.
+func (imp *importer) writeRows(ctx context.Context, batch []importRow) {
+	numImported := int64(0)
+	consumed := 0.0
+	for _, row := range batch {
+		output, err := imp.conn.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:              aws.String(imp.table),
+			Item:                   row.item,
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		})
+		if err != nil {
+			imp.reject(row, wrapAwsError(err, nil))
+			continue
+		}
+		consumed += capacityUnits(output.ConsumedCapacity)
+		numImported++
+	}
+	imp.report(int64(len(batch)), numImported, consumed)
+}
+
+// rejectUnprocessed rejects the rows whose items are in the unprocessed list.
+func (imp *importer) rejectUnprocessed(batch []importRow, unprocessed []types.WriteRequest, err error) {
+	for _, req := range unprocessed {
+		for _, row := range batch {
+			if req.PutRequest != nil && sameItem(req.PutRequest.Item, row.item) {
+				imp.reject(row, err)
+				break
+			}
+		}
+	}
+}
+
+func sameItem(a, b map[string]types.AttributeValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || toText(v) != toText(bv) || nameFromAttributeValue(v) != nameFromAttributeValue(bv) {
+			return false
+		}
+	}
+	return true
+}
+
+/*----------------------------------------------------------------------*/
+
+// StmtImport implements "IMPORT" statement.
+//
+// Syntax:
+//
+//	IMPORT INTO <table-name> FROM '<file-name>' FORMAT JSONL|CSV|DDBJSON
+//	[WITH WORKERS=<num>][,] [WITH TYPE=<attr-name>:<type>][,] [WITH REJECTED_FILE=<report-file-name>]
+//
+//	- table-name: the table to import items into.
+//	- file-name: the file to read items from.
+//	- FORMAT: see ImportFormatJsonl, ImportFormatCsv and ImportFormatDDBJson.
+//	- WORKERS: number of parallel BatchWriteItem workers, default value is DefaultImportWorkers.
+//	- TYPE: type hint for an attribute, e.g. WITH TYPE=score:N, WITH TYPE=tags:SS (see ImportOptions.TypeHints). Can be specified multiple times.
+//	- REJECTED_FILE: if specified, rejected rows are written to this file, one JSON object per line (see ImportResult.WriteRejectedReport).
+//	- RowsAffected() returns the number of imported items.
+//
+// The statement is not bounded by the connection's timeout (DSN option TimeoutMs), which bounds each request sent to
+// DynamoDB instead; use clause WITH TIMEOUT=<ms> or the caller's context to bound the whole import.
+//
+// @Available since v1.4.0
+type StmtImport struct {
+	*Stmt
+	tableName   string
+	fileName    string
+	format      string
+	withOptsStr string
+	opts        ImportOptions
+}
+
+func (s *StmtImport) parse() error {
+	if err := s.Stmt.parseWithOpts(s.withOptsStr); err != nil {
+		return err
+	}
+	if _, ok := s.withOpts["WORKERS"]; ok {
+		workers, err := strconv.Atoi(s.withOpts["WORKERS"].FirstString())
+		if err != nil || workers <= 0 {
+			return fmt.Errorf("invalid value for WORKERS: %s", s.withOpts["WORKERS"].FirstString())
+		}
+		s.opts.Workers = workers
+	}
+	for _, hint := range s.withOpts["TYPE"] {
+		tokens := strings.SplitN(hint, ":", 2)
+		if len(tokens) != 2 || tokens[0] == "" {
+			return fmt.Errorf("invalid value for TYPE: %s, expected <attr-name>:<type>", hint)
+		}
+		if s.opts.TypeHints == nil {
+			s.opts.TypeHints = make(map[string]string)
+		}
+		s.opts.TypeHints[tokens[0]] = strings.ToUpper(tokens[1])
+	}
+	return nil
+}
+
+func (s *StmtImport) validate() error {
+	if s.tableName == "" {
+		return errors.New("table name is missing")
+	}
+	if s.fileName == "" {
+		return errors.New("file name is missing")
+	}
+	if _, err := normalizeExportFormat(s.format); err != nil {
+		return err
+	}
+	for attr, hint := range s.opts.TypeHints {
+		if _, ok := attributeTypes[hint]; !ok {
+			return fmt.Errorf("invalid type hint <%s> for attribute <%s>", hint, attr)
+		}
+	}
+	return nil
+}
+
+// Query implements driver.Stmt/Query.
+// This function is not implemented, use Exec instead.
+func (s *StmtImport) Query(_ []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("this operation is not supported, please use Exec")
+}
+
+// QueryContext implements driver.StmtQueryContext/QueryContext.
+// This function is not implemented, use ExecContext instead.
+func (s *StmtImport) QueryContext(_ context.Context, _ []driver.NamedValue) (driver.Rows, error) {
+	return nil, errors.New("this operation is not supported, please use ExecContext")
+}
+
+// Exec implements driver.Stmt/Exec.
+func (s *StmtImport) Exec(values []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), ValuesToNamedValues(values))
+}
+
+// ExecContext implements driver.StmtExecContext/ExecContext.
+func (s *StmtImport) ExecContext(ctx context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	if err := s.checkWritable(); err != nil {
+		return &ResultNoResultSet{err: err}, err
+	}
+	ctx, cancel := s.newLongRunningContext(ctx)
+	defer cancel()
+	f, err := os.Open(s.fileName)
+	if err != nil {
+		return &ResultNoResultSet{err: err}, err
+	}
+	defer func() { _ = f.Close() }()
+	result, err := s.conn.importContext(ctx, s.tableName, f, s.format, &s.opts)
+	if result == nil {
+		return &ResultNoResultSet{err: err}, err
+	}
+	if reportFile := s.withOpts["REJECTED_FILE"].FirstString(); reportFile != "" && err == nil {
+		var report *os.File
+		if report, err = os.Create(reportFile); err == nil {
+			err = result.WriteRejectedReport(report)
+			if closeErr := report.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	return &ResultNoResultSet{err: err, affectedRows: result.Imported}, err
+}
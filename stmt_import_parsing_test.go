@@ -0,0 +1,322 @@
+package godynamo
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func Test_Stmt_Import_parse(t *testing.T) {
+	testName := "Test_Stmt_Import_parse"
+	testData := []struct {
+		name      string
+		sql       string
+		tableName string
+		fileName  string
+		format    string
+		workers   int
+		typeHints map[string]string
+		mustError bool
+	}{
+		{name: "basic", sql: `IMPORT INTO tbl FROM 'in.jsonl' FORMAT jsonl`, tableName: "tbl", fileName: "in.jsonl", format: "JSONL"},
+		{name: "quoted_table", sql: `IMPORT INTO "tbl" FROM '/tmp/in.csv' FORMAT CSV`, tableName: "tbl", fileName: "/tmp/in.csv", format: "CSV"},
+		{name: "with_opts", sql: "IMPORT INTO tbl FROM 'in.csv' FORMAT CSV WITH WORKERS=8, WITH TYPE=score:n\nWITH TYPE=tags:SS WITH REJECTED_FILE=rejected.jsonl", tableName: "tbl", fileName: "in.csv", format: "CSV", workers: 8, typeHints: map[string]string{"score": "N", "tags": "SS"}},
+		{name: "ddbjson", sql: `IMPORT INTO tbl FROM 'in.json' FORMAT DDBJSON`, tableName: "tbl", fileName: "in.json", format: "DDBJSON"},
+
+		{name: "invalid_format", sql: `IMPORT INTO tbl FROM 'in.xml' FORMAT XML`, mustError: true},
+		{name: "no_file", sql: `IMPORT INTO tbl FROM '' FORMAT CSV`, mustError: true},
+		{name: "invalid_workers", sql: `IMPORT INTO tbl FROM 'in.csv' FORMAT CSV WITH WORKERS=0`, mustError: true},
+		{name: "invalid_type_hint", sql: `IMPORT INTO tbl FROM 'in.csv' FORMAT CSV WITH TYPE=score:X`, mustError: true},
+		{name: "invalid_type_hint_syntax", sql: `IMPORT INTO tbl FROM 'in.csv' FORMAT CSV WITH TYPE=score`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(nil, testCase.sql)
+			if testCase.mustError && err == nil {
+				t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+			}
+			if testCase.mustError {
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			stmt, ok := s.(*StmtImport)
+			if !ok {
+				t.Fatalf("%s failed: expected StmtImport but received %T", testName+"/"+testCase.name, s)
+			}
+			if stmt.tableName != testCase.tableName || stmt.fileName != testCase.fileName || stmt.format != testCase.format || stmt.opts.Workers != testCase.workers {
+				t.Fatalf("%s failed: unexpected parsing result %#v", testName+"/"+testCase.name, stmt)
+			}
+			if !reflect.DeepEqual(stmt.opts.TypeHints, testCase.typeHints) {
+				t.Fatalf("%s failed: expected type hints %#v but received %#v", testName+"/"+testCase.name, testCase.typeHints, stmt.opts.TypeHints)
+			}
+		})
+	}
+}
+
+func Test_ImportRows(t *testing.T) {
+	testName := "Test_ImportRows"
+	expected := map[string]types.AttributeValue{
+		"id":    &types.AttributeValueMemberS{Value: "1"},
+		"big":   &types.AttributeValueMemberN{Value: "12345678901234567890"},
+		"tags":  &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"nums":  &types.AttributeValueMemberNS{Value: []string{"1", "2.5"}},
+		"flag":  &types.AttributeValueMemberBOOL{Value: true},
+		"doc":   &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"n": &types.AttributeValueMemberN{Value: "1"}}},
+		"list":  &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "x"}}},
+		"bin":   &types.AttributeValueMemberB{Value: []byte("hi")},
+		"empty": &types.AttributeValueMemberNULL{Value: true},
+	}
+	typeHints := map[string]string{"tags": "SS", "nums": "NS", "bin": "B", "big": "N", "flag": "BOOL", "doc": "M", "list": "L", "empty": "NULL"}
+
+	// DDBJSON rows produced by export can be imported back
+	js, _ := json.Marshal(map[string]interface{}{"Item": itemToDynamoDBJson(expected)})
+	item, err := parseDDBJsonRow(string(js))
+	if err != nil || !reflect.DeepEqual(item, expected) {
+		t.Fatalf("%s failed: %s / %#v", testName+"/ddbjson_wrapped", err, item)
+	}
+	js, _ = json.Marshal(itemToDynamoDBJson(expected))
+	item, err = parseDDBJsonRow(string(js))
+	if err != nil || !reflect.DeepEqual(item, expected) {
+		t.Fatalf("%s failed: %s / %#v", testName+"/ddbjson", err, item)
+	}
+	item, err = parseDDBJsonRow(`{"Item":{"M":{"a":{"S":"b"}}}}`)
+	if err != nil || nameFromAttributeValue(item["Item"]) != "M" {
+		t.Fatalf("%s failed: attribute named Item must not be unwrapped %s / %#v", testName+"/ddbjson_item_attr", err, item)
+	}
+
+	// JSONL with type hints
+	item, err = parseJsonlRow(`{"id":"1","big":12345678901234567890,"tags":["a","b"],"nums":[1,"2.5"],"flag":true,"doc":{"n":1},"list":["x"],"bin":"aGk=","empty":null}`, typeHints)
+	if err != nil || !reflect.DeepEqual(item, expected) {
+		t.Fatalf("%s failed: %s / %#v", testName+"/jsonl", err, item)
+	}
+	item, err = parseJsonlRow(`{"id":"1","num":10}`, nil)
+	if err != nil || !reflect.DeepEqual(item["num"], &types.AttributeValueMemberN{Value: "10"}) {
+		t.Fatalf("%s failed: %s / %#v", testName+"/jsonl_no_hints", err, item)
+	}
+
+	// CSV with type hints
+	header := []string{"id", "big", "tags", "nums", "flag", "doc", "list", "bin", "empty", "skipped"}
+	record := []string{"1", "12345678901234567890", `["a","b"]`, `[1,2.5]`, "true", `{"n":1}`, `["x"]`, "aGk=", "null", ""}
+	item, err = parseCsvRow(header, record, typeHints)
+	if err != nil || !reflect.DeepEqual(item, expected) {
+		t.Fatalf("%s failed: %s / %#v", testName+"/csv", err, item)
+	}
+
+	// invalid rows
+	invalidRows := []struct {
+		name string
+		f    func() error
+	}{
+		{name: "jsonl_invalid_json", f: func() error { _, err := parseJsonlRow(`{"id":`, nil); return err }},
+		{name: "jsonl_not_object", f: func() error { _, err := parseJsonlRow(`[1,2]`, nil); return err }},
+		{name: "jsonl_invalid_number", f: func() error { _, err := parseJsonlRow(`{"n":"abc"}`, map[string]string{"n": "N"}); return err }},
+		{name: "ddbjson_invalid_type", f: func() error { _, err := parseDDBJsonRow(`{"id":{"X":"1"}}`); return err }},
+		{name: "ddbjson_untyped", f: func() error { _, err := parseDDBJsonRow(`{"id":"1"}`); return err }},
+		{name: "csv_invalid_bool", f: func() error {
+			_, err := parseCsvRow([]string{"b"}, []string{"maybe"}, map[string]string{"b": "BOOL"})
+			return err
+		}},
+		{name: "csv_too_many_fields", f: func() error { _, err := parseCsvRow([]string{"a"}, []string{"1", "2"}, nil); return err }},
+	}
+	for _, row := range invalidRows {
+		if row.f() == nil {
+			t.Fatalf("%s failed: parsing must fail", testName+"/"+row.name)
+		}
+	}
+}
+
+func Test_ImportReadRows(t *testing.T) {
+	testName := "Test_ImportReadRows"
+	imp := &importer{opts: ImportOptions{TypeHints: map[string]string{"n": "N"}}}
+	input := "id,n\n1,10\n2,abc\n\"3,5\n"
+	var lines []int64
+	var numErrors int
+	err := imp.readRows(strings.NewReader(input), ImportFormatCsv, func(row importRow, err error) error {
+		lines = append(lines, row.line)
+		if err != nil {
+			numErrors++
+		}
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(lines, []int64{1, 2, 3}) || numErrors != 2 {
+		t.Fatalf("%s failed: %s / lines %v / errors %d", testName+"/csv", err, lines, numErrors)
+	}
+
+	lines, numErrors = nil, 0
+	input = "{\"id\":{\"S\":\"1\"}}\n\n{\"id\":1}\n"
+	err = imp.readRows(strings.NewReader(input), ImportFormatDDBJson, func(row importRow, err error) error {
+		lines = append(lines, row.line)
+		if err != nil {
+			numErrors++
+		}
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(lines, []int64{1, 3}) || numErrors != 1 {
+		t.Fatalf("%s failed: %s / lines %v / errors %d", testName+"/ddbjson", err, lines, numErrors)
+	}
+
+	result := &ImportResult{Rejected: []ImportRejectedRow{{Line: 3, Data: `{"id":1}`, Error: "invalid"}}}
+	buf := &bytes.Buffer{}
+	if err := result.WriteRejectedReport(buf); err != nil || buf.String() != `{"data":"{\"id\":1}","error":"invalid","line":3}`+"\n" {
+		t.Fatalf("%s failed: %s / %s", testName+"/report", err, buf.String())
+	}
+}
+
+func TestImport_batchErrors(t *testing.T) {
+	testName := "TestImport_batchErrors"
+	testData := []struct {
+		name          string
+		errType       string // error returned by BatchWriteItem
+		numErrors     int    // number of BatchWriteItem requests failing with errType
+		batchWrites   int
+		putItems      int
+		imported      int64
+		numRejected   int
+		rejectedError string
+	}{
+		// the AWS SDK retries throttled requests 3 times, then the importer backs off and retries the batch
+		{name: "throttled", errType: "ProvisionedThroughputExceededException", numErrors: 3, batchWrites: 4, imported: 2},
+		{name: "throttled_too_long", errType: "ThrottlingException", numErrors: 100, batchWrites: 6, numRejected: 2, rejectedError: "ThrottlingException"},
+		{name: "validation", errType: "ValidationException", numErrors: 1, batchWrites: 1, putItems: 2, imported: 2},
+		{name: "not_found", errType: "ResourceNotFoundException", numErrors: 1, batchWrites: 1, numRejected: 2, rejectedError: "ResourceNotFoundException"},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			var lock sync.Mutex
+			calls := map[string]int{}
+			stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				target := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+				lock.Lock()
+				calls[target]++
+				numCalls := calls[target]
+				lock.Unlock()
+				w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+				if target == "BatchWriteItem" && numCalls <= testCase.numErrors {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#` + testCase.errType + `","message":"failed"}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{}`))
+			}))
+			defer stub.Close()
+			db := _openDbWithHooks(t, stub.URL)
+			result, err := Import(context.Background(), db, "tbl", strings.NewReader("{\"id\":\"1\"}\n{\"id\":\"2\"}\n"), ImportFormatJsonl,
+				&ImportOptions{Workers: 1, MaxRetries: 1})
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if calls["BatchWriteItem"] != testCase.batchWrites || calls["PutItem"] != testCase.putItems {
+				t.Fatalf("%s failed: expected %d BatchWriteItem and %d PutItem requests but received %#v", testName+"/"+testCase.name,
+					testCase.batchWrites, testCase.putItems, calls)
+			}
+			if result.Imported != testCase.imported || len(result.Rejected) != testCase.numRejected {
+				t.Fatalf("%s failed: expected %d imported and %d rejected rows but received %#v", testName+"/"+testCase.name,
+					testCase.imported, testCase.numRejected, result)
+			}
+			for _, row := range result.Rejected {
+				if !strings.Contains(row.Error, testCase.rejectedError) {
+					t.Fatalf("%s failed: expected error %s but received %s", testName+"/"+testCase.name, testCase.rejectedError, row.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestStmtImport_timeout(t *testing.T) {
+	testName := "TestStmtImport_timeout"
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer stub.Close()
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;TimeoutMs=250;Endpoint="+stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+	fileName := filepath.Join(t.TempDir(), "data.jsonl")
+	var data strings.Builder
+	for i := 0; i < 3*maxBatchWriteItems; i++ {
+		data.WriteString(fmt.Sprintf("{\"id\":\"%d\"}\n", i))
+	}
+	if err = os.WriteFile(fileName, []byte(data.String()), 0600); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	// the connection's timeout bounds each BatchWriteItem request, not the whole import
+	result, err := db.Exec(`IMPORT INTO tbl FROM '` + fileName + `' FORMAT JSONL WITH WORKERS=1`)
+	if err != nil {
+		t.Fatalf("%s failed: import must not be bounded by the connection's timeout: %s", testName, err)
+	}
+	if numItems, _ := result.RowsAffected(); numItems != 3*maxBatchWriteItems {
+		t.Fatalf("%s failed: expected %d imported items but received %d", testName, 3*maxBatchWriteItems, numItems)
+	}
+
+	// clause WITH TIMEOUT bounds the whole import
+	if _, err = db.Exec(`IMPORT INTO tbl FROM '` + fileName + `' FORMAT JSONL WITH WORKERS=1 WITH TIMEOUT=200`); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("%s failed: expected context.DeadlineExceeded but received %#v", testName, err)
+	}
+}
+
+func TestImport_readOnly(t *testing.T) {
+	testName := "TestImport_readOnly"
+	numRequests := 0
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer stub.Close()
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;ReadOnly=true;Endpoint="+stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+	if _, err = Import(context.Background(), db, "tbl", strings.NewReader("{\"id\":\"1\"}\n"), ImportFormatJsonl, nil); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("%s failed: expected ErrReadOnly but received %#v", testName, err)
+	}
+	if numRequests != 0 {
+		t.Fatalf("%s failed: read-only connection must not send any request, received %d", testName, numRequests)
+	}
+}
+
+func TestImport_tablePrefix(t *testing.T) {
+	testName := "TestImport_tablePrefix"
+	var tables []string
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			RequestItems map[string]interface{}
+		}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		for table := range input.RequestItems {
+			tables = append(tables, table)
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer stub.Close()
+	db := _openDbWithHooks(t, stub.URL) // TablePrefix=dev_
+	if _, err := Import(context.Background(), db, "tbl", strings.NewReader("{\"id\":\"1\"}\n"), ImportFormatJsonl, &ImportOptions{Workers: 1}); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(tables) != 1 || tables[0] != "dev_tbl" {
+		t.Fatalf("%s failed: expected items to be written to table dev_tbl but received %#v", testName, tables)
+	}
+}
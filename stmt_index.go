@@ -125,17 +125,20 @@ func (s *StmtDescribeLSI) ExecContext(_ context.Context, _ []driver.NamedValue)
 
 // Query implements driver.Stmt/Query.
 func (s *StmtDescribeLSI) Query(_ []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), nil)
+	return s.QueryContext(context.Background(), nil)
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
 //
 // @Available since v0.2.0
 func (s *StmtDescribeLSI) QueryContext(ctx context.Context, _ []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
 	input := &dynamodb.DescribeTableInput{
 		TableName: &s.tableName,
 	}
-	output, err := s.conn.client.DescribeTable(s.conn.ensureContext(ctx), input)
+	output, err := s.conn.client.DescribeTable(ctx, input)
+	err = wrapAwsError(err, nil)
 	result := &RowsDescribeIndex{count: 0}
 	if err == nil {
 		for _, lsi := range output.Table.LocalSecondaryIndexes {
@@ -275,13 +278,18 @@ func (s *StmtCreateGSI) QueryContext(_ context.Context, _ []driver.NamedValue) (
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtCreateGSI) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
 //
 // @Available since v0.2.0
 func (s *StmtCreateGSI) ExecContext(ctx context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	if err := s.checkWritable(); err != nil {
+		return &ResultNoResultSet{err: err}, err
+	}
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
 	attrDefs := make([]types.AttributeDefinition, 0, 2)
 	attrDefs = append(attrDefs, types.AttributeDefinition{AttributeName: &s.pkName, AttributeType: dataTypes[s.pkType]})
 	keySchema := make([]types.KeySchemaElement, 0, 2)
@@ -319,13 +327,15 @@ func (s *StmtCreateGSI) ExecContext(ctx context.Context, _ []driver.NamedValue)
 		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Create: gsiInput}},
 	}
 
-	_, err := s.conn.client.UpdateTable(s.conn.ensureContext(ctx), input)
+	_, err := s.conn.client.UpdateTable(ctx, input)
+	err = wrapCreateIndexError(err)
+	s.conn.tableDescriptions.invalidate(s.tableName)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
 	}
 	if s.ifNotExists && err != nil {
-		if IsAwsError(err, "ResourceInUseException") || strings.Contains(err.Error(), "already exist") {
+		if errors.Is(err, ErrIndexExists) {
 			err = nil
 		}
 	}
@@ -368,17 +378,20 @@ func (s *StmtDescribeGSI) ExecContext(_ context.Context, _ []driver.NamedValue)
 
 // Query implements driver.Stmt/Query.
 func (s *StmtDescribeGSI) Query(_ []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), nil)
+	return s.QueryContext(context.Background(), nil)
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
 //
 // @Available since v0.2.0
 func (s *StmtDescribeGSI) QueryContext(ctx context.Context, _ []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
 	input := &dynamodb.DescribeTableInput{
 		TableName: &s.tableName,
 	}
-	output, err := s.conn.client.DescribeTable(s.conn.ensureContext(ctx), input)
+	output, err := s.conn.client.DescribeTable(ctx, input)
+	err = wrapAwsError(err, nil)
 	result := &RowsDescribeIndex{count: 0}
 	if err == nil {
 		for _, gsi := range output.Table.GlobalSecondaryIndexes {
@@ -473,13 +486,18 @@ func (s *StmtAlterGSI) QueryContext(_ context.Context, _ []driver.NamedValue) (d
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtAlterGSI) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
 //
 // @Available since v0.2.0
 func (s *StmtAlterGSI) ExecContext(ctx context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	if err := s.checkWritable(); err != nil {
+		return &ResultNoResultSet{err: err}, err
+	}
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
 	gsiInput := &types.UpdateGlobalSecondaryIndexAction{
 		IndexName: &s.indexName,
 		ProvisionedThroughput: &types.ProvisionedThroughput{
@@ -492,7 +510,9 @@ func (s *StmtAlterGSI) ExecContext(ctx context.Context, _ []driver.NamedValue) (
 		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Update: gsiInput}},
 	}
 
-	_, err := s.conn.client.UpdateTable(s.conn.ensureContext(ctx), input)
+	_, err := s.conn.client.UpdateTable(ctx, input)
+	err = wrapAwsError(err, nil)
+	s.conn.tableDescriptions.invalidate(s.tableName)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
@@ -540,24 +560,31 @@ func (s *StmtDropGSI) QueryContext(_ context.Context, _ []driver.NamedValue) (dr
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtDropGSI) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
 //
 // @Available since v0.2.0
 func (s *StmtDropGSI) ExecContext(ctx context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	if err := s.checkWritable(); err != nil {
+		return &ResultNoResultSet{err: err}, err
+	}
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
 	gsiInput := &types.DeleteGlobalSecondaryIndexAction{IndexName: &s.indexName}
 	input := &dynamodb.UpdateTableInput{
 		TableName:                   &s.tableName,
 		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Delete: gsiInput}},
 	}
-	_, err := s.conn.client.UpdateTable(s.conn.ensureContext(ctx), input)
+	_, err := s.conn.client.UpdateTable(ctx, input)
+	err = wrapAwsError(err, nil)
+	s.conn.tableDescriptions.invalidate(s.tableName)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
 	}
-	if s.ifExists && IsAwsError(err, "ResourceNotFoundException") {
+	if s.ifExists && errors.Is(err, ErrTableNotFound) {
 		err = nil
 	}
 	return &ResultNoResultSet{err: err, affectedRows: affectedRows}, err
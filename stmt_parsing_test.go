@@ -0,0 +1,285 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_parseQuery_timeout(t *testing.T) {
+	testName := "Test_parseQuery_timeout"
+	testData := []struct {
+		name      string
+		sql       string
+		query     string
+		timeout   time.Duration
+		mustError bool
+	}{
+		{name: "no_timeout", sql: `LIST TABLES`, query: `LIST TABLES`},
+		{name: "list_tables", sql: `LIST TABLES WITH TIMEOUT=500`, query: `LIST TABLES`, timeout: 500 * time.Millisecond},
+		{name: "create_table", sql: `CREATE TABLE demo WITH pk=id:string WITH timeout=30000, WITH rcu=1`, query: `CREATE TABLE demo WITH pk=id:string WITH rcu=1`, timeout: 30 * time.Second},
+		{name: "select", sql: `SELECT * FROM "tbl" WHERE a='WITH TIMEOUT=1' WITH TIMEOUT=20 WITH consistent_read=true`,
+			query: `SELECT * FROM "tbl" WHERE a='WITH TIMEOUT=1'`, timeout: 20 * time.Millisecond},
+		{name: "update", sql: `UPDATE "tbl" SET a=? WHERE id=? WITH Timeout = 100`, query: `UPDATE "tbl" SET a=? WHERE id=? RETURNING ALL OLD *`, timeout: 100 * time.Millisecond},
+		{name: "last_wins", sql: `DROP TABLE demo WITH TIMEOUT=1 WITH TIMEOUT=2`, query: `DROP TABLE demo`, timeout: 2 * time.Millisecond},
+
+		{name: "invalid", sql: `LIST TABLES WITH TIMEOUT=abc`, mustError: true},
+		{name: "zero", sql: `LIST TABLES WITH TIMEOUT=0`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(&Conn{}, testCase.sql)
+			if testCase.mustError {
+				if err == nil {
+					t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			var stmt *Stmt
+			switch v := s.(type) {
+			case *StmtListTables:
+				stmt = v.Stmt
+			case *StmtCreateTable:
+				stmt = v.Stmt
+				if v.rcu == nil || *v.rcu != 1 {
+					t.Fatalf("%s failed: unexpected RCU %#v", testName+"/"+testCase.name, v.rcu)
+				}
+			case *StmtDropTable:
+				stmt = v.Stmt
+			case *StmtSelect:
+				stmt = v.Stmt
+				if !v.withOpts["CONSISTENT_READ"].FirstBool() {
+					t.Fatalf("%s failed: WITH consistent_read must be kept", testName+"/"+testCase.name)
+				}
+			case *StmtUpdate:
+				stmt = v.Stmt
+			}
+			if stmt.query != testCase.query || stmt.timeout != testCase.timeout {
+				t.Fatalf("%s failed: expected %#v/%s but received %#v/%s", testName+"/"+testCase.name, testCase.query, testCase.timeout, stmt.query, stmt.timeout)
+			}
+		})
+	}
+}
+
+func Test_Conn_newContext(t *testing.T) {
+	testName := "Test_Conn_newContext"
+	conn := &Conn{timeout: time.Minute}
+	now := time.Now()
+
+	ctx, cancel := conn.newContext(nil, 0)
+	if deadline, ok := ctx.Deadline(); !ok || deadline.Sub(now) < 59*time.Second {
+		t.Fatalf("%s failed: expected the connection's timeout but received %s/%v", testName+"/conn_timeout", deadline, ok)
+	}
+	cancel()
+	if ctx.Err() == nil {
+		t.Fatalf("%s failed: context must be canceled", testName+"/cancel")
+	}
+
+	ctx, cancel = conn.newContext(context.Background(), time.Second)
+	if deadline, ok := ctx.Deadline(); !ok || deadline.Sub(now) > 2*time.Second {
+		t.Fatalf("%s failed: expected the statement's timeout but received %s/%v", testName+"/stmt_timeout", deadline, ok)
+	}
+	cancel()
+
+	parent, parentCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer parentCancel()
+	ctx, cancel = conn.newContext(parent, 0)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || deadline.Sub(now) > time.Second {
+		t.Fatalf("%s failed: expected the caller's deadline but received %s/%v", testName+"/caller_deadline", deadline, ok)
+	}
+
+	ctx, cancel = (&Conn{}).newContext(context.Background(), 0)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatalf("%s failed: expected no deadline", testName+"/no_timeout")
+	}
+}
+
+func Test_parseQuery_tablePrefix(t *testing.T) {
+	testName := "Test_parseQuery_tablePrefix"
+	conn := &Conn{tablePrefix: "dev_"}
+	testData := []struct {
+		name      string
+		sql       string
+		tableName string
+		query     string
+	}{
+		{name: "create_table", sql: `CREATE TABLE demo WITH pk=id:string`, tableName: "dev_demo"},
+		{name: "describe_table", sql: `DESCRIBE TABLE demo`, tableName: "dev_demo"},
+		{name: "drop_table", sql: `DROP TABLE IF EXISTS demo`, tableName: "dev_demo"},
+		{name: "create_gsi", sql: `CREATE GSI idx ON demo WITH pk=id:string`, tableName: "dev_demo"},
+		{name: "describe_lsi", sql: `DESCRIBE LSI idx ON demo`, tableName: "dev_demo"},
+		{name: "insert", sql: `INSERT INTO "demo" VALUE {'id': 'INSERT INTO demo'}`, query: `INSERT INTO "dev_demo" VALUE {'id': 'INSERT INTO demo'}`},
+		{name: "select", sql: `SELECT "from" FROM demo WHERE id='FROM demo'`, query: `SELECT "from" FROM "dev_demo" WHERE id='FROM demo'`},
+		{name: "select_index", sql: `SELECT * FROM "demo"."idx" WHERE id=?`, query: `SELECT * FROM "dev_demo"."idx" WHERE id=?`},
+		{name: "update", sql: `UPDATE demo SET a=? WHERE id=?`, query: `UPDATE "dev_demo" SET a=? WHERE id=? RETURNING ALL OLD *`},
+		{name: "delete", sql: `DELETE FROM "demo" WHERE id=?`, query: `DELETE FROM "dev_demo" WHERE id=? RETURNING ALL OLD *`},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(conn, testCase.sql)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			var tableName, query string
+			switch v := s.(type) {
+			case *StmtCreateTable:
+				tableName = v.tableName
+			case *StmtDescribeTable:
+				tableName = v.tableName
+			case *StmtDropTable:
+				tableName = v.tableName
+			case *StmtCreateGSI:
+				tableName = v.tableName
+			case *StmtDescribeLSI:
+				tableName = v.tableName
+			case *StmtInsert:
+				query = v.query
+			case *StmtSelect:
+				query = v.query
+			case *StmtUpdate:
+				query = v.query
+			case *StmtDelete:
+				query = v.query
+			}
+			if tableName != testCase.tableName || query != testCase.query {
+				t.Fatalf("%s failed: expected %#v/%#v but received %#v/%#v", testName+"/"+testCase.name, testCase.tableName, testCase.query, tableName, query)
+			}
+		})
+	}
+
+	for physical, expected := range map[string]string{"dev_demo": "demo", "prod_demo": ""} {
+		name, ok := conn.logicalTableName(physical)
+		if ok != (expected != "") || (ok && name != expected) {
+			t.Fatalf("%s failed: expected logical name of %s to be %#v but received %#v/%v", testName, physical, expected, name, ok)
+		}
+	}
+}
+
+func Test_parseQuery_readOnly(t *testing.T) {
+	testName := "Test_parseQuery_readOnly"
+	testData := []struct {
+		sql      string
+		readOnly bool
+	}{
+		{sql: `LIST TABLES`, readOnly: true},
+		{sql: `DESCRIBE TABLE demo`, readOnly: true},
+		{sql: `DESCRIBE LSI idx ON demo`, readOnly: true},
+		{sql: `DESCRIBE GSI idx ON demo`, readOnly: true},
+		{sql: `SELECT * FROM "demo" WHERE id=?`, readOnly: true},
+		{sql: `EXPLAIN SELECT * FROM "demo" WHERE id=?`, readOnly: true},
+		{sql: `EXPORT (SELECT * FROM "demo") TO 'demo.jsonl' FORMAT JSONL`, readOnly: true},
+
+		{sql: `CREATE TABLE demo WITH pk=id:string`},
+		{sql: `ALTER TABLE demo WITH rcu=1`},
+		{sql: `DROP TABLE demo`},
+		{sql: `CREATE GSI idx ON demo WITH pk=id:string`},
+		{sql: `ALTER GSI idx ON demo WITH rcu=1`},
+		{sql: `DROP GSI idx ON demo`},
+		{sql: `IMPORT INTO demo FROM 'demo.jsonl' FORMAT JSONL`},
+		{sql: `INSERT INTO "demo" VALUE {'id': ?}`},
+		{sql: `UPDATE "demo" SET a=? WHERE id=?`},
+		{sql: `DELETE FROM "demo" WHERE id=?`},
+	}
+	conns := map[string]*Conn{
+		"dsn": {readOnly: true},
+		"tx":  {},
+	}
+	conns["tx"].tx = &Tx{conn: conns["tx"], readOnly: true}
+	for connName, conn := range conns {
+		for _, testCase := range testData {
+			_, err := parseQuery(conn, testCase.sql)
+			if testCase.readOnly && err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+connName+"/"+testCase.sql, err)
+			}
+			if !testCase.readOnly && !errors.Is(err, ErrReadOnly) {
+				t.Fatalf("%s failed: expected ErrReadOnly but received %v", testName+"/"+connName+"/"+testCase.sql, err)
+			}
+			if _, err = parseQuery(&Conn{}, testCase.sql); err != nil {
+				t.Fatalf("%s failed: %s", testName+"/read_write/"+testCase.sql, err)
+			}
+		}
+	}
+}
+
+func Test_Stmt_ExecContext_readOnly(t *testing.T) {
+	testName := "Test_Stmt_ExecContext_readOnly"
+	testData := []string{
+		`CREATE TABLE demo WITH pk=id:string`,
+		`ALTER TABLE demo WITH rcu=1`,
+		`DROP TABLE demo`,
+		`CREATE GSI idx ON demo WITH pk=id:string`,
+		`ALTER GSI idx ON demo WITH rcu=1`,
+		`DROP GSI idx ON demo`,
+		`IMPORT INTO demo FROM 'demo.jsonl' FORMAT JSONL`,
+		`INSERT INTO "demo" VALUE {'id': ?}`,
+		`UPDATE "demo" SET a=? WHERE id=?`,
+		`DELETE FROM "demo" WHERE id=?`,
+	}
+	for _, query := range testData {
+		// statements prepared before the read-only transaction started are checked again when executed
+		conn := &Conn{}
+		stmt, err := parseQuery(conn, query)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+query, err)
+		}
+		conn.tx = &Tx{conn: conn, readOnly: true}
+		conn.txMode = txStarted
+		values := make([]driver.NamedValue, stmt.NumInput())
+		for i := range values {
+			values[i] = driver.NamedValue{Ordinal: i + 1, Value: "a"}
+		}
+		if _, err = stmt.(driver.StmtExecContext).ExecContext(context.Background(), values); !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("%s failed: expected ErrReadOnly but received %v", testName+"/"+query, err)
+		}
+		if len(conn.txStmtList) != 0 {
+			t.Fatalf("%s failed: statement must not be added to the read-only transaction", testName+"/"+query)
+		}
+	}
+}
+
+func Test_Tx_Stmt_readOnly(t *testing.T) {
+	testName := "Test_Tx_Stmt_readOnly"
+	var numRequests int32
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequests, 1)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_, _ = w.Write([]byte(`{"Responses":[{}]}`))
+	}))
+	defer stub.Close()
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint="+stub.URL)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+	db.SetMaxOpenConns(1) // tx.Stmt reuses the statement prepared on the same connection
+
+	s, err := db.Prepare(`DELETE FROM "demo" WHERE id=?`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = s.Close() }()
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.Stmt(s).Exec("a"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("%s failed: expected ErrReadOnly but received %v", testName, err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if n := atomic.LoadInt32(&numRequests); n != 0 {
+		t.Fatalf("%s failed: expected no request sent to DynamoDB but received %d", testName, n)
+	}
+}
@@ -171,13 +171,18 @@ func (s *StmtCreateTable) QueryContext(_ context.Context, _ []driver.NamedValue)
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtCreateTable) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/Exec.
 //
 // @Available since v0.2.0
 func (s *StmtCreateTable) ExecContext(ctx context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	if err := s.checkWritable(); err != nil {
+		return &ResultNoResultSet{err: err}, err
+	}
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
 	attrDefs := make([]types.AttributeDefinition, 0, 2)
 	attrDefs = append(attrDefs, types.AttributeDefinition{AttributeName: &s.pkName, AttributeType: dataTypes[s.pkType]})
 	keySchema := make([]types.KeySchemaElement, 0, 2)
@@ -229,12 +234,14 @@ func (s *StmtCreateTable) ExecContext(ctx context.Context, _ []driver.NamedValue
 			WriteCapacityUnits: s.wcu,
 		}
 	}
-	_, err := s.conn.client.CreateTable(s.conn.ensureContext(ctx), input)
+	_, err := s.conn.client.CreateTable(ctx, input)
+	err = wrapAwsError(err, map[string]error{"ResourceInUseException": ErrTableExists})
+	s.conn.tableDescriptions.invalidate(s.tableName)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
 	}
-	if s.ifNotExists && IsAwsError(err, "ResourceInUseException") {
+	if s.ifNotExists && errors.Is(err, ErrTableExists) {
 		err = nil
 	}
 	return &ResultNoResultSet{err: err, affectedRows: affectedRows}, err
@@ -247,6 +254,9 @@ func (s *StmtCreateTable) ExecContext(ctx context.Context, _ []driver.NamedValue
 // Syntax:
 //
 //	LIST TABLES|TABLE
+//
+// @Since v1.4.0 all tables are listed (not only the first page of ListTables). If DSN option TablePrefix is set, only
+// the tables with the prefix are listed, and the prefix is stripped from their names.
 type StmtListTables struct {
 	*Stmt
 }
@@ -269,24 +279,34 @@ func (s *StmtListTables) ExecContext(_ context.Context, _ []driver.NamedValue) (
 
 // Query implements driver.Stmt/Query.
 func (s *StmtListTables) Query(_ []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), nil)
+	return s.QueryContext(context.Background(), nil)
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
 //
 // @Available since v0.2.0
 func (s *StmtListTables) QueryContext(ctx context.Context, _ []driver.NamedValue) (driver.Rows, error) {
-	output, err := s.conn.client.ListTables(s.conn.ensureContext(ctx), &dynamodb.ListTablesInput{})
-	var rows driver.Rows
-	if err == nil {
-		rows = &RowsListTables{
-			count:       len(output.TableNames),
-			tables:      output.TableNames,
-			cursorCount: 0,
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
+	tables := make([]string, 0)
+	paginator := dynamodb.NewListTablesPaginator(s.conn.client, &dynamodb.ListTablesInput{})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, wrapAwsError(err, nil)
+		}
+		for _, tableName := range output.TableNames {
+			if name, ok := s.conn.logicalTableName(tableName); ok {
+				tables = append(tables, name)
+			}
 		}
-		sort.Strings(rows.(*RowsListTables).tables)
 	}
-	return rows, err
+	sort.Strings(tables)
+	return &RowsListTables{
+		count:       len(tables),
+		tables:      tables,
+		cursorCount: 0,
+	}, nil
 }
 
 // RowsListTables captures the result from LIST TABLES statement.
@@ -399,19 +419,24 @@ func (s *StmtAlterTable) Query(_ []driver.Value) (driver.Rows, error) {
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
 // This function is not implemented, use ExecContext instead.
-func (s *StmtAlterTable) QueryContext(_ []driver.NamedValue) (driver.Rows, error) {
+func (s *StmtAlterTable) QueryContext(_ context.Context, _ []driver.NamedValue) (driver.Rows, error) {
 	return nil, errors.New("this operation is not supported, please use ExecContext")
 }
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtAlterTable) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
 //
 // @Available since v0.2.0
 func (s *StmtAlterTable) ExecContext(ctx context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	if err := s.checkWritable(); err != nil {
+		return &ResultNoResultSet{err: err}, err
+	}
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
 	input := &dynamodb.UpdateTableInput{
 		TableName: &s.tableName,
 	}
@@ -429,7 +454,9 @@ func (s *StmtAlterTable) ExecContext(ctx context.Context, _ []driver.NamedValue)
 			}
 		}
 	}
-	_, err := s.conn.client.UpdateTable(s.conn.ensureContext(ctx), input)
+	_, err := s.conn.client.UpdateTable(ctx, input)
+	err = wrapAwsError(err, nil)
+	s.conn.tableDescriptions.invalidate(s.tableName)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
@@ -473,22 +500,29 @@ func (s *StmtDropTable) QueryContext(_ context.Context, _ []driver.NamedValue) (
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtDropTable) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/Exec.
 //
 // @Available since v0.2.0
 func (s *StmtDropTable) ExecContext(ctx context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	if err := s.checkWritable(); err != nil {
+		return &ResultNoResultSet{err: err}, err
+	}
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
 	input := &dynamodb.DeleteTableInput{
 		TableName: &s.tableName,
 	}
-	_, err := s.conn.client.DeleteTable(s.conn.ensureContext(ctx), input)
+	_, err := s.conn.client.DeleteTable(ctx, input)
+	err = wrapAwsError(err, nil)
+	s.conn.tableDescriptions.invalidate(s.tableName)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
 	}
-	if s.ifExists && IsAwsError(err, "ResourceNotFoundException") {
+	if s.ifExists && errors.Is(err, ErrTableNotFound) {
 		err = nil
 	}
 	return &ResultNoResultSet{err: err, affectedRows: affectedRows}, err
@@ -501,6 +535,8 @@ func (s *StmtDropTable) ExecContext(ctx context.Context, _ []driver.NamedValue)
 // Syntax:
 //
 //	DESCRIBE TABLE <table-name>
+//
+// @Since v1.4.0 if DSN option TablePrefix is set, the logical table name (without the prefix) is returned.
 type StmtDescribeTable struct {
 	*Stmt
 	tableName string
@@ -527,20 +563,28 @@ func (s *StmtDescribeTable) ExecContext(_ context.Context, _ []driver.NamedValue
 
 // Query implements driver.Stmt/Query.
 func (s *StmtDescribeTable) Query(_ []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), nil)
+	return s.QueryContext(context.Background(), nil)
 }
 
 // QueryContext implements driver.StmtQueryContext/Query.
 //
 // @Available since v0.2.0
 func (s *StmtDescribeTable) QueryContext(ctx context.Context, _ []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := s.newContext(ctx)
+	defer cancel()
 	input := &dynamodb.DescribeTableInput{
 		TableName: &s.tableName,
 	}
-	output, err := s.conn.client.DescribeTable(s.conn.ensureContext(ctx), input)
+	output, err := s.conn.client.DescribeTable(ctx, input)
+	err = wrapAwsError(err, nil)
 	result := &RowsDescribeTable{count: 0}
 	if err == nil {
 		result.count = 1
+		if output.Table != nil && output.Table.TableName != nil {
+			// show the logical table name
+			name, _ := s.conn.logicalTableName(*output.Table.TableName)
+			output.Table.TableName = &name
+		}
 		js, _ := json.Marshal(output.Table)
 		_ = json.Unmarshal(js, &result.tableInfo)
 
@@ -554,7 +598,7 @@ func (s *StmtDescribeTable) QueryContext(ctx context.Context, _ []driver.NamedVa
 		}
 		sort.Strings(result.columnList)
 	}
-	if IsAwsError(err, "ResourceNotFoundException") {
+	if errors.Is(err, ErrTableNotFound) {
 		err = nil
 	}
 	return result, err
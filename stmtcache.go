@@ -0,0 +1,232 @@
+package godynamo
+
+import (
+	"container/list"
+	"database/sql/driver"
+	"sync"
+)
+
+// DefaultStatementCacheSize is the number of parsed statements cached per DSN if DSN setting StatementCacheSize is not
+// specified.
+//
+// @Available since v1.4.0
+const DefaultStatementCacheSize = 256
+
+// StatementCacheStats holds the statistics of a statement cache, see Conn.StatementCacheStats.
+//
+// @Available since v1.4.0
+type StatementCacheStats struct {
+	Capacity int    // maximum number of cached statements, 0 if the cache is disabled
+	Size     int    // number of cached statements
+	Hits     uint64 // number of statements served from the cache
+	Misses   uint64 // number of statements parsed because they were not found in the cache
+}
+
+// stmtCacheKey identifies a cached statement. UPDATE and DELETE statements are parsed differently inside transactions.
+type stmtCacheKey struct {
+	query string
+	inTx  bool
+}
+
+type stmtCacheEntry struct {
+	key  stmtCacheKey
+	stmt driver.Stmt // the statement template, not bound to any connection
+}
+
+// stmtCache is a LRU cache of parsed statements, keyed by query text.
+// Connections opened with the same DSN settings share the same cache, see dsnCacheKey.
+type stmtCache struct {
+	lock     sync.Mutex
+	capacity int
+	entries  map[stmtCacheKey]*list.Element
+	lru      *list.List // most recently used entries first
+	hits     uint64
+	misses   uint64
+}
+
+var (
+	stmtCachesLock sync.Mutex
+	stmtCaches     = make(map[string]*stmtCache)
+)
+
+// stmtCacheFor returns the statement cache shared by connections whose DSN settings have the key cacheKey (see
+// dsnCacheKey), nil if capacity is 0.
+func stmtCacheFor(cacheKey string, capacity int) *stmtCache {
+	if capacity <= 0 {
+		return nil
+	}
+	stmtCachesLock.Lock()
+	defer stmtCachesLock.Unlock()
+	cache, ok := stmtCaches[cacheKey]
+	if !ok {
+		cache = &stmtCache{capacity: capacity, entries: make(map[stmtCacheKey]*list.Element), lru: list.New()}
+		stmtCaches[cacheKey] = cache
+	}
+	return cache
+}
+
+func (cache *stmtCache) get(key stmtCacheKey) driver.Stmt {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	elem, ok := cache.entries[key]
+	if !ok {
+		cache.misses++
+		return nil
+	}
+	cache.hits++
+	cache.lru.MoveToFront(elem)
+	return elem.Value.(*stmtCacheEntry).stmt
+}
+
+func (cache *stmtCache) put(key stmtCacheKey, stmt driver.Stmt) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if elem, ok := cache.entries[key]; ok {
+		elem.Value.(*stmtCacheEntry).stmt = stmt
+		cache.lru.MoveToFront(elem)
+		return
+	}
+	cache.entries[key] = cache.lru.PushFront(&stmtCacheEntry{key: key, stmt: stmt})
+	for cache.lru.Len() > cache.capacity {
+		oldest := cache.lru.Back()
+		cache.lru.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*stmtCacheEntry).key)
+	}
+}
+
+func (cache *stmtCache) stats() StatementCacheStats {
+	if cache == nil {
+		return StatementCacheStats{}
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	return StatementCacheStats{Capacity: cache.capacity, Size: cache.lru.Len(), Hits: cache.hits, Misses: cache.misses}
+}
+
+// StatementCacheStats returns the statistics of the statement cache shared by connections opened with the same DSN
+// as this connection (see DSN setting StatementCacheSize).
+//
+// Use sql.Conn.Raw to access the underlying *Conn:
+//
+//	conn, _ := db.Conn(ctx)
+//	defer conn.Close()
+//	var stats godynamo.StatementCacheStats
+//	conn.Raw(func(driverConn interface{}) error {
+//		stats = driverConn.(*godynamo.Conn).StatementCacheStats()
+//		return nil
+//	})
+//
+// @Available since v1.4.0
+func (c *Conn) StatementCacheStats() StatementCacheStats {
+	return c.stmtCache.stats()
+}
+
+// parseCachedStatement parses a statement, served from the connection's statement cache if available. Statements
+// served from the cache are cloned and bound to the connection; statements that fail to parse are not cached.
+func parseCachedStatement(c *Conn, query string) (driver.Stmt, error) {
+	if c == nil || c.stmtCache == nil {
+		return parseStatement(c, query)
+	}
+	key := stmtCacheKey{query: query, inTx: c.txMode != txNone}
+	if tmpl := c.stmtCache.get(key); tmpl != nil {
+		return cloneStmt(tmpl, c), nil
+	}
+	stmt, err := parseStatement(c, query)
+	if err == nil {
+		if tmpl := cloneStmt(stmt, nil); tmpl != nil {
+			c.stmtCache.put(key, tmpl)
+		}
+	}
+	return stmt, err
+}
+
+// cloneStmt returns a copy of a parsed statement bound to the connection c, or nil if the statement is not cacheable.
+// Parsed statements are not modified once parsed, except by Stmt.setTimeout, hence their (read-only) fields are
+// shared between the copies.
+func cloneStmt(stmt driver.Stmt, c *Conn) driver.Stmt {
+	switch s := stmt.(type) {
+	case *StmtCreateTable:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		return &clone
+	case *StmtListTables:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		return &clone
+	case *StmtDescribeTable:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		return &clone
+	case *StmtAlterTable:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		return &clone
+	case *StmtDropTable:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		return &clone
+	case *StmtDescribeLSI:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		return &clone
+	case *StmtCreateGSI:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		return &clone
+	case *StmtDescribeGSI:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		return &clone
+	case *StmtAlterGSI:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		return &clone
+	case *StmtDropGSI:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		return &clone
+	case *StmtImport:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		return &clone
+	case *StmtExport:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		clone.selectStmt = cloneStmt(s.selectStmt, c).(*StmtSelect)
+		return &clone
+	case *StmtExplain:
+		clone := *s
+		clone.Stmt = s.Stmt.clone(c)
+		clone.selectStmt = cloneStmt(s.selectStmt, c).(*StmtSelect)
+		return &clone
+	case *StmtInsert:
+		clone := *s
+		clone.StmtExecutable = s.StmtExecutable.clone(c)
+		return &clone
+	case *StmtSelect:
+		clone := *s
+		clone.StmtExecutable = s.StmtExecutable.clone(c)
+		return &clone
+	case *StmtUpdate:
+		clone := *s
+		clone.StmtExecutable = s.StmtExecutable.clone(c)
+		return &clone
+	case *StmtDelete:
+		clone := *s
+		clone.StmtExecutable = s.StmtExecutable.clone(c)
+		return &clone
+	}
+	return nil
+}
+
+func (s *Stmt) clone(c *Conn) *Stmt {
+	clone := *s
+	clone.conn = c
+	return &clone
+}
+
+func (s *StmtExecutable) clone(c *Conn) *StmtExecutable {
+	clone := *s
+	clone.Stmt = s.Stmt.clone(c)
+	return &clone
+}
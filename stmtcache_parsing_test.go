@@ -0,0 +1,197 @@
+package godynamo
+
+import (
+	"container/list"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func _newStmtCache(capacity int) *stmtCache {
+	return &stmtCache{capacity: capacity, entries: make(map[stmtCacheKey]*list.Element), lru: list.New()}
+}
+
+// _stmtOf returns the *Stmt embedded in a statement.
+func _stmtOf(stmt driver.Stmt) *Stmt {
+	return reflect.ValueOf(stmt).Elem().FieldByName("Stmt").Interface().(*Stmt)
+}
+
+func Test_stmtCache_lru(t *testing.T) {
+	testName := "Test_stmtCache_lru"
+	cache := _newStmtCache(2)
+	keyA, keyB, keyC := stmtCacheKey{query: "a"}, stmtCacheKey{query: "b"}, stmtCacheKey{query: "c"}
+	stmtA, stmtB, stmtC := &StmtListTables{}, &StmtListTables{}, &StmtListTables{}
+	cache.put(keyA, stmtA)
+	cache.put(keyB, stmtB)
+	if cache.get(keyA) != stmtA {
+		t.Fatalf("%s failed: expected cached statement <a>", testName)
+	}
+	cache.put(keyC, stmtC)
+	if cache.get(keyB) != nil {
+		t.Fatalf("%s failed: least recently used statement <b> must be evicted", testName)
+	}
+	if cache.get(keyA) != stmtA || cache.get(keyC) != stmtC {
+		t.Fatalf("%s failed: statements <a> and <c> must be cached", testName)
+	}
+	if cache.get(stmtCacheKey{query: "a", inTx: true}) != nil {
+		t.Fatalf("%s failed: statements inside transactions must be cached separately", testName)
+	}
+	expected := StatementCacheStats{Capacity: 2, Size: 2, Hits: 3, Misses: 2}
+	if stats := cache.stats(); stats != expected {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, stats)
+	}
+	if stats := (*stmtCache)(nil).stats(); stats != (StatementCacheStats{}) {
+		t.Fatalf("%s failed: expected empty stats for disabled cache but received %#v", testName, stats)
+	}
+}
+
+func TestParseQuery_stmtCache(t *testing.T) {
+	testName := "TestParseQuery_stmtCache"
+	cache := _newStmtCache(100)
+	conn1 := &Conn{stmtCache: cache, tablePrefix: "dev_"}
+	conn2 := &Conn{stmtCache: cache, tablePrefix: "dev_"}
+	testData := []string{
+		`CREATE TABLE tbl WITH pk=id:string WITH rcu=1 WITH wcu=2`,
+		`LIST TABLES`,
+		`DESCRIBE TABLE tbl`,
+		`ALTER TABLE tbl WITH rcu=3`,
+		`DROP TABLE IF EXISTS tbl`,
+		`DESCRIBE LSI idx ON tbl`,
+		`CREATE GSI idx ON tbl WITH pk=id:string`,
+		`DESCRIBE GSI idx ON tbl`,
+		`ALTER GSI idx ON tbl WITH wcu=4`,
+		`DROP GSI idx ON tbl`,
+		`IMPORT INTO tbl FROM 'data.jsonl' FORMAT JSONL WITH workers=2`,
+		`EXPORT (SELECT * FROM tbl WHERE id=?) TO 'data.csv' FORMAT CSV`,
+		`EXPLAIN SELECT * FROM tbl WHERE id=?`,
+		`INSERT INTO tbl VALUE {'id': ?, 'name': ?}`,
+		`SELECT * FROM tbl WHERE id=? LIMIT 10 WITH consistency=strong`,
+		`UPDATE tbl SET name=? WHERE id=? WITH VERSION=ver`,
+		`DELETE FROM tbl WHERE id=?`,
+	}
+	for _, query := range testData {
+		stmt1, err := parseQuery(conn1, query)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+query, err)
+		}
+		stats := cache.stats()
+		stmt2, err := parseQuery(conn2, query)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName+"/"+query, err)
+		}
+		if cache.stats().Hits != stats.Hits+1 {
+			t.Fatalf("%s failed: statement must be served from the cache", testName+"/"+query)
+		}
+		if reflect.TypeOf(stmt1) != reflect.TypeOf(stmt2) {
+			t.Fatalf("%s failed: expected %T but received %T", testName+"/"+query, stmt1, stmt2)
+		}
+		s1, s2 := _stmtOf(stmt1), _stmtOf(stmt2)
+		if s1 == s2 || s1.conn != conn1 || s2.conn != conn2 {
+			t.Fatalf("%s failed: cached statement must be cloned and bound to the connection", testName+"/"+query)
+		}
+		s2.conn = conn1
+		if !reflect.DeepEqual(stmt1, stmt2) {
+			t.Fatalf("%s failed: expected %#v but received %#v", testName+"/"+query, stmt1, stmt2)
+		}
+	}
+
+	// the nested SELECT statement of EXPORT is cloned too
+	stmt, _ := parseQuery(conn2, testData[11])
+	if selectStmt := stmt.(*StmtExport).selectStmt; selectStmt.conn != conn2 {
+		t.Fatalf("%s failed: nested SELECT statement must be bound to the connection", testName)
+	}
+
+	// WITH TIMEOUT is not part of the cached statement
+	stmt, _ = parseQuery(conn1, `SELECT * FROM tbl WHERE id=? WITH TIMEOUT=100`)
+	if _stmtOf(stmt).timeout != 100*time.Millisecond {
+		t.Fatalf("%s failed: expected timeout 100ms but received %s", testName, _stmtOf(stmt).timeout)
+	}
+	stmt, _ = parseQuery(conn2, `SELECT * FROM tbl WHERE id=?`)
+	if _stmtOf(stmt).timeout != 0 {
+		t.Fatalf("%s failed: timeout of another statement must not leak, received %s", testName, _stmtOf(stmt).timeout)
+	}
+
+	// parsing errors are not cached
+	stats := cache.stats()
+	for i := 0; i < 2; i++ {
+		if _, err := parseQuery(conn1, `SELECT * FROM tbl LIMIT 0`); err == nil {
+			t.Fatalf("%s failed: expected parsing error", testName)
+		}
+	}
+	if newStats := cache.stats(); newStats.Misses != stats.Misses+2 || newStats.Size != stats.Size {
+		t.Fatalf("%s failed: statements failed to parse must not be cached, received %#v", testName, newStats)
+	}
+}
+
+func TestParseQuery_stmtCache_tx(t *testing.T) {
+	testName := "TestParseQuery_stmtCache_tx"
+	// outside transactions, UPDATE/DELETE statements are rewritten with RETURNING ALL OLD * to count affected rows;
+	// inside transactions, they are sent as is
+	testData := []string{`UPDATE tbl SET name=? WHERE id=?`, `DELETE FROM tbl WHERE id=?`}
+	for _, query := range testData {
+		for _, firstInTx := range []bool{false, true} {
+			cache := _newStmtCache(100)
+			conn := &Conn{stmtCache: cache}
+			inTx := firstInTx
+			for i := 0; i < 4; i++ {
+				conn.txMode = txNone
+				if inTx {
+					conn.txMode = txStarted
+				}
+				stmt, err := parseQuery(conn, query)
+				if err != nil {
+					t.Fatalf("%s failed: %s", testName+"/"+query, err)
+				}
+				if returning := strings.Contains(_stmtOf(stmt).query, "RETURNING ALL OLD"); returning == inTx {
+					t.Fatalf("%s failed: statement (in tx: %v) served from the cache with the wrong query %s", testName+"/"+query, inTx, _stmtOf(stmt).query)
+				}
+				inTx = !inTx
+			}
+			if stats := cache.stats(); stats.Size != 2 || stats.Hits != 2 || stats.Misses != 2 {
+				t.Fatalf("%s failed: statements inside and outside transactions must be cached separately, received %#v", testName+"/"+query, stats)
+			}
+		}
+	}
+}
+
+func TestDriver_Open_statementCacheSize(t *testing.T) {
+	testName := "TestDriver_Open_statementCacheSize"
+	d := &Driver{}
+	dsn := "Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint=http://localhost:8000"
+	conn, err := d.Open(dsn)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if capacity := conn.(*Conn).StatementCacheStats().Capacity; capacity != DefaultStatementCacheSize {
+		t.Fatalf("%s failed: expected capacity %d but received %d", testName, DefaultStatementCacheSize, capacity)
+	}
+	conn2, _ := d.Open(dsn)
+	if conn.(*Conn).stmtCache != conn2.(*Conn).stmtCache {
+		t.Fatalf("%s failed: connections opened with the same DSN must share the statement cache", testName)
+	}
+	conn2, _ = d.Open("Endpoint=http://localhost:8000;Region=us-east-1;AkId=id;Secret_Key=rotated")
+	if conn.(*Conn).stmtCache != conn2.(*Conn).stmtCache {
+		t.Fatalf("%s failed: connections opened with the same DSN settings must share the statement cache", testName)
+	}
+	stmtCachesLock.Lock()
+	for key := range stmtCaches {
+		if strings.Contains(key, "secret") {
+			stmtCachesLock.Unlock()
+			t.Fatalf("%s failed: statement caches must not be keyed by secrets: %s", testName, key)
+		}
+	}
+	stmtCachesLock.Unlock()
+	if conn, err = d.Open(dsn + ";Statement_Cache_Size=10"); err != nil || conn.(*Conn).StatementCacheStats().Capacity != 10 {
+		t.Fatalf("%s failed: expected capacity 10 but received %#v / %s", testName, conn, err)
+	}
+	if conn, err = d.Open(dsn + ";StatementCacheSize=0"); err != nil || conn.(*Conn).stmtCache != nil {
+		t.Fatalf("%s failed: statement cache must be disabled but received %#v / %s", testName, conn, err)
+	}
+	for _, value := range []string{"-1", "abc"} {
+		if _, err = d.Open(dsn + ";StatementCacheSize=" + value); err == nil || !strings.Contains(err.Error(), "StatementCacheSize") {
+			t.Fatalf("%s failed: expected error for StatementCacheSize=%s but received %#v", testName, value, err)
+		}
+	}
+}
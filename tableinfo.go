@@ -0,0 +1,267 @@
+package godynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// tableDescriptionTTL is how long a table description is cached before it is fetched again from DynamoDB.
+const tableDescriptionTTL = 5 * time.Minute
+
+type tableDescriptionEntry struct {
+	desc   *types.TableDescription
+	expiry time.Time
+}
+
+// tableDescriptionCache caches table descriptions returned by DescribeTable.
+// Connections opened with the same DSN settings (secrets aside, see dsnCacheKey) share the same cache.
+type tableDescriptionCache struct {
+	lock    sync.RWMutex
+	entries map[string]tableDescriptionEntry
+}
+
+var (
+	tableDescriptionCachesLock sync.Mutex
+	tableDescriptionCaches     = make(map[string]*tableDescriptionCache)
+)
+
+// tableDescriptionCacheFor returns the cache shared by connections whose DSN settings have the key cacheKey, see
+// dsnCacheKey.
+func tableDescriptionCacheFor(cacheKey string) *tableDescriptionCache {
+	tableDescriptionCachesLock.Lock()
+	defer tableDescriptionCachesLock.Unlock()
+	cache, ok := tableDescriptionCaches[cacheKey]
+	if !ok {
+		cache = &tableDescriptionCache{entries: make(map[string]tableDescriptionEntry)}
+		tableDescriptionCaches[cacheKey] = cache
+	}
+	return cache
+}
+
+func (cache *tableDescriptionCache) get(tableName string) *types.TableDescription {
+	if cache == nil {
+		return nil
+	}
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	entry, ok := cache.entries[tableName]
+	if !ok || time.Now().After(entry.expiry) {
+		return nil
+	}
+	return entry.desc
+}
+
+func (cache *tableDescriptionCache) put(tableName string, desc *types.TableDescription) {
+	if cache == nil {
+		return
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.entries[tableName] = tableDescriptionEntry{desc: desc, expiry: time.Now().Add(tableDescriptionTTL)}
+}
+
+// invalidate removes a table description from the cache, it is called when the table is created, altered or dropped.
+func (cache *tableDescriptionCache) invalidate(tableName string) {
+	if cache == nil {
+		return
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	delete(cache.entries, tableName)
+}
+
+// describeTable returns the description of a table, served from cache if available.
+func (c *Conn) describeTable(ctx context.Context, tableName string) (*types.TableDescription, error) {
+	if desc := c.tableDescriptions.get(tableName); desc != nil {
+		return desc, nil
+	}
+	output, err := c.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return nil, wrapAwsError(err, nil)
+	}
+	c.tableDescriptions.put(tableName, output.Table)
+	return output.Table, nil
+}
+
+/*----------------------------------------------------------------------*/
+
+const (
+	accessPathGetItem = "GetItem"
+	accessPathQuery   = "Query"
+	accessPathScan    = "Scan"
+)
+
+// accessPath describes how DynamoDB executes a SELECT statement.
+type accessPath struct {
+	method        string // one of accessPathGetItem, accessPathQuery or accessPathScan
+	tableName     string
+	indexName     string // empty if the base table is accessed
+	indexType     string // "GSI", "LSI" or empty if the base table is accessed
+	partitionKey  string
+	sortKey       string              // empty if the table/index has no sort key
+	keyConditions []*partiqlCondition // conditions on key attributes
+	filter        []*partiqlCondition // remaining conditions, applied after items are read
+}
+
+// keySchemaOf returns the names of the partition key and sort key of the table or index.
+func keySchemaOf(desc *types.TableDescription, indexName string) (pk, sk, indexType string, err error) {
+	keySchema := desc.KeySchema
+	if indexName != "" {
+		found := false
+		for _, gsi := range desc.GlobalSecondaryIndexes {
+			if aws.ToString(gsi.IndexName) == indexName {
+				keySchema, indexType, found = gsi.KeySchema, "GSI", true
+			}
+		}
+		for _, lsi := range desc.LocalSecondaryIndexes {
+			if aws.ToString(lsi.IndexName) == indexName {
+				keySchema, indexType, found = lsi.KeySchema, "LSI", true
+			}
+		}
+		if !found {
+			return "", "", "", fmt.Errorf("index <%s> not found on table <%s>", indexName, aws.ToString(desc.TableName))
+		}
+	}
+	for _, key := range keySchema {
+		if key.KeyType == types.KeyTypeHash {
+			pk = aws.ToString(key.AttributeName)
+		} else if key.KeyType == types.KeyTypeRange {
+			sk = aws.ToString(key.AttributeName)
+		}
+	}
+	return pk, sk, indexType, nil
+}
+
+// isAttribute checks if the operand is the top-level attribute attr.
+func (o partiqlOperand) isAttribute(attr string) bool {
+	return len(o.path) == 1 && o.path[0] == attr
+}
+
+// isValue checks if the operand is a placeholder or a literal.
+func (o partiqlOperand) isValue() bool {
+	return o.path == nil
+}
+
+// isPartitionKeyCondition checks if the condition is an equality (or IN) condition on the attribute pk.
+func isPartitionKeyCondition(c *partiqlCondition, pk string) bool {
+	switch c.op {
+	case "=":
+		return (c.operands[0].isAttribute(pk) && c.operands[1].isValue()) || (c.operands[1].isAttribute(pk) && c.operands[0].isValue())
+	case "IN":
+		if !c.operands[0].isAttribute(pk) {
+			return false
+		}
+		for _, o := range c.operands[1:] {
+			if !o.isValue() {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isSortKeyCondition checks if the condition can be used as a key condition on the sort key sk.
+func isSortKeyCondition(c *partiqlCondition, sk string) bool {
+	switch c.op {
+	case "=", "<", "<=", ">", ">=":
+		return (c.operands[0].isAttribute(sk) && c.operands[1].isValue()) || (c.operands[1].isAttribute(sk) && c.operands[0].isValue())
+	case "BETWEEN":
+		return c.operands[0].isAttribute(sk) && c.operands[1].isValue() && c.operands[2].isValue()
+	case "begins_with":
+		return c.operands[0].isAttribute(sk) && c.operands[1].isValue()
+	}
+	return false
+}
+
+// analyzeAccessPath determines how DynamoDB executes a SELECT statement, based on the key schema of the table or index.
+func analyzeAccessPath(query *partiqlSelect, desc *types.TableDescription) (*accessPath, error) {
+	pk, sk, indexType, err := keySchemaOf(desc, query.indexName)
+	if err != nil {
+		return nil, err
+	}
+	path := &accessPath{method: accessPathScan, tableName: query.tableName, indexName: query.indexName, indexType: indexType, partitionKey: pk, sortKey: sk}
+	var pkCond, skCond *partiqlCondition
+	for _, c := range query.where.conjuncts() {
+		switch {
+		case pkCond == nil && isPartitionKeyCondition(c, pk):
+			pkCond = c
+		case sk != "" && skCond == nil && isSortKeyCondition(c, sk):
+			skCond = c
+		default:
+			path.filter = append(path.filter, c)
+		}
+	}
+	if pkCond == nil {
+		// without partition key condition, the condition on sort key is just a filter
+		path.filter = query.where.conjuncts()
+		return path, nil
+	}
+	path.method = accessPathQuery
+	path.keyConditions = []*partiqlCondition{pkCond}
+	if skCond != nil {
+		path.keyConditions = append(path.keyConditions, skCond)
+	}
+	if indexType == "" && pkCond.op == "=" && (sk == "" || (skCond != nil && skCond.op == "=")) {
+		path.method = accessPathGetItem
+	}
+	return path, nil
+}
+
+/*----------------------------------------------------------------------*/
+
+// ErrScanNotAllowed is returned when a SELECT statement would run as a full scan while scans are not allowed
+// (DSN option AllowScan=false).
+//
+// @Available since v1.4.0
+var ErrScanNotAllowed = errors.New("full scan is not allowed")
+
+// scanAllowed checks if the statement is allowed to run as a full scan, either by the DSN option AllowScan or the
+// per-statement WITH ALLOW_SCAN=true clause.
+func (c *Conn) scanAllowed(stmt *Stmt) bool {
+	if !c.disallowScan {
+		return true
+	}
+	if opt, ok := stmt.withOpts["ALLOW_SCAN"]; ok {
+		return opt.FirstBool()
+	}
+	return stmt.withOpts["ALLOWSCAN"].FirstBool()
+}
+
+// checkFullScan returns an error wrapping ErrScanNotAllowed if the SELECT statement would run as a full scan and scans
+// are not allowed.
+func (c *Conn) checkFullScan(ctx context.Context, stmt *StmtSelect) error {
+	if c.scanAllowed(stmt.Stmt) {
+		return nil
+	}
+	if stmt.selectQuery == nil {
+		return fmt.Errorf("%w: cannot determine if statement would run as a full scan (%s), use WITH ALLOW_SCAN=true to allow", ErrScanNotAllowed, stmt.selectQueryErr)
+	}
+	if stmt.parallel > 0 {
+		return fmt.Errorf("%w: parallel scan on table <%s>, use WITH ALLOW_SCAN=true to allow", ErrScanNotAllowed, stmt.selectQuery.tableName)
+	}
+	desc, err := c.describeTable(ctx, stmt.selectQuery.tableName)
+	if err != nil {
+		return err
+	}
+	path, err := analyzeAccessPath(stmt.selectQuery, desc)
+	if err != nil {
+		return err
+	}
+	if path.method != accessPathScan {
+		return nil
+	}
+	target := fmt.Sprintf("table <%s>", path.tableName)
+	if path.indexName != "" {
+		target = fmt.Sprintf("index <%s> of table <%s>", path.indexName, path.tableName)
+	}
+	return fmt.Errorf("%w: statement would run as a full scan on %s, WHERE clause has no equality condition on partition key <%s>; use WITH ALLOW_SCAN=true to allow",
+		ErrScanNotAllowed, target, path.partitionKey)
+}
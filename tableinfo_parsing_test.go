@@ -0,0 +1,129 @@
+package godynamo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func _testTableDescription() *types.TableDescription {
+	return &types.TableDescription{
+		TableName: aws.String("tbl"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("app"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("user"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{{
+			IndexName: aws.String("idx_os"),
+			KeySchema: []types.KeySchemaElement{{AttributeName: aws.String("os"), KeyType: types.KeyTypeHash}},
+		}},
+		LocalSecondaryIndexes: []types.LocalSecondaryIndexDescription{{
+			IndexName: aws.String("idx_time"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("app"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("time"), KeyType: types.KeyTypeRange},
+			},
+		}},
+		TableSizeBytes: aws.Int64(10000),
+		ItemCount:      aws.Int64(10),
+	}
+}
+
+func Test_analyzeAccessPath(t *testing.T) {
+	testName := "Test_analyzeAccessPath"
+	testData := []struct {
+		name          string
+		sql           string
+		method        string
+		indexType     string
+		keyConditions string
+		filter        string
+		mustError     bool
+	}{
+		{name: "get_item", sql: `SELECT * FROM tbl WHERE app=? AND "user"=?`, method: accessPathGetItem, keyConditions: "app = ? AND user = ?"},
+		{name: "query_pk", sql: `SELECT * FROM tbl WHERE ?=app AND active=true`, method: accessPathQuery, keyConditions: "? = app", filter: "active = TRUE"},
+		{name: "query_pk_in", sql: `SELECT * FROM tbl WHERE app IN ('a', 'b')`, method: accessPathQuery, keyConditions: "app IN ('a', 'b')"},
+		{name: "query_sk_range", sql: `SELECT * FROM tbl WHERE begins_with("user", 'u') AND app='a' AND os='linux'`, method: accessPathQuery, keyConditions: "app = 'a' AND begins_with(user, 'u')", filter: "os = 'linux'"},
+		{name: "query_gsi", sql: `SELECT * FROM "tbl"."idx_os" WHERE os=?`, method: accessPathQuery, indexType: "GSI", keyConditions: "os = ?"},
+		{name: "query_lsi", sql: `SELECT * FROM "tbl"."idx_time" WHERE app=? AND "time" BETWEEN 1 AND 2`, method: accessPathQuery, indexType: "LSI", keyConditions: "app = ? AND time BETWEEN 1 AND 2"},
+		{name: "scan_no_where", sql: `SELECT * FROM tbl`, method: accessPathScan},
+		{name: "scan_sk_only", sql: `SELECT * FROM tbl WHERE "user"=?`, method: accessPathScan, filter: "user = ?"},
+		{name: "scan_pk_range", sql: `SELECT * FROM tbl WHERE app>?`, method: accessPathScan, filter: "app > ?"},
+		{name: "scan_or", sql: `SELECT * FROM tbl WHERE app=? OR os=?`, method: accessPathScan, filter: "app = ? OR os = ?"},
+
+		{name: "index_not_found", sql: `SELECT * FROM "tbl"."idx_none" WHERE app=?`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			query, err := parsePartiqlSelect(testCase.sql)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			path, err := analyzeAccessPath(query, _testTableDescription())
+			if testCase.mustError && err == nil {
+				t.Fatalf("%s failed: analyzing must fail", testName+"/"+testCase.name)
+			}
+			if testCase.mustError {
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			keyConditions, filter := conditionsString(path.keyConditions), conditionsString(path.filter)
+			if path.method != testCase.method || path.indexType != testCase.indexType || keyConditions != testCase.keyConditions || filter != testCase.filter {
+				t.Fatalf("%s failed: unexpected access path %s/%s/[%s]/[%s]", testName+"/"+testCase.name, path.method, path.indexType, keyConditions, filter)
+			}
+		})
+	}
+}
+
+func Test_checkFullScan(t *testing.T) {
+	testName := "Test_checkFullScan"
+	conn := &Conn{disallowScan: true, tableDescriptions: &tableDescriptionCache{entries: make(map[string]tableDescriptionEntry)}}
+	conn.tableDescriptions.put("tbl", _testTableDescription())
+	testData := []struct {
+		name      string
+		sql       string
+		mustError bool
+	}{
+		{name: "query", sql: `SELECT * FROM tbl WHERE app=?`},
+		{name: "scan_allowed", sql: `SELECT * FROM tbl WITH ALLOW_SCAN=true`},
+		{name: "scan_allowed_with_limit", sql: `SELECT * FROM tbl WHERE os=? LIMIT 10 WITH ConsistentRead=true, WITH ALLOW_SCAN=true`},
+
+		{name: "scan", sql: `SELECT * FROM tbl WHERE os=?`, mustError: true},
+		{name: "scan_index", sql: `SELECT * FROM "tbl"."idx_os" WHERE app=?`, mustError: true},
+		{name: "scan_not_allowed", sql: `SELECT * FROM tbl WITH ALLOW_SCAN=false`, mustError: true},
+		{name: "parallel", sql: `SELECT * FROM tbl WITH PARALLEL=2`, mustError: true},
+		{name: "cannot_analyze", sql: `SELECT * FROM tbl WHERE size(app) > 1`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(conn, testCase.sql)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			err = conn.checkFullScan(context.Background(), s.(*StmtSelect))
+			if testCase.mustError && !errors.Is(err, ErrScanNotAllowed) {
+				t.Fatalf("%s failed: expected ErrScanNotAllowed but received %v", testName+"/"+testCase.name, err)
+			}
+			if !testCase.mustError && err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+		})
+	}
+
+	s, _ := parseQuery(conn, `SELECT * FROM "tbl"."idx_os" WHERE app=?`)
+	if err := conn.checkFullScan(context.Background(), s.(*StmtSelect)); err == nil || !strings.Contains(err.Error(), "<tbl>") || !strings.Contains(err.Error(), "<os>") {
+		t.Fatalf("%s failed: error must name the table and the partition key: %v", testName, err)
+	}
+
+	conn.disallowScan = false
+	s, _ = parseQuery(conn, `SELECT * FROM tbl WHERE os=?`)
+	if err := conn.checkFullScan(context.Background(), s.(*StmtSelect)); err != nil {
+		t.Fatalf("%s failed: scan must be allowed by default: %s", testName, err)
+	}
+}
@@ -1,7 +1,12 @@
 package godynamo
 
 import (
+	"context"
+	"database/sql/driver"
 	"fmt"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 // TxResultNoResultSet is transaction-aware version of ResultNoResultSet.
@@ -33,59 +38,88 @@ func (t *TxResultNoResultSet) RowsAffected() (int64, error) {
 	return t.affectedRows, nil
 }
 
-// // TxResultResultSet is transaction-aware version of ResultResultSet.
-// //
-// // @Available since v0.2.0
-// type TxResultResultSet struct {
-// 	wrap      ResultResultSet
-// 	hasOutput bool
-// 	outputFn  executeStatementOutputWrapper
-// }
-//
-// func (r *TxResultResultSet) checkOutput() {
-// 	if !r.hasOutput {
-// 		r.wrap.stmtOutput = r.outputFn()
-// 		fmt.Println("DEBUG", r.wrap.stmtOutput)
-// 		if r.wrap.stmtOutput != nil {
-// 			r.wrap.err = nil
-// 			r.hasOutput = true
-// 			r.wrap.init()
-// 		}
-// 	}
-// }
+// TxResultResultSet is transaction-aware version of ResultResultSet: rows returned by an INSERT/UPDATE/DELETE statement
+// with RETURNING clause are available once the transaction is committed. Before that, Next returns ErrInTx.
 //
-// // Columns implements driver.Rows/Columns.
-// func (r *TxResultResultSet) Columns() []string {
-// 	r.checkOutput()
-// 	return r.wrap.Columns()
-// }
+// database/sql closes *sql.Rows when the transaction is committed, hence the rows can not be read via *sql.Rows; use
+// TxReturning to receive them instead.
 //
-// // ColumnTypeScanType implements driver.RowsColumnTypeScanType/ColumnTypeScanType
-// func (r *TxResultResultSet) ColumnTypeScanType(index int) reflect.Type {
-// 	r.checkOutput()
-// 	return r.wrap.ColumnTypeScanType(index)
-// }
+// @Available since v1.4.0
+type TxResultResultSet struct {
+	wrap       *ResultResultSet
+	outputFn   executeStatementOutputWrapper
+	numberMode string
+}
+
+// checkOutput wraps the statement's output once available, and reports if it is.
+func (r *TxResultResultSet) checkOutput() bool {
+	if r.wrap == nil {
+		if output := r.outputFn(); output != nil {
+			r.wrap = (&ResultResultSet{stmtOutput: output, numberMode: r.numberMode}).init()
+		}
+	}
+	return r.wrap != nil
+}
+
+// Columns implements driver.Rows/Columns.
+func (r *TxResultResultSet) Columns() []string {
+	if !r.checkOutput() {
+		return []string{}
+	}
+	return r.wrap.Columns()
+}
+
+// ColumnTypeScanType implements driver.RowsColumnTypeScanType/ColumnTypeScanType
+func (r *TxResultResultSet) ColumnTypeScanType(index int) reflect.Type {
+	if !r.checkOutput() {
+		return nil
+	}
+	return r.wrap.ColumnTypeScanType(index)
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName/ColumnTypeDatabaseTypeName
+func (r *TxResultResultSet) ColumnTypeDatabaseTypeName(index int) string {
+	if !r.checkOutput() {
+		return ""
+	}
+	return r.wrap.ColumnTypeDatabaseTypeName(index)
+}
+
+// Close implements driver.Rows/Close.
+func (r *TxResultResultSet) Close() error {
+	return nil
+}
+
+// Next implements driver.Rows/Next.
+func (r *TxResultResultSet) Next(dest []driver.Value) error {
+	if !r.checkOutput() {
+		return ErrInTx
+	}
+	return r.wrap.Next(dest)
+}
+
+// TxReturning receives the rows returned by an INSERT/UPDATE/DELETE statement executed inside a transaction, once the
+// transaction is committed. Pass it via sql.Out as an extra argument of the statement; the argument is not bound to
+// any placeholder:
 //
-// // ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName/ColumnTypeDatabaseTypeName
-// func (r *TxResultResultSet) ColumnTypeDatabaseTypeName(index int) string {
-// 	r.checkOutput()
-// 	return r.wrap.ColumnTypeDatabaseTypeName(index)
-// }
+//	var returning godynamo.TxReturning
+//	tx, _ := db.Begin()
+//	_, err := tx.Exec(`DELETE FROM "session" WHERE id=?`, "1", sql.Out{Dest: &returning})
+//	...
+//	err = tx.Commit()
+//	// returning.Items holds the deleted item
 //
-// // Close implements driver.Rows/Close.
-// func (r *TxResultResultSet) Close() error {
-// 	r.checkOutput()
-// 	if !r.hasOutput {
-// 		return ErrInTx
-// 	}
-// 	return nil
-// }
+// Supported statements:
+//   - INSERT: the statement must have a RETURNING clause, see StmtInsert.
+//   - UPDATE/DELETE: the old image of the item (RETURNING ALL OLD *, the only supported clause) is fetched with a
+//     strongly consistent read right before the transaction is executed. The statement is then executed only if each
+//     attribute of the item still equals its value in the old image, so that the transaction fails to commit if the
+//     item was modified in between; attributes added in between are not detected.
 //
-// // Next implements driver.Rows/Next.
-// func (r *TxResultResultSet) Next(dest []driver.Value) error {
-// 	r.checkOutput()
-// 	return r.wrap.Next(dest)
-// }
+// @Available since v1.4.0
+type TxReturning struct {
+	Items []map[string]types.AttributeValue // the returned rows, set once the transaction is committed
+}
 
 /*----------------------------------------------------------------------*/
 
@@ -93,15 +127,28 @@ func (t *TxResultNoResultSet) RowsAffected() (int64, error) {
 //
 // @Available since v0.2.0
 type Tx struct {
-	conn *Conn
+	conn     *Conn
+	readOnly bool            // sql.TxOptions.ReadOnly, only read statements are allowed in the transaction (@Available since v1.4.0)
+	ctx      context.Context // the context passed to BeginTx, which bounds the commit (@Available since v1.4.0)
 }
 
 // Commit implements driver.Tx/Commit
+//
+// @Since v1.4.0 the commit is reported to Hooks.OnCommit.
+//
+// @Since v1.4.0 the commit is bound to the context passed to BeginTx (e.g. sql.DB.BeginTx), and to the connection's
+// timeout (DSN option TimeoutMs).
 func (t *Tx) Commit() error {
-	return t.conn.commit()
+	return t.conn.withTxHooks(t.ctx, true, func(ctx context.Context) error {
+		return t.conn.commit(ctx)
+	})
 }
 
 // Rollback implements driver.Tx/Rollback
+//
+// @Since v1.4.0 the rollback is reported to Hooks.OnRollback.
 func (t *Tx) Rollback() error {
-	return t.conn.rollback()
+	return t.conn.withTxHooks(t.ctx, false, func(_ context.Context) error {
+		return t.conn.rollback()
+	})
 }